@@ -3,18 +3,25 @@ module github.com/CiaranMcAleer/colade
 go 1.24.5
 
 require (
+	github.com/BurntSushi/toml v1.2.1
+	github.com/alecthomas/chroma/v2 v2.20.0
+	github.com/microcosm-cc/bluemonday v1.0.27
 	github.com/spf13/cobra v1.9.1
 	github.com/yuin/goldmark v1.7.13
+	go.abhg.dev/goldmark/frontmatter v0.2.0
+	go.abhg.dev/goldmark/mermaid v0.5.0
+	golang.org/x/image v0.25.0
+	golang.org/x/net v0.26.0
+	golang.org/x/text v0.27.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
-	github.com/BurntSushi/toml v1.2.1 // indirect
+	github.com/aymerick/douceur v0.2.0 // indirect
+	github.com/dlclark/regexp2 v1.11.5 // indirect
+	github.com/gorilla/css v1.0.1 // indirect
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect
 	github.com/spf13/pflag v1.0.6 // indirect
 	github.com/yuin/goldmark-meta v1.1.0 // indirect
-	go.abhg.dev/goldmark/frontmatter v0.2.0 // indirect
-	go.abhg.dev/goldmark/mermaid v0.5.0 // indirect
-	golang.org/x/text v0.27.0 // indirect
 	gopkg.in/yaml.v2 v2.3.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -0,0 +1,82 @@
+// breadcrumbs.go - Breadcrumb trails from the content directory hierarchy
+package sitegen
+
+import (
+	"encoding/json"
+	"html/template"
+	"path"
+	"path/filepath"
+)
+
+// Breadcrumb is one step of the trail from the site root to a page
+type Breadcrumb struct {
+	Title   string
+	URL     string // empty for directories without an index page
+	Page    *Page  // the section's index page or the page itself, nil if there is none
+	Current bool   // the last step, the page itself
+}
+
+// linkBreadcrumbs gives every page a trail through its ancestor directories,
+// titled after each directory's index page in the same language
+func (s *Site) linkBreadcrumbs() {
+	indexes := make(map[string]*Page)
+	for _, p := range s.Pages {
+		if isIndexPage(p.key) {
+			indexes[p.langCode()+"\x00"+path.Dir(filepath.ToSlash(p.key))] = p
+		}
+	}
+
+	for _, p := range s.Pages {
+		dir := path.Dir(filepath.ToSlash(p.key))
+		if isIndexPage(p.key) {
+			if dir == "." {
+				p.breadcrumbs = []Breadcrumb{{Title: p.Title, URL: p.URL, Page: p, Current: true}}
+				continue
+			}
+			dir = path.Dir(dir)
+		}
+		var trail []Breadcrumb
+		for {
+			crumb := Breadcrumb{Title: titleFromFilename(dir)}
+			if dir == "." {
+				crumb.Title = "Home"
+			}
+			if index := indexes[p.langCode()+"\x00"+dir]; index != nil {
+				crumb = Breadcrumb{Title: index.Title, URL: index.URL, Page: index}
+			}
+			trail = append([]Breadcrumb{crumb}, trail...)
+			if dir == "." {
+				break
+			}
+			dir = path.Dir(dir)
+		}
+		p.breadcrumbs = append(trail, Breadcrumb{Title: p.Title, URL: p.URL, Page: p, Current: true})
+	}
+}
+
+// breadcrumbsJSONLD renders a trail as a schema.org BreadcrumbList script,
+// with URLs below base, the site's base URL or base path
+func breadcrumbsJSONLD(trail []Breadcrumb, base string) template.HTML {
+	if len(trail) < 2 {
+		return ""
+	}
+	type listItem struct {
+		Type     string `json:"@type"`
+		Position int    `json:"position"`
+		Name     string `json:"name"`
+		Item     string `json:"item,omitempty"`
+	}
+	list := struct {
+		Context string     `json:"@context"`
+		Type    string     `json:"@type"`
+		Items   []listItem `json:"itemListElement"`
+	}{Context: "https://schema.org", Type: "BreadcrumbList"}
+	for i, crumb := range trail {
+		list.Items = append(list.Items, listItem{Type: "ListItem", Position: i + 1, Name: crumb.Title, Item: absURL(base, crumb.URL)})
+	}
+	data, err := json.Marshal(list)
+	if err != nil {
+		return ""
+	}
+	return template.HTML(`<script type="application/ld+json">` + string(data) + `</script>`)
+}
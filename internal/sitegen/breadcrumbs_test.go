@@ -0,0 +1,70 @@
+package sitegen
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func crumbTitles(trail []Breadcrumb) string {
+	var titles []string
+	for _, c := range trail {
+		titles = append(titles, c.Title+"="+c.URL)
+	}
+	return strings.Join(titles, " > ")
+}
+
+func TestSite_Breadcrumbs(t *testing.T) {
+	inputDir := t.TempDir()
+	writeTestFile(t, filepath.Join(inputDir, "index.md"), "# Welcome")
+	writeTestFile(t, filepath.Join(inputDir, "docs", "index.md"), "# Documentation")
+	writeTestFile(t, filepath.Join(inputDir, "docs", "guide", "index.md"), "# User guide")
+	writeTestFile(t, filepath.Join(inputDir, "docs", "guide", "install.md"), "# Installing")
+	writeTestFile(t, filepath.Join(inputDir, "docs", "internal-api", "auth.md"), "# Auth")
+	pages := []string{"index.md", filepath.Join("docs", "index.md"), filepath.Join("docs", "guide", "index.md"),
+		filepath.Join("docs", "guide", "install.md"), filepath.Join("docs", "internal-api", "auth.md")}
+
+	site, err := LoadSite(NewMarkdownProcessor(""), &Config{}, inputDir, pages)
+	if err != nil {
+		t.Fatalf("LoadSite failed: %v", err)
+	}
+	tests := map[string]string{
+		"index.md": "Welcome=/index.html",
+		filepath.Join("docs", "guide", "index.md"):       "Welcome=/index.html > Documentation=/docs/index.html > User guide=/docs/guide/index.html",
+		filepath.Join("docs", "guide", "install.md"):     "Welcome=/index.html > Documentation=/docs/index.html > User guide=/docs/guide/index.html > Installing=/docs/guide/install.html",
+		filepath.Join("docs", "internal-api", "auth.md"): "Welcome=/index.html > Documentation=/docs/index.html > Internal Api= > Auth=/docs/internal-api/auth.html",
+	}
+	for relPath, want := range tests {
+		trail := site.Page(relPath).breadcrumbs
+		if got := crumbTitles(trail); got != want {
+			t.Errorf("breadcrumbs of %s = %q, want %q", relPath, got, want)
+		}
+		if !trail[len(trail)-1].Current {
+			t.Errorf("expected the last breadcrumb of %s to be current", relPath)
+		}
+	}
+}
+
+func TestBuild_BreadcrumbsJSONLD(t *testing.T) {
+	inputDir := t.TempDir()
+	outputDir := t.TempDir()
+	writeTestFile(t, filepath.Join(inputDir, "colade.yaml"), "breadcrumbs:\n  json_ld: true\n")
+	writeTestFile(t, filepath.Join(inputDir, "docs", "index.md"), "# Docs")
+	writeTestFile(t, filepath.Join(inputDir, "docs", "setup.md"), "# Setup")
+
+	if err := Build(BuildOptions{InputDir: inputDir, OutputDir: outputDir}); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	out := readTestFile(t, filepath.Join(outputDir, "docs", "setup.html"))
+	for _, want := range []string{
+		`<li>Home</li><li><a href="/docs/index.html">Docs</a></li><li><span aria-current="page">Setup</span></li>`,
+		`<script type="application/ld+json">{"@context":"https://schema.org","@type":"BreadcrumbList","itemListElement":[` +
+			`{"@type":"ListItem","position":1,"name":"Home"},` +
+			`{"@type":"ListItem","position":2,"name":"Docs","item":"/docs/index.html"},` +
+			`{"@type":"ListItem","position":3,"name":"Setup","item":"/docs/setup.html"}]}</script>`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, out)
+		}
+	}
+}
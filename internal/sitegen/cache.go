@@ -3,18 +3,26 @@ package sitegen
 
 import (
 	"encoding/json"
+	"maps"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
 type cacheFile struct {
-	Version int                       `json:"version"`
-	Files   map[string]cacheFileEntry `json:"files"`
+	Version   int                       `json:"version"`
+	Settings  string                    `json:"settings,omitempty"`
+	Files     map[string]cacheFileEntry `json:"files"`
+	Images    map[string]imageInfo      `json:"images,omitempty"`    // resized variants by source path, see ProcessImages
+	Generated []string                  `json:"generated,omitempty"` // output files generated without a source
 }
 
 type cacheFileEntry struct {
-	Mtime  int64  `json:"mtime"`
-	Output string `json:"output"`
+	Mtime   int64            `json:"mtime"`
+	Output  string           `json:"output"`
+	Deps    map[string]int64 `json:"deps,omitempty"`
+	Context string           `json:"context,omitempty"`
+	Listing bool             `json:"listing,omitempty"` // reads .Site.Pages, see Site.ContextKey
 }
 
 func loadCache(path string) (*cacheFile, error) {
@@ -59,3 +67,36 @@ func getMtime(path string) int64 {
 	}
 	return info.ModTime().Unix()
 }
+
+// depKey returns the cache key for a file a page depends on: relative to the
+// input directory when inside it, absolute otherwise
+func depKey(inputDir, path string) string {
+	if rel, err := filepath.Rel(inputDir, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
+
+// depMtimes records the current modification times of a page's dependencies
+func depMtimes(inputDir string, deps []string) map[string]int64 {
+	if len(deps) == 0 {
+		return nil
+	}
+	m := make(map[string]int64, len(deps))
+	for _, d := range deps {
+		p := d
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(inputDir, d)
+		}
+		m[d] = getMtime(p)
+	}
+	return m
+}
+
+// depsChanged reports whether a page's dependencies differ from the cached ones
+func depsChanged(prev cacheFileEntry, current map[string]int64) bool {
+	return !maps.Equal(prev.Deps, current)
+}
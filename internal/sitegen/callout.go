@@ -0,0 +1,241 @@
+// callout.go - GitHub-style > [!NOTE] alerts and :::warning blocks rendered as callouts
+package sitegen
+
+import (
+	"bytes"
+	"math"
+	"regexp"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// calloutTitles are the default titles of the callout types, GitHub's alerts
+// and the info and danger types common with ::: blocks
+var calloutTitles = map[string]string{
+	"note":      "Note",
+	"tip":       "Tip",
+	"important": "Important",
+	"warning":   "Warning",
+	"caution":   "Caution",
+	"info":      "Info",
+	"danger":    "Danger",
+}
+
+// alertMarkerPattern matches the [!TYPE] line opening a callout blockquote,
+// optionally followed by a title
+var alertMarkerPattern = regexp.MustCompile(`^\[!([A-Za-z]+)\][ \t]*(.*?)[ \t]*$`)
+
+// KindCallout is the node kind of callouts
+var KindCallout = ast.NewNodeKind("Callout")
+
+// Callout is a note, tip or warning set apart from the text around it
+type Callout struct {
+	ast.BaseBlock
+	CalloutType string // key of calloutTitles
+	Title       string
+	fence       int // number of colons opening a ::: block, 0 for blockquotes
+}
+
+func (n *Callout) Kind() ast.NodeKind { return KindCallout }
+
+func (n *Callout) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"CalloutType": n.CalloutType, "Title": n.Title}, nil)
+}
+
+// newCallout returns a callout of a known type, or nil
+func newCallout(typ, title string) *Callout {
+	typ = strings.ToLower(typ)
+	defaultTitle, ok := calloutTitles[typ]
+	if !ok {
+		return nil
+	}
+	if title == "" {
+		title = defaultTitle
+	}
+	return &Callout{CalloutType: typ, Title: title}
+}
+
+// callouts is a goldmark extension for callouts
+type callouts struct{}
+
+func (e *callouts) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(
+		parser.WithBlockParsers(util.Prioritized(&calloutParser{}, 700)),
+		parser.WithASTTransformers(util.Prioritized(&alertTransformer{}, 100)),
+	)
+	m.Renderer().AddOptions(renderer.WithNodeRenderers(
+		util.Prioritized(&calloutRenderer{}, 500),
+	))
+}
+
+// calloutParser parses :::type [title] blocks, closed by a line of at least
+// as many colons. Blocks nest by opening the outer one with more colons.
+type calloutParser struct{}
+
+func (p *calloutParser) Trigger() []byte {
+	return []byte{':'}
+}
+
+// fenceLength returns the number of colons a line starts with after up to
+// three spaces of indentation and the position after them, or 0 if there are
+// fewer than three
+func fenceLength(line []byte, offset int) (int, int) {
+	w, pos := util.IndentWidth(line, offset)
+	if w > 3 {
+		return 0, 0
+	}
+	n := 0
+	for pos+n < len(line) && line[pos+n] == ':' {
+		n++
+	}
+	if n < 3 {
+		return 0, 0
+	}
+	return n, pos + n
+}
+
+func (p *calloutParser) Open(parent ast.Node, reader text.Reader, pc parser.Context) (ast.Node, parser.State) {
+	line, _ := reader.PeekLine()
+	n, pos := fenceLength(line, reader.LineOffset())
+	if n == 0 {
+		return nil, parser.NoChildren
+	}
+	rest := strings.TrimSpace(string(line[pos:]))
+	typ, title, _ := strings.Cut(rest, " ")
+	node := newCallout(typ, strings.TrimSpace(title))
+	if node == nil {
+		return nil, parser.NoChildren
+	}
+	node.fence = n
+	reader.AdvanceToEOL()
+	return node, parser.HasChildren
+}
+
+func (p *calloutParser) Continue(node ast.Node, reader text.Reader, pc parser.Context) parser.State {
+	line, _ := reader.PeekLine()
+	n, pos := fenceLength(line, reader.LineOffset())
+	if n >= node.(*Callout).fence && util.IsBlank(line[pos:]) {
+		// Consume the closing line so that no block is opened from it
+		reader.AdvanceToEOL()
+		return parser.Close
+	}
+	return parser.Continue | parser.HasChildren
+}
+
+func (p *calloutParser) Close(node ast.Node, reader text.Reader, pc parser.Context) {}
+
+func (p *calloutParser) CanInterruptParagraph() bool {
+	return true
+}
+
+func (p *calloutParser) CanAcceptIndentedLine() bool {
+	return false
+}
+
+// alertTransformer turns blockquotes whose first line is a [!TYPE] marker
+// into callouts, as GitHub renders them
+type alertTransformer struct{}
+
+func (t *alertTransformer) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	source := reader.Source()
+	var quotes []*ast.Blockquote
+	ast.Walk(doc, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+		if q, ok := node.(*ast.Blockquote); ok && entering {
+			quotes = append(quotes, q)
+		}
+		return ast.WalkContinue, nil
+	})
+	for _, q := range quotes {
+		para, ok := q.FirstChild().(*ast.Paragraph)
+		if !ok || para.Lines().Len() == 0 {
+			continue
+		}
+		first := para.Lines().At(0)
+		m := alertMarkerPattern.FindSubmatch(bytes.TrimRight(first.Value(source), "\r\n"))
+		if m == nil {
+			continue
+		}
+		callout := newCallout(string(m[1]), string(m[2]))
+		if callout == nil {
+			continue
+		}
+
+		// Drop the marker line from the paragraph, and the paragraph if that was all
+		for c := para.FirstChild(); c != nil && inlineStart(c) < first.Stop; {
+			next := c.NextSibling()
+			para.RemoveChild(para, c)
+			c = next
+		}
+		if para.ChildCount() == 0 {
+			q.RemoveChild(q, para)
+		} else {
+			lines := text.NewSegments()
+			lines.AppendAll(para.Lines().Sliced(1, para.Lines().Len()))
+			para.SetLines(lines)
+		}
+		for c := q.FirstChild(); c != nil; {
+			next := c.NextSibling()
+			callout.AppendChild(callout, c)
+			c = next
+		}
+		q.Parent().ReplaceChild(q.Parent(), q, callout)
+	}
+}
+
+// inlineStart returns where an inline node starts in the source. Nodes
+// without text or raw HTML, like images without alt text, get the start of
+// the next sibling that has some, or the end of the source if none does.
+func inlineStart(n ast.Node) int {
+	for ; n != nil; n = n.NextSibling() {
+		if start := contentStart(n); start >= 0 {
+			return start
+		}
+	}
+	return math.MaxInt
+}
+
+// contentStart returns where the first text or raw HTML in an inline node
+// starts in the source, or -1 if it has none
+func contentStart(n ast.Node) int {
+	switch n := n.(type) {
+	case *ast.Text:
+		return n.Segment.Start
+	case *ast.RawHTML:
+		if n.Segments.Len() > 0 {
+			return n.Segments.At(0).Start
+		}
+	}
+	for c := n.FirstChild(); c != nil; c = c.NextSibling() {
+		if start := contentStart(c); start >= 0 {
+			return start
+		}
+	}
+	return -1
+}
+
+// calloutRenderer writes callouts as <aside class="callout callout-type">
+// starting with their title
+type calloutRenderer struct{}
+
+func (r *calloutRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(KindCallout, r.renderCallout)
+}
+
+func (r *calloutRenderer) renderCallout(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	n := node.(*Callout)
+	if !entering {
+		_, _ = w.WriteString("</aside>\n")
+		return ast.WalkContinue, nil
+	}
+	_, _ = w.WriteString(`<aside class="callout callout-` + n.CalloutType + `">` + "\n")
+	_, _ = w.WriteString(`<p class="callout-title">`)
+	_, _ = w.Write(util.EscapeHTML([]byte(n.Title)))
+	_, _ = w.WriteString("</p>\n")
+	return ast.WalkContinue, nil
+}
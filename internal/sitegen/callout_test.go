@@ -0,0 +1,80 @@
+package sitegen
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCallouts(t *testing.T) {
+	for name, tc := range map[string]struct{ in, want string }{
+		"alert": {
+			"> [!NOTE]\n> Useful *information*.\n> More.\n",
+			"<aside class=\"callout callout-note\">\n<p class=\"callout-title\">Note</p>\n<p>Useful <em>information</em>.\nMore.</p>\n</aside>\n",
+		},
+		"alert with title": {
+			"> [!warning] Mind the <gap>\n>\n> - one\n",
+			"<aside class=\"callout callout-warning\">\n<p class=\"callout-title\">Mind the &lt;gap&gt;</p>\n<ul>\n<li>one</li>\n</ul>\n</aside>\n",
+		},
+		"alert before inline HTML": {
+			"> [!TIP]\n> <kbd>Ctrl</kbd> and more\n",
+			"<aside class=\"callout callout-tip\">\n<p class=\"callout-title\">Tip</p>\n<p><kbd>Ctrl</kbd> and more</p>\n</aside>\n",
+		},
+		"alert before image": {
+			"> [!NOTE]\n> ![](x.png) picture\n",
+			"<aside class=\"callout callout-note\">\n<p class=\"callout-title\">Note</p>\n<p><img src=\"x.png\" alt=\"\"> picture</p>\n</aside>\n",
+		},
+		"alert before lone image": {
+			"> [!NOTE]\n> ![](x.png)\n",
+			"<aside class=\"callout callout-note\">\n<p class=\"callout-title\">Note</p>\n<p><img src=\"x.png\" alt=\"\"></p>\n</aside>\n",
+		},
+		"unknown alert": {
+			"> [!UNKNOWN]\n> text\n",
+			"<blockquote>\n<p>[!UNKNOWN]\ntext</p>\n</blockquote>\n",
+		},
+		"marker not first": {
+			"> text\n> [!TIP]\n",
+			"<blockquote>\n<p>text\n[!TIP]</p>\n</blockquote>\n",
+		},
+		"fenced": {
+			":::tip\nUse **this**.\n:::\nAfter\n",
+			"<aside class=\"callout callout-tip\">\n<p class=\"callout-title\">Tip</p>\n<p>Use <strong>this</strong>.</p>\n</aside>\n<p>After</p>\n",
+		},
+		"nested": {
+			"::::danger Careful\n:::info\nInner\n:::\nOuter\n::::\n",
+			"<aside class=\"callout callout-danger\">\n<p class=\"callout-title\">Careful</p>\n<aside class=\"callout callout-info\">\n<p class=\"callout-title\">Info</p>\n<p>Inner</p>\n</aside>\n<p>Outer</p>\n</aside>\n",
+		},
+		"unknown fence": {
+			":::nope\ntext\n:::\n",
+			"<p>:::nope\ntext\n:::</p>\n",
+		},
+		"code": {
+			"```\n:::warning\n> [!NOTE]\n```\n",
+			"<pre><code>:::warning\n&gt; [!NOTE]\n</code></pre>\n",
+		},
+	} {
+		out, _, err := NewMarkdownProcessor("").renderMarkdown([]byte(tc.in))
+		if err != nil {
+			t.Fatalf("%s: renderMarkdown failed: %v", name, err)
+		}
+		if string(out) != tc.want {
+			t.Errorf("%s: renderMarkdown() = %q, want %q", name, out, tc.want)
+		}
+	}
+}
+
+func TestBuild_CalloutStyles(t *testing.T) {
+	inputDir := t.TempDir()
+	outputDir := t.TempDir()
+	writeTestFile(t, filepath.Join(inputDir, "index.md"), "# Home\n\n> [!WARNING]\n> Back up first.\n")
+
+	if err := Build(BuildOptions{InputDir: inputDir, OutputDir: outputDir}); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	if page := readTestFile(t, filepath.Join(outputDir, "index.html")); !strings.Contains(page, `<aside class="callout callout-warning">`) {
+		t.Errorf("expected a warning callout, got:\n%s", page)
+	}
+	if css := readTestFile(t, filepath.Join(outputDir, "style.css")); !strings.Contains(css, ".callout-warning") {
+		t.Errorf("expected style.css to style callouts, got:\n%s", css)
+	}
+}
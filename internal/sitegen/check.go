@@ -0,0 +1,176 @@
+// check.go - Validation of the links and assets of a built site
+package sitegen
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// idAttrPattern matches the attributes that make fragment targets
+var idAttrPattern = regexp.MustCompile(`\b(?:id|name)="([^"]*)"`)
+
+// LinkProblem is a broken link found by CheckLinks
+type LinkProblem struct {
+	Page   string // output path of the page, slash-separated
+	Line   int
+	URL    string
+	Reason string
+}
+
+func (p LinkProblem) String() string {
+	return fmt.Sprintf("%s:%d: %s: %s", p.Page, p.Line, p.URL, p.Reason)
+}
+
+// linkChecker holds the pages of a built site while their links are checked
+type linkChecker struct {
+	dir      string
+	basePath string
+	ids      map[string]map[string]bool // fragment targets of each page, read on demand
+}
+
+// CheckLinks checks every HTML page below dir: internal href and src URLs
+// must resolve to a file, fragments must exist on the target page and no
+// link may point at a markdown source. Root-relative URLs are resolved below
+// basePath, the path the site is served from.
+func CheckLinks(dir, basePath string) ([]LinkProblem, error) {
+	lc := &linkChecker{dir: dir, basePath: normalizeBasePath(basePath), ids: make(map[string]map[string]bool)}
+	var pages []string
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && strings.EqualFold(filepath.Ext(p), ".html") {
+			rel, _ := filepath.Rel(dir, p)
+			pages = append(pages, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk %s: %w", dir, err)
+	}
+	sort.Strings(pages)
+
+	var problems []LinkProblem
+	for _, page := range pages {
+		content, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(page)))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", page, err)
+		}
+		for _, m := range urlAttrPattern.FindAllSubmatchIndex(content, -1) {
+			attr, value := string(content[m[2]:m[3]]), html.UnescapeString(string(content[m[4]:m[5]]))
+			line := bytes.Count(content[:m[0]], []byte("\n")) + 1
+			urls := []string{value}
+			if attr == "srcset" {
+				urls = nil
+				for _, c := range strings.Split(value, ",") {
+					if fields := strings.Fields(c); len(fields) > 0 {
+						urls = append(urls, fields[0])
+					}
+				}
+			}
+			for _, u := range urls {
+				if reason := lc.check(page, u); reason != "" {
+					problems = append(problems, LinkProblem{Page: page, Line: line, URL: u, Reason: reason})
+				}
+			}
+		}
+	}
+	return problems, nil
+}
+
+// check returns why a URL on a page is broken, or "" if it is fine
+func (lc *linkChecker) check(page, u string) string {
+	if u == "" || !isRelativeURL(u) && !isRootRelative(u) && !strings.HasPrefix(u, "#") {
+		return ""
+	}
+	target, suffix := splitURL(u)
+	fragment := ""
+	if i := strings.Index(suffix, "#"); i >= 0 {
+		fragment = suffix[i+1:]
+	}
+	if unescaped, err := url.PathUnescape(target); err == nil {
+		target = unescaped
+	}
+
+	// Resolve the target to a file below the output directory
+	var file string
+	switch {
+	case target == "":
+		file = page
+	case isRootRelative(target):
+		if target == lc.basePath {
+			target += "/"
+		}
+		rest, ok := strings.CutPrefix(target, lc.basePath+"/")
+		if !ok {
+			return "outside of the base path " + lc.basePath
+		}
+		file = path.Clean(rest)
+	default:
+		file = path.Join(path.Dir(page), target)
+	}
+	if file == ".." || strings.HasPrefix(file, "../") {
+		return "points outside of the site"
+	}
+	if ext := strings.ToLower(path.Ext(file)); ext == ".md" || ext == ".markdown" {
+		return "links to a markdown source"
+	}
+	info, err := os.Stat(filepath.Join(lc.dir, filepath.FromSlash(file)))
+	if err == nil && info.IsDir() {
+		file = path.Join(file, "index.html")
+		info, err = os.Stat(filepath.Join(lc.dir, filepath.FromSlash(file)))
+	}
+	if err != nil {
+		return "not found"
+	}
+
+	if fragment == "" || fragment == "top" || !strings.EqualFold(path.Ext(file), ".html") {
+		return ""
+	}
+	if unescaped, err := url.PathUnescape(fragment); err == nil {
+		fragment = unescaped
+	}
+	if !lc.fragments(file)[fragment] {
+		return fmt.Sprintf("no element with id %q on %s", fragment, file)
+	}
+	return ""
+}
+
+// fragments returns the ids and anchor names of a page
+func (lc *linkChecker) fragments(page string) map[string]bool {
+	if ids, ok := lc.ids[page]; ok {
+		return ids
+	}
+	ids := make(map[string]bool)
+	if content, err := os.ReadFile(filepath.Join(lc.dir, filepath.FromSlash(page))); err == nil {
+		for _, m := range idAttrPattern.FindAllSubmatch(content, -1) {
+			ids[html.UnescapeString(string(m[1]))] = true
+		}
+	}
+	lc.ids[page] = ids
+	return ids
+}
+
+// ReportLinks prints the problems found by CheckLinks and returns an error if
+// there are any
+func ReportLinks(problems []LinkProblem) error {
+	pages := make(map[string]bool)
+	for _, p := range problems {
+		fmt.Printf("[Check] %s\n", p)
+		pages[p.Page] = true
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("found %d broken link(s) on %d page(s)", len(problems), len(pages))
+	}
+	fmt.Println("[Check] No broken links found")
+	return nil
+}
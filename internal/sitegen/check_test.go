@@ -0,0 +1,73 @@
+package sitegen
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCheckLinks(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "index.html"), "<html>\n<body>\n"+
+		`<a href="docs/guide.html#install">ok</a> <a href="docs/">dir</a> <a href="#top">top</a> <a href="https://example.com/x.md">ext</a>`+"\n"+
+		`<a href="docs/missing.html">missing</a> <a href="docs/guide.html#nope">bad fragment</a>`+"\n"+
+		`<a href="guide.md">source</a> <img src="/img/a%20b.png" srcset="/img/a%20b.png 1x, /img/big.png 2x">`+"\n"+
+		`<a href="#local">local</a> <a href="../up.html">up</a> <a href="mailto:me@example.com">mail</a>`+"\n"+
+		`<h2 id="local">Local</h2>`+"\n</body>\n</html>\n")
+	writeTestFile(t, filepath.Join(dir, "docs", "guide.html"), `<h2 id="install">Install</h2><a href="../index.html?x=1#local">home</a>`)
+	writeTestFile(t, filepath.Join(dir, "docs", "index.html"), `<a href="/docs/guide.html">guide</a>`)
+	writeTestFile(t, filepath.Join(dir, "img", "a b.png"), "png")
+
+	problems, err := CheckLinks(dir, "")
+	if err != nil {
+		t.Fatalf("CheckLinks failed: %v", err)
+	}
+	want := []LinkProblem{
+		{Page: "index.html", Line: 4, URL: "docs/missing.html", Reason: "not found"},
+		{Page: "index.html", Line: 4, URL: "docs/guide.html#nope", Reason: `no element with id "nope" on docs/guide.html`},
+		{Page: "index.html", Line: 5, URL: "guide.md", Reason: "links to a markdown source"},
+		{Page: "index.html", Line: 5, URL: "/img/big.png", Reason: "not found"},
+		{Page: "index.html", Line: 6, URL: "../up.html", Reason: "points outside of the site"},
+	}
+	if !reflect.DeepEqual(problems, want) {
+		t.Errorf("CheckLinks() =\n%v\nwant\n%v", problems, want)
+	}
+	if err := ReportLinks(problems); err == nil || !strings.Contains(err.Error(), "5 broken link(s) on 1 page(s)") {
+		t.Errorf("expected ReportLinks to fail with a summary, got %v", err)
+	}
+	if err := ReportLinks(nil); err != nil {
+		t.Errorf("expected no error without problems, got %v", err)
+	}
+}
+
+func TestCheckLinks_BasePath(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "index.html"), `<a href="/blog/about.html">about</a> <a href="/blog">home</a> <a href="/about.html">root</a>`)
+	writeTestFile(t, filepath.Join(dir, "about.html"), "About")
+
+	problems, err := CheckLinks(dir, "/blog/")
+	if err != nil {
+		t.Fatalf("CheckLinks failed: %v", err)
+	}
+	if len(problems) != 1 || problems[0].URL != "/about.html" || problems[0].Reason != "outside of the base path /blog" {
+		t.Errorf("expected only the link outside the base path to be reported, got %v", problems)
+	}
+}
+
+func TestBuild_Check(t *testing.T) {
+	inputDir := t.TempDir()
+	outputDir := t.TempDir()
+	writeTestFile(t, filepath.Join(inputDir, "index.md"), "# Home\n\n[Guide](docs/guide.md#install)\n")
+	writeTestFile(t, filepath.Join(inputDir, "docs", "guide.md"), "# Guide\n\n## Install\n\n[Home](../index.md)\n")
+
+	if err := Build(BuildOptions{InputDir: inputDir, OutputDir: outputDir, Check: true}); err != nil {
+		t.Fatalf("expected a site without broken links to pass the check, got %v", err)
+	}
+
+	writeTestFile(t, filepath.Join(inputDir, "index.md"), "# Home\n\n[Guide](docs/guide.md#setup)\n")
+	err := Build(BuildOptions{InputDir: inputDir, OutputDir: outputDir, NoIncremental: true, Check: true})
+	if err == nil || !strings.Contains(err.Error(), "1 broken link(s)") {
+		t.Errorf("expected the check to fail on a missing fragment, got %v", err)
+	}
+}
@@ -9,14 +9,27 @@ import (
 )
 
 type OutputCleaner struct {
-	outputDir string
-	rssURL    string
+	outputDir  string
+	rssURL     string
+	languages  *Languages
+	prettyURLs bool
+	generated  map[string]bool
 }
 
-func NewOutputCleaner(outputDir, rssURL string) *OutputCleaner {
+func NewOutputCleaner(outputDir, rssURL string, languages *Languages, prettyURLs bool) *OutputCleaner {
 	return &OutputCleaner{
-		outputDir: outputDir,
-		rssURL:    rssURL,
+		outputDir:  outputDir,
+		rssURL:     rssURL,
+		languages:  languages,
+		prettyURLs: prettyURLs,
+		generated:  make(map[string]bool),
+	}
+}
+
+// KeepGenerated marks output files that were generated without a matching input file
+func (oc *OutputCleaner) KeepGenerated(relPaths ...string) {
+	for _, p := range relPaths {
+		oc.generated[filepath.Clean(p)] = true
 	}
 }
 
@@ -51,8 +64,7 @@ func (oc *OutputCleaner) CleanupOrphanedFiles(fileSet *FileSet) error {
 
 func (oc *OutputCleaner) isExpectedFile(relPath string, fileSet *FileSet) bool {
 	for _, f := range fileSet.MarkdownFiles {
-		out := f[:len(f)-len(filepath.Ext(f))] + ".html"
-		if relPath == out {
+		if !isIncludeFile(f) && relPath == pageOutputPath(oc.languages, f, oc.prettyURLs) {
 			return true
 		}
 	}
@@ -63,39 +75,53 @@ func (oc *OutputCleaner) isExpectedFile(relPath string, fileSet *FileSet) bool {
 		}
 	}
 
-	// Don't clean up generated RSS feed
-	if relPath == "feed.xml" && oc.rssURL != "" {
+	if oc.generated[relPath] {
 		return true
 	}
 
+	// Don't clean up generated RSS feeds
+	if oc.rssURL != "" {
+		for _, feed := range oc.languages.feedPaths() {
+			if relPath == feed {
+				return true
+			}
+		}
+	}
+
 	return false
 }
 
 // CacheManager handles cache operations for full builds
 type CacheManager struct {
-	inputDir  string
-	outputDir string
+	inputDir   string
+	outputDir  string
+	languages  *Languages
+	prettyURLs bool
 }
 
-func NewCacheManager(inputDir, outputDir string) *CacheManager {
+func NewCacheManager(inputDir, outputDir string, languages *Languages, prettyURLs bool) *CacheManager {
 	return &CacheManager{
-		inputDir:  inputDir,
-		outputDir: outputDir,
+		inputDir:   inputDir,
+		outputDir:  outputDir,
+		languages:  languages,
+		prettyURLs: prettyURLs,
 	}
 }
 
-func (cm *CacheManager) CreateCacheFromFileSet(fileSet *FileSet) (*cacheFile, error) {
+// CreateCacheFromFileSet creates the cache of a full build from its pages and
+// assets. Headers, footers and include files have no output of their own, so
+// they are left out like in incremental builds.
+func (cm *CacheManager) CreateCacheFromFileSet(fileSet *FileSet, pages []string) (*cacheFile, error) {
 	newCache := newCache()
 
-	// Add markdown files to cache
-	for _, f := range fileSet.MarkdownFiles {
+	// Add pages to cache
+	for _, f := range pages {
 		src := filepath.Join(cm.inputDir, f)
 		mtime := int64(0)
 		if info, err := os.Stat(src); err == nil {
 			mtime = info.ModTime().Unix()
 		}
-		out := f[:len(f)-len(filepath.Ext(f))] + ".html"
-		newCache.Files[f] = cacheFileEntry{Mtime: mtime, Output: out}
+		newCache.Files[f] = cacheFileEntry{Mtime: mtime, Output: pageOutputPath(cm.languages, f, cm.prettyURLs)}
 	}
 
 	// Add asset files to cache
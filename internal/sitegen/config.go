@@ -0,0 +1,76 @@
+// config.go - Site configuration loaded from the input directory
+package sitegen
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigFileName is the name of the optional site config file in the input directory
+const ConfigFileName = "colade.yaml"
+
+// Config holds site-wide settings. Command line flags take precedence over these values.
+type Config struct {
+	Title          string                `yaml:"title"`         // site name, used for og:site_name
+	BaseURL        string                `yaml:"base_url"`      // absolute URL of the site, e.g. https://example.com/blog (defaults to --rss)
+	BasePath       string                `yaml:"base_path"`     // path the site is served from, e.g. /blog (defaults to the path of base_url)
+	RelativeURLs   bool                  `yaml:"relative_urls"` // make internal links relative to each page
+	PrettyURLs     bool                  `yaml:"pretty_urls"`   // write foo.md as foo/index.html and link to it as foo/
+	Theme          string                `yaml:"theme"`
+	Related        int                   `yaml:"related"` // number of related pages per page, negative to disable
+	HeadingAnchors bool                  `yaml:"heading_anchors"`
+	TOC            TOCConfig             `yaml:"toc"`
+	Languages      []LanguageConfig      `yaml:"languages"` // the first language is the default
+	Menus          map[string][]MenuItem `yaml:"menus"`
+	Breadcrumbs    BreadcrumbsConfig     `yaml:"breadcrumbs"`
+	SEO            SEOConfig             `yaml:"seo"`
+	Highlight      HighlightConfig       `yaml:"highlight"`
+	Mermaid        MermaidConfig         `yaml:"mermaid"`
+	Math           MathConfig            `yaml:"math"`
+	Markdown       MarkdownConfig        `yaml:"markdown"`
+	Images         ImagesConfig          `yaml:"images"`
+	SafeHTML       SafeHTMLConfig        `yaml:"safe_html"`
+}
+
+// BreadcrumbsConfig configures the breadcrumb trail of each page
+type BreadcrumbsConfig struct {
+	JSONLD bool `yaml:"json_ld"` // add a BreadcrumbList JSON-LD script to .BreadcrumbsJSONLD
+}
+
+// TOCConfig selects the heading levels listed in a page's table of contents
+type TOCConfig struct {
+	MinDepth int `yaml:"min_depth"`
+	MaxDepth int `yaml:"max_depth"`
+}
+
+// depths returns the configured heading levels, falling back to h2-h3
+func (c TOCConfig) depths() (int, int) {
+	minDepth, maxDepth := c.MinDepth, c.MaxDepth
+	if minDepth <= 0 {
+		minDepth = defaultTOCMinDepth
+	}
+	if maxDepth <= 0 {
+		maxDepth = defaultTOCMaxDepth
+	}
+	return minDepth, maxDepth
+}
+
+// LoadConfig reads colade.yaml from the input directory.
+// A missing config file is not an error and yields an empty config.
+func LoadConfig(inputDir string) (*Config, error) {
+	cfg := &Config{}
+	data, err := os.ReadFile(filepath.Join(inputDir, ConfigFileName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return cfg, nil
+		}
+		return nil, fmt.Errorf("error reading %s: %w", ConfigFileName, err)
+	}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %w", ConfigFileName, err)
+	}
+	return cfg, nil
+}
@@ -0,0 +1,172 @@
+// data.go - Data files exposed to templates as .Site.Data
+package sitegen
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// DataDirName is the directory in the input directory holding data files
+const DataDirName = "data"
+
+// LoadData reads the JSON, YAML and TOML files under data/ into a nested map
+// keyed by directory and file name, so data/team/members.yaml becomes
+// .Site.Data.team.members. It also returns the cache keys of the files and
+// subdirectories read.
+func LoadData(inputDir string) (map[string]interface{}, []string, error) {
+	data := make(map[string]interface{})
+	root := filepath.Join(inputDir, DataDirName)
+	if !isDir(root) {
+		return data, nil, nil
+	}
+
+	var files []string
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		if isHiddenFile(relPath) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() {
+			if relPath != "." {
+				files = append(files, depKey(inputDir, path))
+			}
+			return nil
+		}
+		value, ok, err := readDataFile(path)
+		if err != nil {
+			return fmt.Errorf("error reading data file '%s': %w", relPath, err)
+		}
+		if !ok {
+			return nil
+		}
+		if err := setDataValue(data, relPath, value); err != nil {
+			return err
+		}
+		files = append(files, depKey(inputDir, path))
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	sort.Strings(files)
+	return data, files, nil
+}
+
+// readDataFile decodes a data file by extension; ok is false for other files
+func readDataFile(path string) (interface{}, bool, error) {
+	var unmarshal func([]byte, interface{}) error
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		unmarshal = json.Unmarshal
+	case ".yaml", ".yml":
+		unmarshal = yaml.Unmarshal
+	case ".toml":
+		unmarshal = toml.Unmarshal
+	default:
+		return nil, false, nil
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false, err
+	}
+	var value interface{}
+	if err := unmarshal(content, &value); err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+// setDataValue stores a decoded file under its path without extension
+func setDataValue(data map[string]interface{}, relPath string, value interface{}) error {
+	parts := strings.Split(filepath.ToSlash(relPath), "/")
+	name := parts[len(parts)-1]
+	parts[len(parts)-1] = strings.TrimSuffix(name, filepath.Ext(name))
+
+	m := data
+	for _, dir := range parts[:len(parts)-1] {
+		child, ok := m[dir].(map[string]interface{})
+		if !ok {
+			if _, exists := m[dir]; exists {
+				return fmt.Errorf("data file and directory share the name '%s'", dir)
+			}
+			child = make(map[string]interface{})
+			m[dir] = child
+		}
+		m = child
+	}
+	key := parts[len(parts)-1]
+	if _, exists := m[key]; exists {
+		return fmt.Errorf("more than one data file for '%s'", strings.Join(parts, "/"))
+	}
+	m[key] = value
+	return nil
+}
+
+// SiteView is the site as seen by the templates of one page. It records
+// whether the page reads .Site.Data or .Site.Pages so that changes to them
+// rebuild it.
+type SiteView struct {
+	site      *Site
+	lang      string // language code of the page, "" for single-language sites
+	url       string // URL of the page, for active menu entries
+	usedData  bool
+	usedPages bool
+}
+
+// Pages returns every page of the site
+func (v *SiteView) Pages() []*Page {
+	if v == nil {
+		return nil
+	}
+	v.usedPages = true
+	if v.site == nil {
+		return nil
+	}
+	return v.site.Pages
+}
+
+// Data returns the contents of the data files
+func (v *SiteView) Data() map[string]interface{} {
+	if v == nil {
+		return nil
+	}
+	v.usedData = true
+	if v.site == nil {
+		return nil
+	}
+	return v.site.data
+}
+
+// Menus returns the site's menus by name, with the entries linking to the
+// page marked active
+func (v *SiteView) Menus() map[string][]MenuEntry {
+	if v == nil || v.site == nil {
+		return nil
+	}
+	return menusFor(v.site.menus, v.lang, v.url)
+}
+
+// dataDeps lists the cache keys a page reading .Site.Data depends on. The
+// data directories are included so that added files are noticed.
+func (v *SiteView) dataDeps() []string {
+	if !v.usedData || v.site == nil {
+		return nil
+	}
+	return append([]string{DataDirName}, v.site.dataFiles...)
+}
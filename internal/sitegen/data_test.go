@@ -0,0 +1,119 @@
+package sitegen
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadData(t *testing.T) {
+	inputDir := t.TempDir()
+	writeTestFile(t, filepath.Join(inputDir, "data", "links.json"), `[{"name": "Go", "url": "https://go.dev"}]`)
+	writeTestFile(t, filepath.Join(inputDir, "data", "team", "members.yaml"), "- name: Ada\n- name: Grace\n")
+	writeTestFile(t, filepath.Join(inputDir, "data", "site.toml"), "title = \"Example\"\n")
+	writeTestFile(t, filepath.Join(inputDir, "data", "notes.txt"), "ignored")
+
+	data, files, err := LoadData(inputDir)
+	if err != nil {
+		t.Fatalf("LoadData failed: %v", err)
+	}
+	want := map[string]interface{}{
+		"links": []interface{}{map[string]interface{}{"name": "Go", "url": "https://go.dev"}},
+		"team": map[string]interface{}{
+			"members": []interface{}{map[string]interface{}{"name": "Ada"}, map[string]interface{}{"name": "Grace"}},
+		},
+		"site": map[string]interface{}{"title": "Example"},
+	}
+	if !reflect.DeepEqual(data, want) {
+		t.Errorf("LoadData returned %#v, want %#v", data, want)
+	}
+	wantFiles := []string{filepath.Join("data", "links.json"), filepath.Join("data", "site.toml"), filepath.Join("data", "team"), filepath.Join("data", "team", "members.yaml")}
+	if !reflect.DeepEqual(files, wantFiles) {
+		t.Errorf("LoadData files = %v, want %v", files, wantFiles)
+	}
+
+	writeTestFile(t, filepath.Join(inputDir, "data", "links.yaml"), "[]")
+	if _, _, err := LoadData(inputDir); err == nil || !strings.Contains(err.Error(), "more than one data file for 'links'") {
+		t.Errorf("expected an error for duplicate data files, got %v", err)
+	}
+}
+
+func TestBuild_SiteData(t *testing.T) {
+	inputDir := t.TempDir()
+	outputDir := t.TempDir()
+	writeTestFile(t, filepath.Join(inputDir, "data", "team.yaml"), "- name: Ada\n- name: Grace\n")
+	writeTestFile(t, filepath.Join(inputDir, "shortcodes", "team.html"),
+		`<ul>{{ range .Site.Data.team }}<li>{{ .name }}</li>{{ end }}</ul>`)
+	writeTestFile(t, filepath.Join(inputDir, "about.md"), "# About\n\n{{< team >}}\n")
+	writeTestFile(t, filepath.Join(inputDir, "index.md"), "# Home\n")
+
+	if err := Build(BuildOptions{InputDir: inputDir, OutputDir: outputDir}); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	if out := readTestFile(t, filepath.Join(outputDir, "about.html")); !strings.Contains(out, "<ul><li>Ada</li><li>Grace</li></ul>") {
+		t.Errorf("expected team list from data file, got:\n%s", out)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "data")); !os.IsNotExist(err) {
+		t.Errorf("data files should not be copied to the output")
+	}
+
+	// A data change rebuilds the page using it but not the other pages
+	indexPath := filepath.Join(outputDir, "index.html")
+	old := time.Now().Add(-time.Hour)
+	os.Chtimes(indexPath, old, old)
+	teamPath := filepath.Join(inputDir, "data", "team.yaml")
+	writeTestFile(t, teamPath, "- name: Linus\n")
+	later := time.Now().Add(2 * time.Second)
+	os.Chtimes(teamPath, later, later)
+
+	if err := Build(BuildOptions{InputDir: inputDir, OutputDir: outputDir}); err != nil {
+		t.Fatalf("incremental Build failed: %v", err)
+	}
+	if out := readTestFile(t, filepath.Join(outputDir, "about.html")); !strings.Contains(out, "<ul><li>Linus</li></ul>") {
+		t.Errorf("expected about page to be rebuilt with new data, got:\n%s", out)
+	}
+	if info, err := os.Stat(indexPath); err != nil || info.ModTime().After(old.Add(time.Minute)) {
+		t.Errorf("expected index page, which does not use data, not to be rebuilt")
+	}
+}
+
+func TestBuild_SitePagesListing(t *testing.T) {
+	inputDir := t.TempDir()
+	outputDir := t.TempDir()
+	writeTestFile(t, filepath.Join(inputDir, "shortcodes", "pages.html"),
+		`<ul>{{ range .Site.Pages }}<li>{{ .Title }}</li>{{ end }}</ul>`)
+	writeTestFile(t, filepath.Join(inputDir, "archive.md"), "# Archive\n\n{{< pages >}}\n")
+	writeTestFile(t, filepath.Join(inputDir, "first.md"), "# First\n")
+
+	if err := Build(BuildOptions{InputDir: inputDir, OutputDir: outputDir}); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	archivePath := filepath.Join(outputDir, "archive.html")
+	if out := readTestFile(t, archivePath); !strings.Contains(out, "<li>First</li>") {
+		t.Errorf("expected the archive to list the first page, got:\n%s", out)
+	}
+
+	// Adding a page rebuilds the page listing them on an incremental build
+	writeTestFile(t, filepath.Join(inputDir, "second.md"), "# Second\n")
+	if err := Build(BuildOptions{InputDir: inputDir, OutputDir: outputDir}); err != nil {
+		t.Fatalf("incremental Build failed: %v", err)
+	}
+	if out := readTestFile(t, archivePath); !strings.Contains(out, "<li>Second</li>") {
+		t.Errorf("expected the archive to list the added page, got:\n%s", out)
+	}
+
+	// So does retitling one
+	firstPath := filepath.Join(inputDir, "first.md")
+	writeTestFile(t, firstPath, "# Renamed\n")
+	later := time.Now().Add(2 * time.Second)
+	os.Chtimes(firstPath, later, later)
+	if err := Build(BuildOptions{InputDir: inputDir, OutputDir: outputDir}); err != nil {
+		t.Fatalf("incremental Build failed: %v", err)
+	}
+	if out := readTestFile(t, archivePath); !strings.Contains(out, "<li>Renamed</li>") || strings.Contains(out, "<li>First</li>") {
+		t.Errorf("expected the archive to list the new title, got:\n%s", out)
+	}
+}
@@ -28,8 +28,8 @@ func DiscoverFiles(inputDir string) (*FileSet, error) {
 			return err
 		}
 
-		// Skip hidden files and directories
-		if isHiddenFile(relPath) {
+		// Skip hidden files and directories, and site files that are not content
+		if isHiddenFile(relPath) || isReservedPath(relPath) {
 			if info.IsDir() {
 				return filepath.SkipDir
 			}
@@ -74,6 +74,22 @@ func isHiddenFile(relPath string) bool {
 	return false
 }
 
+// reservedPaths are top-level entries in the input directory that configure
+// the build rather than being published
+var reservedPaths = map[string]bool{
+	ConfigFileName: true,
+	"templates":    true,
+	"themes":       true,
+	"shortcodes":   true,
+	DataDirName:    true,
+	I18nDirName:    true,
+}
+
+// isReservedPath checks if a path is a site config file or directory
+func isReservedPath(relPath string) bool {
+	return reservedPaths[filepath.ToSlash(relPath)]
+}
+
 // classifyFile determines if a file is markdown, asset, or should be skipped
 func classifyFile(name string) string {
 	ext := filepath.Ext(name)
@@ -9,12 +9,10 @@ import (
 	"io"
 	"os"
 	"path/filepath"
-	"regexp"
-	"strings"
 	"time"
 )
 
-//go:embed templates/*.html templates/style.css
+//go:embed templates/*.html templates/style.css templates/shortcodes/*.html
 var EmbeddedFiles embed.FS
 
 // copyFilePreserveDirs copies a file from src to dst, creating parent directories as needed.
@@ -47,33 +45,60 @@ func parseMarkdownFile(path string) ([]byte, error) {
 	return os.ReadFile(path)
 }
 
-// renderHTMLPage is a future-proof extension point for templating support.
-func renderHTMLPage(html []byte, templateOpt string, headerHTML, footerHTML []byte, meta map[string]interface{}) []byte {
-	// Determine template path
-	var templatePath string
-	if templateOpt != "" {
-		if _, err := os.Stat(templateOpt); err == nil {
-			templatePath = templateOpt
-		} else if filepath.IsAbs(templateOpt) || filepath.Ext(templateOpt) == ".html" {
-			templatePath = templateOpt
-		} else {
-			templatePath = "templates/" + templateOpt + ".html"
+// PageData is the data passed to page templates
+type PageData struct {
+	Content           template.HTML
+	Meta              map[string]interface{}
+	HeaderHTML        template.HTML
+	FooterHTML        template.HTML
+	Title             string
+	Date              string
+	Tags              []interface{}
+	TOC               template.HTML // nested list of the page's headings, empty if disabled
+	Summary           template.HTML // text before <!--more-->, frontmatter summary or first paragraph
+	WordCount         int
+	ReadingTime       int               // estimated minutes to read
+	Site              *SiteView         // .Site.Pages and .Site.Data
+	Page              *Page             // this page, nil if the site was not loaded
+	Language          *LanguageConfig   // nil unless the site has languages configured
+	Translations      []*Page           // this page in the other languages
+	I18n              map[string]string // translated strings for the page's language
+	Breadcrumbs       []Breadcrumb      // from the site root to this page
+	BreadcrumbsJSONLD template.HTML     // BreadcrumbList script, empty unless enabled in the config
+	SEO               template.HTML     // description, canonical, Open Graph, Twitter card and Article JSON-LD tags
+	Prev              *Page             // previous page by date in the same section
+	Next              *Page             // next page by date in the same section
+	Related           []*Page           // pages sharing the most tags
+	Backlinks         []*Page           // pages linking here with [[wiki links]]
+}
+
+// dateFormats are the frontmatter date layouts we accept
+var dateFormats = []string{
+	"2006-01-02",      // ISO
+	"02/01/2006",      // UK/EU
+	"01/02/2006",      // US
+	"02 Jan 2006",     // 07 Aug 2025
+	"2 January 2006",  // 7 August 2025
+	"January 2, 2006", // August 7, 2025
+}
+
+// parseDate parses a frontmatter date given as a string or time.Time
+func parseDate(v interface{}) (time.Time, bool) {
+	switch v := v.(type) {
+	case string:
+		for _, f := range dateFormats {
+			if t, err := time.Parse(f, v); err == nil {
+				return t, true
+			}
 		}
-	} else {
-		templatePath = "templates/default.html"
-	}
-	var tmpl *template.Template
-	var err error
-	if filepath.IsAbs(templatePath) || fileExists(templatePath) {
-		tmpl, err = template.ParseFiles(templatePath)
-	} else {
-		tmpl, err = template.ParseFS(EmbeddedFiles, templatePath)
-	}
-	if err != nil {
-		return html
+	case time.Time:
+		return v, true
 	}
+	return time.Time{}, false
+}
 
-	// Flatten common meta fields for easier template access
+// newPageData flattens common meta fields for easier template access
+func newPageData(html, headerHTML, footerHTML []byte, meta map[string]interface{}) PageData {
 	var title, date string
 	var tags []interface{}
 	if meta != nil {
@@ -84,25 +109,7 @@ func renderHTMLPage(html []byte, templateOpt string, headerHTML, footerHTML []by
 		switch v := meta["date"].(type) {
 		case string:
 			fmt.Printf("[DEBUG] meta[\"date\"] = %q\n", v)
-			fmt.Printf("[DEBUG] meta = %#v\n", meta)
-			formats := []string{
-				"2006-01-02",      // ISO
-				"02/01/2006",      // UK/EU
-				"01/02/2006",      // US
-				"02 Jan 2006",     // 07 Aug 2025
-				"2 January 2006",  // 7 August 2025
-				"January 2, 2006", // August 7, 2025
-			}
-			var parsed time.Time
-			for _, f := range formats {
-				t, err := time.Parse(f, v)
-				if err == nil {
-					fmt.Printf("[DEBUG] Parsed date %q with format %q\n", v, f)
-					parsed = t
-					break
-				}
-			}
-			if !parsed.IsZero() {
+			if parsed, ok := parseDate(v); ok {
 				date = parsed.Format("02 Jan 2006")
 			} else {
 				fmt.Printf("[DEBUG] Could not parse date %q, using as-is\n", v)
@@ -117,15 +124,7 @@ func renderHTMLPage(html []byte, templateOpt string, headerHTML, footerHTML []by
 		}
 	}
 
-	data := struct {
-		Content    template.HTML
-		Meta       map[string]interface{}
-		HeaderHTML template.HTML
-		FooterHTML template.HTML
-		Title      string
-		Date       string
-		Tags       []interface{}
-	}{
+	return PageData{
 		Content:    template.HTML(html),
 		Meta:       meta,
 		HeaderHTML: template.HTML(headerHTML),
@@ -134,44 +133,25 @@ func renderHTMLPage(html []byte, templateOpt string, headerHTML, footerHTML []by
 		Date:       date,
 		Tags:       tags,
 	}
+}
+
+// renderHTMLPage is a future-proof extension point for templating support.
+// Without a usable layout the rendered markdown is returned as-is.
+func renderHTMLPage(tmpl *template.Template, data PageData) []byte {
+	if tmpl == nil {
+		return []byte(data.Content)
+	}
 
 	// DEBUG: Print the final date value passed to the template
 	fmt.Printf("[DEBUG] FINAL data.Date = %q for title %q\n", data.Date, data.Title)
 
 	var buf bytes.Buffer
 	if err := tmpl.Execute(&buf, data); err != nil {
-		return html
+		return []byte(data.Content)
 	}
 	return buf.Bytes()
 }
 
-// SimpleMarkdownToHTML provides a minimal Markdown-to-HTML conversion for headers/footers.
-func SimpleMarkdownToHTML(md []byte) []byte {
-	// Only handle headings, links, emphasis, and lists for minimalism.
-	s := string(md)
-	s = strings.ReplaceAll(s, "\r\n", "\n")
-	lines := strings.Split(s, "\n")
-	var htmlLines []string
-	for _, line := range lines {
-		switch {
-		case strings.HasPrefix(line, "# "):
-			htmlLines = append(htmlLines, "<h1>"+line[2:]+"</h1>")
-		case strings.HasPrefix(line, "## "):
-			htmlLines = append(htmlLines, "<h2>"+line[3:]+"</h2>")
-		case strings.HasPrefix(line, "- "):
-			htmlLines = append(htmlLines, "<li>"+line[2:]+"</li>")
-		default:
-			htmlLines = append(htmlLines, line)
-		}
-	}
-	html := strings.Join(htmlLines, "\n")
-	// Replace [text](url) with <a href="url">text</a>
-	html = regexp.MustCompile(`\[([^\]]+)\]\(([^)]+)\)`).ReplaceAllString(html, `<a href="$2">$1</a>`)
-	html = strings.ReplaceAll(html, "*", "<em>")
-	html = strings.ReplaceAll(html, "_", "<em>")
-	return []byte(html)
-}
-
 // fileExists checks if a file exists on disk
 func fileExists(path string) bool {
 	info, err := os.Stat(path)
@@ -7,6 +7,7 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestHeaderFooterInjection(t *testing.T) {
@@ -95,8 +96,8 @@ func TestHeaderFooterInvalidMarkdown(t *testing.T) {
 	}
 	html := string(htmlBytes)
 
-	// Should still include the invalid content as raw HTML
-	if !strings.Contains(html, "<<<<<") && !strings.Contains(html, ">>>>>") {
+	// Should still include the invalid content, escaped as text
+	if !strings.Contains(html, "&lt;&lt;&lt;&lt;&lt;") {
 		t.Errorf("Invalid markdown in header/footer not rendered as HTML")
 	}
 }
@@ -128,3 +129,175 @@ func TestHeaderFooterDisabledFlags(t *testing.T) {
 		t.Errorf("Header/footer should not be present when disabled by flags")
 	}
 }
+
+func TestHeaderFooterFullMarkdown(t *testing.T) {
+	tmpDir := t.TempDir()
+	inputDir := filepath.Join(tmpDir, "input")
+	outputDir := filepath.Join(tmpDir, "output")
+	os.MkdirAll(inputDir, 0755)
+
+	headerContent := "---\ntitle: Site header\n---\n# Site\n\n- [Docs](docs/getting_started.md)\n- [Blog](/blog/)\n"
+	footerContent := "Source on [GitHub](https://example.com/my_repo/some_file) *thanks*\n"
+	os.WriteFile(filepath.Join(inputDir, "header.md"), []byte(headerContent), 0644)
+	os.WriteFile(filepath.Join(inputDir, "footer.md"), []byte(footerContent), 0644)
+	os.WriteFile(filepath.Join(inputDir, "index.md"), []byte("# Main"), 0644)
+
+	err := BuildSite(inputDir, outputDir, 14*1024, false, "", 20, false, "default", "", "", false, false, "")
+	if err != nil {
+		t.Fatalf("BuildSite failed: %v", err)
+	}
+	htmlBytes, err := os.ReadFile(filepath.Join(outputDir, "index.html"))
+	if err != nil {
+		t.Fatalf("Failed to read generated HTML: %v", err)
+	}
+	html := string(htmlBytes)
+
+	for _, want := range []string{
+		"<ul>\n<li><a href=\"/docs/getting_started.html\">Docs</a></li>",
+		`<a href="https://example.com/my_repo/some_file">GitHub</a>`,
+		"<em>thanks</em>",
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("expected %q in output HTML, got:\n%s", want, html)
+		}
+	}
+	if strings.Contains(html, "title: Site header") {
+		t.Errorf("header frontmatter should not be rendered")
+	}
+}
+
+func TestHeaderFooterDirectoryOverrides(t *testing.T) {
+	tmpDir := t.TempDir()
+	inputDir := filepath.Join(tmpDir, "input")
+	outputDir := filepath.Join(tmpDir, "output")
+	os.MkdirAll(filepath.Join(inputDir, "docs", "guide"), 0755)
+
+	os.WriteFile(filepath.Join(inputDir, "header.md"), []byte("Root header"), 0644)
+	os.WriteFile(filepath.Join(inputDir, "footer.md"), []byte("Root footer"), 0644)
+	os.WriteFile(filepath.Join(inputDir, "docs", "header.md"), []byte("Docs header"), 0644)
+	os.WriteFile(filepath.Join(inputDir, "index.md"), []byte("# Home"), 0644)
+	os.WriteFile(filepath.Join(inputDir, "docs", "index.md"), []byte("# Docs"), 0644)
+	os.WriteFile(filepath.Join(inputDir, "docs", "guide", "intro.md"), []byte("# Intro"), 0644)
+
+	if err := BuildSite(inputDir, outputDir, 14*1024, false, "", 20, false, "default", "", "", false, false, ""); err != nil {
+		t.Fatalf("BuildSite failed: %v", err)
+	}
+
+	checks := map[string][]string{
+		"index.html":            {"Root header", "Root footer"},
+		"docs/index.html":       {"Docs header", "Root footer"},
+		"docs/guide/intro.html": {"Docs header", "Root footer"},
+	}
+	for page, wants := range checks {
+		htmlBytes, err := os.ReadFile(filepath.Join(outputDir, page))
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", page, err)
+		}
+		for _, want := range wants {
+			if !strings.Contains(string(htmlBytes), want) {
+				t.Errorf("%s: expected %q", page, want)
+			}
+		}
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "docs", "header.html")); err == nil {
+		t.Errorf("directory header should not be rendered as a page")
+	}
+
+	// Editing a directory header rebuilds only the pages below it in an incremental build
+	docsHeader := filepath.Join(inputDir, "docs", "header.md")
+	os.WriteFile(docsHeader, []byte("Updated docs header"), 0644)
+	later := time.Now().Add(2 * time.Second)
+	os.Chtimes(docsHeader, later, later)
+	if err := BuildSite(inputDir, outputDir, 14*1024, false, "", 20, false, "default", "", "", false, false, ""); err != nil {
+		t.Fatalf("incremental BuildSite failed: %v", err)
+	}
+	htmlBytes, err := os.ReadFile(filepath.Join(outputDir, "docs", "guide", "intro.html"))
+	if err != nil {
+		t.Fatalf("Failed to read intro.html: %v", err)
+	}
+	if !strings.Contains(string(htmlBytes), "Updated docs header") {
+		t.Errorf("expected page to be rebuilt after its header changed, got:\n%s", htmlBytes)
+	}
+}
+
+func TestHeaderFooterNotInFeed(t *testing.T) {
+	inputDir := t.TempDir()
+	outputDir := t.TempDir()
+	writeTestFile(t, filepath.Join(inputDir, "header.md"), "# Header")
+	writeTestFile(t, filepath.Join(inputDir, "docs", "footer.md"), "# Docs footer")
+	writeTestFile(t, filepath.Join(inputDir, "docs", "intro.md"), "# Intro")
+
+	if err := Build(BuildOptions{InputDir: inputDir, OutputDir: outputDir, RSSURL: "https://example.com"}); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	feed := readTestFile(t, filepath.Join(outputDir, "feed.xml"))
+	if !strings.Contains(feed, "docs/intro.html") {
+		t.Errorf("expected the page in the feed, got:\n%s", feed)
+	}
+	for _, partial := range []string{"header.html", "footer.html"} {
+		if strings.Contains(feed, partial) {
+			t.Errorf("expected %s to be left out of the feed, got:\n%s", partial, feed)
+		}
+	}
+}
+
+func TestHeaderFooterLinksOnNestedPages(t *testing.T) {
+	inputDir := t.TempDir()
+	outputDir := t.TempDir()
+	writeTestFile(t, filepath.Join(inputDir, "header.md"), "[Docs](docs/index.md) [Blog](blog/post.html) ![Logo](img/logo.png)")
+	writeTestFile(t, filepath.Join(inputDir, "docs", "footer.md"), "[Intro](guide/intro.md#intro) [Top](#top)")
+	writeTestFile(t, filepath.Join(inputDir, "img", "logo.png"), "png")
+	writeTestFile(t, filepath.Join(inputDir, "index.md"), "# Home")
+	writeTestFile(t, filepath.Join(inputDir, "blog", "post.md"), "# Post")
+	writeTestFile(t, filepath.Join(inputDir, "docs", "index.md"), "# Docs")
+	writeTestFile(t, filepath.Join(inputDir, "docs", "guide", "intro.md"), "# Intro")
+
+	if err := Build(BuildOptions{InputDir: inputDir, OutputDir: outputDir, Check: true}); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	out := readTestFile(t, filepath.Join(outputDir, "docs", "guide", "intro.html"))
+	for _, want := range []string{
+		`<a href="/docs/index.html">Docs</a>`,
+		`<a href="/blog/post.html">Blog</a>`,
+		`<img src="/img/logo.png" alt="Logo">`,
+		`<a href="/docs/guide/intro.html#intro">Intro</a>`,
+		`<a href="#top">Top</a>`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected intro.html to contain %q, got:\n%s", want, out)
+		}
+	}
+
+	// Root-relative partial links follow the base path and --relative-urls
+	if err := Build(BuildOptions{InputDir: inputDir, OutputDir: outputDir, NoIncremental: true, RelativeURLs: true}); err != nil {
+		t.Fatalf("Build with relative URLs failed: %v", err)
+	}
+	if out := readTestFile(t, filepath.Join(outputDir, "blog", "post.html")); !strings.Contains(out, `<a href="../docs/index.html">Docs</a>`) {
+		t.Errorf("expected a relative header link on blog/post.html, got:\n%s", out)
+	}
+}
+
+func TestHeaderFooterNotCachedAsPages(t *testing.T) {
+	inputDir := t.TempDir()
+	outputDir := t.TempDir()
+	writeTestFile(t, filepath.Join(inputDir, "header.md"), "# Header")
+	writeTestFile(t, filepath.Join(inputDir, "docs", "footer.md"), "# Docs footer")
+	writeTestFile(t, filepath.Join(inputDir, "_note.md"), "A note")
+	writeTestFile(t, filepath.Join(inputDir, "docs", "intro.md"), "# Intro\n\n{{< include \"../_note.md\" >}}\n")
+
+	if err := Build(BuildOptions{InputDir: inputDir, OutputDir: outputDir}); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	cache, err := loadCache(getCachePath(outputDir))
+	if err != nil {
+		t.Fatalf("loadCache failed: %v", err)
+	}
+	if _, ok := cache.Files[filepath.Join("docs", "intro.md")]; !ok {
+		t.Errorf("expected the page in the cache, got %v", cache.Files)
+	}
+	for _, partial := range []string{"header.md", filepath.Join("docs", "footer.md"), "_note.md"} {
+		if _, ok := cache.Files[partial]; ok {
+			t.Errorf("expected %s to be left out of the cache pages", partial)
+		}
+	}
+}
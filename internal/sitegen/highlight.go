@@ -0,0 +1,230 @@
+// highlight.go - Build-time syntax highlighting of fenced code blocks with chroma
+package sitegen
+
+import (
+	"bytes"
+	"fmt"
+	stdhtml "html"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// DefaultHighlightStyle is the chroma style used by gen-css when none is given
+const DefaultHighlightStyle = "github"
+
+// HighlightConfig configures syntax highlighting of fenced code blocks
+type HighlightConfig struct {
+	Disabled    bool `yaml:"disabled"`     // render code blocks as plain <pre><code>
+	LineNumbers bool `yaml:"line_numbers"` // number the lines of every code block
+}
+
+// highlighting is a goldmark extension rendering fenced code blocks with a
+// known language as chroma spans with CSS classes, see HighlightCSS
+type highlighting struct {
+	config HighlightConfig
+}
+
+func (e *highlighting) Extend(m goldmark.Markdown) {
+	m.Renderer().AddOptions(renderer.WithNodeRenderers(
+		util.Prioritized(&highlightRenderer{config: e.config, Config: html.NewConfig()}, 200),
+	))
+}
+
+// highlightRenderer replaces goldmark's fenced code block renderer
+type highlightRenderer struct {
+	html.Config
+	config HighlightConfig
+}
+
+func (r *highlightRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(ast.KindFencedCodeBlock, r.renderFencedCodeBlock)
+}
+
+func (r *highlightRenderer) renderFencedCodeBlock(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	n := node.(*ast.FencedCodeBlock)
+	var code bytes.Buffer
+	for i := 0; i < n.Lines().Len(); i++ {
+		line := n.Lines().At(i)
+		code.Write(line.Value(source))
+	}
+	var lang, info []byte
+	if n.Info != nil {
+		info = n.Info.Segment.Value(source)
+		lang = n.Language(source)
+	}
+
+	lexer := lexers.Get(string(lang))
+	if lexer == nil || r.config.Disabled {
+		// Unknown languages render as goldmark does
+		_, _ = w.WriteString("<pre><code")
+		if lang != nil {
+			_, _ = w.WriteString(` class="language-`)
+			r.Writer.Write(w, lang)
+			_ = w.WriteByte('"')
+		}
+		_ = w.WriteByte('>')
+		r.Writer.RawWrite(w, code.Bytes())
+		_, _ = w.WriteString("</code></pre>\n")
+		return ast.WalkSkipChildren, nil
+	}
+
+	opts := append(fenceOptions(info, r.config), chromahtml.WithPreWrapper(codeWrapper(lang)))
+	iterator, err := chroma.Coalesce(lexer).Tokenise(nil, code.String())
+	if err != nil {
+		return ast.WalkStop, fmt.Errorf("failed to highlight %s code block: %w", lang, err)
+	}
+	if err := chromahtml.New(opts...).Format(w, styles.Fallback, iterator); err != nil {
+		return ast.WalkStop, err
+	}
+	_ = w.WriteByte('\n')
+	return ast.WalkSkipChildren, nil
+}
+
+// codeWrapper keeps the language-* class goldmark puts on code blocks
+type codeWrapper string
+
+func (lang codeWrapper) Start(code bool, styleAttr string) string {
+	if !code {
+		return "<pre" + styleAttr + ">"
+	}
+	return "<pre" + styleAttr + `><code class="language-` + stdhtml.EscapeString(string(lang)) + `">`
+}
+
+func (lang codeWrapper) End(code bool) string {
+	if !code {
+		return "</pre>"
+	}
+	return "</code></pre>"
+}
+
+// fenceOptions reads the attributes after the language of a fence, e.g.
+// ```go {linenos=true linenostart=10 hl_lines=[2,"4-6"]}
+// hl_lines counts from the first line of the block, whatever linenostart is.
+func fenceOptions(info []byte, cfg HighlightConfig) []chromahtml.Option {
+	opts := []chromahtml.Option{chromahtml.WithClasses(true), chromahtml.WithLineNumbers(cfg.LineNumbers)}
+	i := bytes.IndexByte(info, '{')
+	if i < 0 {
+		return opts
+	}
+	attrs, ok := parser.ParseAttributes(text.NewReader(info[i:]))
+	if !ok {
+		return opts
+	}
+	start := 1
+	var lines [][2]int
+	for _, attr := range attrs {
+		switch string(attr.Name) {
+		case "linenos":
+			opts = append(opts, chromahtml.WithLineNumbers(attrBool(attr.Value)))
+		case "linenostart":
+			if n, ok := attrInt(attr.Value); ok {
+				start = n
+			}
+		case "hl_lines":
+			lines = lineRanges(attr.Value)
+		}
+	}
+	for i := range lines {
+		lines[i][0] += start - 1
+		lines[i][1] += start - 1
+	}
+	return append(opts, chromahtml.BaseLineNumber(start), chromahtml.HighlightLines(lines))
+}
+
+func attrBool(v interface{}) bool {
+	switch v := v.(type) {
+	case []byte:
+		b, _ := strconv.ParseBool(string(v))
+		return b
+	case float64:
+		return v != 0
+	case bool:
+		return v
+	}
+	return false
+}
+
+func attrInt(v interface{}) (int, bool) {
+	switch v := v.(type) {
+	case float64:
+		return int(v), true
+	case []byte:
+		n, err := strconv.Atoi(string(v))
+		return n, err == nil
+	}
+	return 0, false
+}
+
+// lineRanges reads hl_lines: a list of line numbers and "from-to" ranges, or
+// a string of them separated by spaces
+func lineRanges(v interface{}) [][2]int {
+	var items []interface{}
+	switch v := v.(type) {
+	case []interface{}:
+		items = v
+	case []byte:
+		for _, f := range strings.Fields(string(v)) {
+			items = append(items, []byte(f))
+		}
+	default:
+		items = []interface{}{v}
+	}
+	var ranges [][2]int
+	for _, item := range items {
+		if n, ok := item.(float64); ok {
+			ranges = append(ranges, [2]int{int(n), int(n)})
+			continue
+		}
+		s, ok := item.([]byte)
+		if !ok {
+			continue
+		}
+		from, to, isRange := strings.Cut(string(s), "-")
+		a, err := strconv.Atoi(from)
+		if err != nil {
+			continue
+		}
+		b := a
+		if isRange {
+			if b, err = strconv.Atoi(to); err != nil {
+				continue
+			}
+		}
+		ranges = append(ranges, [2]int{a, b})
+	}
+	return ranges
+}
+
+// HighlightStyles returns the names of the available chroma styles
+func HighlightStyles() []string {
+	names := styles.Names()
+	sort.Strings(names)
+	return names
+}
+
+// HighlightCSS writes the stylesheet for highlighted code blocks in a chroma style
+func HighlightCSS(w io.Writer, style string) error {
+	for _, name := range styles.Names() {
+		if strings.EqualFold(name, style) {
+			return chromahtml.New(chromahtml.WithClasses(true)).WriteCSS(w, styles.Get(name))
+		}
+	}
+	return fmt.Errorf("unknown style %q, use --list to see the available styles", style)
+}
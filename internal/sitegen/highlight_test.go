@@ -0,0 +1,92 @@
+package sitegen
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHighlight_FencedCodeBlocks(t *testing.T) {
+	mp := NewMarkdownProcessor("")
+	src := "```go\nfunc main() {}\n```\n\n```\nplain <x>\n```\n\n```nosuchlang\na<b\n```\n"
+	out, _, err := mp.renderMarkdown([]byte(src))
+	if err != nil {
+		t.Fatalf("renderMarkdown failed: %v", err)
+	}
+	html := string(out)
+	for _, want := range []string{
+		`<pre class="chroma"><code class="language-go">`,
+		`<span class="kd">func</span>`,
+		`<span class="nf">main</span>`,
+		"<pre><code>plain &lt;x&gt;\n</code></pre>",
+		"<pre><code class=\"language-nosuchlang\">a&lt;b\n</code></pre>",
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, html)
+		}
+	}
+	if strings.Contains(html, "style=") {
+		t.Errorf("expected class-based highlighting without inline styles, got:\n%s", html)
+	}
+}
+
+func TestHighlight_FenceAttributes(t *testing.T) {
+	mp := NewMarkdownProcessor("")
+	src := "```python {linenos=true linenostart=10 hl_lines=[2]}\na = 1\nb = 2\nc = 3\n```\n"
+	out, _, _ := mp.renderMarkdown([]byte(src))
+	html := string(out)
+	for _, want := range []string{`<span class="ln">10</span>`, `<span class="line hl"><span class="ln">11</span>`} {
+		if !strings.Contains(html, want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, html)
+		}
+	}
+	if strings.Count(html, "line hl") != 1 {
+		t.Errorf("expected exactly one highlighted line, got:\n%s", html)
+	}
+}
+
+func TestHighlight_Config(t *testing.T) {
+	src := []byte("```go\nx := 1\n```\n")
+	out, _, _ := NewMarkdownProcessorWithConfig("", &Config{Highlight: HighlightConfig{Disabled: true}}).renderMarkdown(src)
+	if want := "<pre><code class=\"language-go\">x := 1\n</code></pre>\n"; string(out) != want {
+		t.Errorf("expected plain code with highlighting disabled, got %q", out)
+	}
+	out, _, _ = NewMarkdownProcessorWithConfig("", &Config{Highlight: HighlightConfig{LineNumbers: true}}).renderMarkdown(src)
+	if !strings.Contains(string(out), `<span class="ln">1</span>`) {
+		t.Errorf("expected line numbers, got %s", out)
+	}
+}
+
+func TestLineRanges(t *testing.T) {
+	tests := []struct {
+		in   interface{}
+		want [][2]int
+	}{
+		{[]interface{}{float64(2), float64(3)}, [][2]int{{2, 2}, {3, 3}}},
+		{[]interface{}{[]byte("4-6"), float64(9)}, [][2]int{{4, 6}, {9, 9}}},
+		{[]byte("1 3-4"), [][2]int{{1, 1}, {3, 4}}},
+		{float64(7), [][2]int{{7, 7}}},
+	}
+	for _, tt := range tests {
+		if got := lineRanges(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("lineRanges(%v) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestHighlightCSS(t *testing.T) {
+	var buf bytes.Buffer
+	if err := HighlightCSS(&buf, "Monokai"); err != nil {
+		t.Fatalf("HighlightCSS failed: %v", err)
+	}
+	if !strings.Contains(buf.String(), ".chroma .kd") {
+		t.Errorf("expected keyword rules in the stylesheet, got:\n%s", buf.String())
+	}
+	if err := HighlightCSS(&buf, "no-such-style"); err == nil {
+		t.Errorf("expected an unknown style to fail")
+	}
+	if !strings.Contains(strings.Join(HighlightStyles(), " "), DefaultHighlightStyle) {
+		t.Errorf("expected %q among the styles", DefaultHighlightStyle)
+	}
+}
@@ -0,0 +1,394 @@
+// images.go - Resized variants of JPEG, PNG and GIF images and responsive <img> attributes
+package sitegen
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+	"golang.org/x/image/draw"
+)
+
+const (
+	defaultImageQuality = 80
+	defaultImageSizes   = "100vw"
+)
+
+// responsiveImageKey holds the function looking up the images of the page
+// being parsed, see MarkdownProcessor.responsiveImage
+var responsiveImageKey = parser.NewContextKey()
+
+// imageSourceAttr holds the source path of a processed image on its node, so
+// that the page can depend on it. It is not rendered.
+var imageSourceAttr = []byte("colade-image-source")
+
+// ImagesConfig configures the resized variants generated for JPEG, PNG and GIF images
+type ImagesConfig struct {
+	Widths  []int  `yaml:"widths"`  // widths in pixels of the variants, none to copy images as they are
+	Sizes   string `yaml:"sizes"`   // sizes attribute of <img> tags, 100vw by default
+	Quality int    `yaml:"quality"` // JPEG quality from 1 to 100, 80 by default
+}
+
+// enabled reports whether images are processed at all
+func (c ImagesConfig) enabled() bool {
+	return len(c.Widths) > 0
+}
+
+func (c ImagesConfig) quality() int {
+	if c.Quality <= 0 || c.Quality > 100 {
+		return defaultImageQuality
+	}
+	return c.Quality
+}
+
+func (c ImagesConfig) sizes() string {
+	if c.Sizes == "" {
+		return defaultImageSizes
+	}
+	return c.Sizes
+}
+
+// imageVariant is a resized copy of an image
+type imageVariant struct {
+	Width int    `json:"width"`
+	Path  string `json:"path"` // output path, relative to the output directory
+}
+
+// imageInfo describes a processed image, and is its entry in the build cache
+type imageInfo struct {
+	Hash     string         `json:"hash"` // of the source and the JPEG quality
+	Width    int            `json:"width"`
+	Height   int            `json:"height"`
+	Variants []imageVariant `json:"variants,omitempty"`
+}
+
+// ImageSet holds the processed images of a site by source path
+type ImageSet struct {
+	config ImagesConfig
+	images map[string]imageInfo
+}
+
+// isResizableImage reports whether an asset is an image variants are made of
+func isResizableImage(relPath string) bool {
+	switch strings.ToLower(filepath.Ext(relPath)) {
+	case ".jpg", ".jpeg", ".png", ".gif":
+		return true
+	}
+	return false
+}
+
+// variantPath returns the path of the variant of an image at the given
+// width: hero.jpg becomes hero-480w.jpg
+func variantPath(p string, width int) string {
+	ext := path.Ext(p)
+	return strings.TrimSuffix(p, ext) + "-" + strconv.Itoa(width) + "w" + ext
+}
+
+// ProcessImages generates the configured variants of the JPEG, PNG and GIF
+// assets of a site into outputDir. Images whose source hash matches the
+// previous build's cache are not resized again, and variants that are no
+// longer needed are removed.
+func ProcessImages(cfg ImagesConfig, inputDir, outputDir string, fileSet *FileSet, prev *cacheFile) (*ImageSet, error) {
+	set := &ImageSet{config: cfg, images: make(map[string]imageInfo)}
+	if cfg.enabled() {
+		assets := make(map[string]bool, len(fileSet.AssetFiles))
+		for _, f := range fileSet.AssetFiles {
+			assets[filepath.ToSlash(f)] = true
+		}
+		widths := slices.Clone(cfg.Widths)
+		slices.Sort(widths)
+		widths = slices.Compact(widths)
+		for _, relPath := range fileSet.AssetFiles {
+			if !isResizableImage(relPath) || assets[variantSource(filepath.ToSlash(relPath), widths)] {
+				continue
+			}
+			info, err := set.process(inputDir, outputDir, relPath, widths, assets, prev)
+			if err != nil {
+				return nil, err
+			}
+			if info != nil {
+				set.images[relPath] = *info
+			}
+		}
+	}
+
+	// Remove the variants of images that were removed, changed or shrunk
+	if prev != nil {
+		keep := make(map[string]bool)
+		for _, p := range set.outputs() {
+			keep[p] = true
+		}
+		for _, info := range prev.Images {
+			for _, v := range info.Variants {
+				if !keep[v.Path] {
+					fmt.Printf("[Image] Removing unused variant %s\n", v.Path)
+					os.Remove(filepath.Join(outputDir, filepath.FromSlash(v.Path)))
+				}
+			}
+		}
+	}
+	return set, nil
+}
+
+// variantSource returns the image an asset would be a variant of, so that
+// variants copied into the input directory are not resized again, or ""
+func variantSource(relPath string, widths []int) string {
+	for _, w := range widths {
+		suffix := "-" + strconv.Itoa(w) + "w"
+		ext := path.Ext(relPath)
+		if base, ok := strings.CutSuffix(strings.TrimSuffix(relPath, ext), suffix); ok {
+			return base + ext
+		}
+	}
+	return ""
+}
+
+// process generates the variants of one image narrower than the original,
+// reusing the cached ones if the image did not change. Images that cannot be
+// decoded are only copied, and return nil.
+func (s *ImageSet) process(inputDir, outputDir, relPath string, widths []int, assets map[string]bool, prev *cacheFile) (*imageInfo, error) {
+	data, err := os.ReadFile(filepath.Join(inputDir, relPath))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image '%s': %w", relPath, err)
+	}
+	h := sha256.New()
+	h.Write(data)
+	fmt.Fprintf(h, "\x00%d", s.config.quality())
+	info := &imageInfo{Hash: hex.EncodeToString(h.Sum(nil))}
+
+	imgCfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		fmt.Printf("[Image] Warning: %s: %v, copying as is\n", relPath, err)
+		return nil, nil
+	}
+	info.Width, info.Height = imgCfg.Width, imgCfg.Height
+	if format == "gif" && animatedGIF(data) {
+		// Resizing would drop all but the first frame
+		return info, nil
+	}
+	for _, w := range widths {
+		p := variantPath(filepath.ToSlash(relPath), w)
+		if w > 0 && w < info.Width && !assets[p] {
+			info.Variants = append(info.Variants, imageVariant{Width: w, Path: p})
+		}
+	}
+	if len(info.Variants) == 0 {
+		return info, nil
+	}
+
+	if prev != nil {
+		if cached, ok := prev.Images[relPath]; ok && cached.Hash == info.Hash && slices.Equal(cached.Variants, info.Variants) && variantsExist(outputDir, info.Variants) {
+			fmt.Printf("[Image] %s unchanged, skipping\n", relPath)
+			return info, nil
+		}
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		fmt.Printf("[Image] Warning: %s: %v, copying as is\n", relPath, err)
+		return nil, nil
+	}
+	for _, v := range info.Variants {
+		dst := filepath.Join(outputDir, filepath.FromSlash(v.Path))
+		if err := writeImage(dst, resizeImage(img, v.Width), format, s.config.quality()); err != nil {
+			return nil, fmt.Errorf("failed to write image variant '%s': %w", v.Path, err)
+		}
+	}
+	fmt.Printf("[Image] %s -> %d variant(s)\n", relPath, len(info.Variants))
+	return info, nil
+}
+
+// outputs lists the output paths of all variants
+func (s *ImageSet) outputs() []string {
+	var paths []string
+	for _, info := range s.images {
+		for _, v := range info.Variants {
+			paths = append(paths, v.Path)
+		}
+	}
+	return paths
+}
+
+// cacheEntries returns the images to record in the build cache
+func (s *ImageSet) cacheEntries() map[string]imageInfo {
+	if s == nil || len(s.images) == 0 {
+		return nil
+	}
+	return s.images
+}
+
+func variantsExist(outputDir string, variants []imageVariant) bool {
+	for _, v := range variants {
+		if _, err := os.Stat(filepath.Join(outputDir, filepath.FromSlash(v.Path))); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// animatedGIF reports whether a GIF has more than one frame
+func animatedGIF(data []byte) bool {
+	g, err := gif.DecodeAll(bytes.NewReader(data))
+	return err == nil && len(g.Image) > 1
+}
+
+// resizeImage scales an image to the given width, keeping its aspect ratio.
+// Paletted images keep their palette.
+func resizeImage(img image.Image, width int) image.Image {
+	b := img.Bounds()
+	height := max(1, (b.Dy()*width+b.Dx()/2)/b.Dx())
+	rect := image.Rect(0, 0, width, height)
+	var dst draw.Image
+	if p, ok := img.(*image.Paletted); ok {
+		dst = image.NewPaletted(rect, p.Palette)
+	} else {
+		dst = image.NewRGBA(rect)
+	}
+	draw.CatmullRom.Scale(dst, rect, img, b, draw.Src, nil)
+	return dst
+}
+
+// writeImage encodes an image in the format of its source
+func writeImage(dst string, img image.Image, format string, quality int) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if err := encodeImage(f, img, format, quality); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func encodeImage(w io.Writer, img image.Image, format string, quality int) error {
+	switch format {
+	case "jpeg":
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: quality})
+	case "png":
+		enc := png.Encoder{CompressionLevel: png.BestCompression}
+		return enc.Encode(w, img)
+	case "gif":
+		return gif.Encode(w, img, nil)
+	}
+	return fmt.Errorf("unsupported image format %q", format)
+}
+
+// responsiveImage holds the attributes added to the <img> tag of a processed image
+type responsiveImage struct {
+	source string // source path of the image
+	width  int
+	height int
+	srcset string
+	sizes  string
+}
+
+// responsiveImage returns the attributes of a markdown image on the page at
+// relPath pointing to dest, or nil if it is not a processed image. Images in
+// markdown that is not a page (relPath "") are only found by root-relative URLs.
+func (mp *MarkdownProcessor) responsiveImage(relPath, dest string) *responsiveImage {
+	if mp.images == nil || !isRelativeURL(dest) && !isRootRelative(dest) {
+		return nil
+	}
+	target, _ := splitURL(dest)
+	if unescaped, err := url.PathUnescape(target); err == nil {
+		target = unescaped
+	}
+	var src string
+	switch {
+	case isRootRelative(target):
+		src = strings.TrimPrefix(target, "/")
+	case relPath != "":
+		src = path.Join(path.Dir(filepath.ToSlash(relPath)), target)
+	default:
+		return nil
+	}
+	src = filepath.FromSlash(path.Clean(src))
+	info, ok := mp.images.images[src]
+	if !ok {
+		return nil
+	}
+	img := &responsiveImage{source: src, width: info.Width, height: info.Height}
+	if len(info.Variants) > 0 {
+		base, _ := splitURL(dest)
+		candidates := make([]string, 0, len(info.Variants)+1)
+		for _, v := range info.Variants {
+			candidates = append(candidates, fmt.Sprintf("%s %dw", util.URLEscape([]byte(variantPath(base, v.Width)), true), v.Width))
+		}
+		candidates = append(candidates, fmt.Sprintf("%s %dw", util.URLEscape([]byte(base), true), info.Width))
+		img.srcset = strings.Join(candidates, ", ")
+		img.sizes = mp.images.config.sizes()
+	}
+	return img
+}
+
+// imageDeps returns the source paths of the processed images in a document
+func imageDeps(root ast.Node) []string {
+	var deps []string
+	ast.Walk(root, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if img, ok := n.(*ast.Image); ok && entering {
+			if src, ok := img.Attribute(imageSourceAttr); ok {
+				deps = append(deps, src.(string))
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+	return deps
+}
+
+// responsiveImages is a goldmark extension adding srcset, sizes, width,
+// height and loading="lazy" to markdown images of processed images
+type responsiveImages struct{}
+
+func (e *responsiveImages) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(parser.WithASTTransformers(util.Prioritized(&responsiveImageTransformer{}, 100)))
+}
+
+type responsiveImageTransformer struct{}
+
+func (t *responsiveImageTransformer) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	lookup, _ := pc.Get(responsiveImageKey).(func(string) *responsiveImage)
+	if lookup == nil {
+		return
+	}
+	ast.Walk(doc, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+		n, ok := node.(*ast.Image)
+		if !ok || !entering {
+			return ast.WalkContinue, nil
+		}
+		img := lookup(string(n.Destination))
+		if img == nil {
+			return ast.WalkContinue, nil
+		}
+		n.SetAttribute(imageSourceAttr, img.source)
+		if img.srcset != "" {
+			n.SetAttributeString("srcset", img.srcset)
+			n.SetAttributeString("sizes", img.sizes)
+		}
+		n.SetAttributeString("width", strconv.Itoa(img.width))
+		n.SetAttributeString("height", strconv.Itoa(img.height))
+		n.SetAttributeString("loading", "lazy")
+		return ast.WalkContinue, nil
+	})
+}
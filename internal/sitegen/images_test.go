@@ -0,0 +1,131 @@
+package sitegen
+
+import (
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeTestImage writes a solid JPEG or PNG image of the given size
+func writeTestImage(t *testing.T, p string, width, height int) {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Create(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if filepath.Ext(p) == ".png" {
+		err = png.Encode(f, img)
+	} else {
+		err = jpeg.Encode(f, img, nil)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func imageSize(t *testing.T, p string) (int, int) {
+	t.Helper()
+	f, err := os.Open(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	cfg, _, err := image.DecodeConfig(f)
+	if err != nil {
+		t.Fatalf("failed to decode %s: %v", p, err)
+	}
+	return cfg.Width, cfg.Height
+}
+
+func TestVariantPath(t *testing.T) {
+	if got := variantPath("img/hero.jpg", 480); got != "img/hero-480w.jpg" {
+		t.Errorf("variantPath() = %q", got)
+	}
+	if got := variantSource("img/hero-480w.jpg", []int{320, 480}); got != "img/hero.jpg" {
+		t.Errorf("variantSource() = %q", got)
+	}
+	if got := variantSource("img/hero.jpg", []int{320, 480}); got != "" {
+		t.Errorf("variantSource() = %q, want none", got)
+	}
+}
+
+func TestBuild_ResponsiveImages(t *testing.T) {
+	inputDir := t.TempDir()
+	outputDir := t.TempDir()
+	writeTestFile(t, filepath.Join(inputDir, "colade.yaml"), "images:\n  widths: [100, 50, 400]\n  sizes: \"(min-width: 40em) 40em, 100vw\"\n")
+	writeTestFile(t, filepath.Join(inputDir, "docs", "index.md"), "# Docs\n\n![Hero](../img/hero.jpg \"The hero\") ![Logo](/img/logo.png) ![Remote](https://example.com/x.jpg)\n")
+	writeTestImage(t, filepath.Join(inputDir, "img", "hero.jpg"), 200, 100)
+	writeTestImage(t, filepath.Join(inputDir, "img", "logo.png"), 40, 20)
+
+	if err := Build(BuildOptions{InputDir: inputDir, OutputDir: outputDir}); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	for name, want := range map[string][2]int{"hero.jpg": {200, 100}, "hero-50w.jpg": {50, 25}, "hero-100w.jpg": {100, 50}} {
+		if w, h := imageSize(t, filepath.Join(outputDir, "img", name)); w != want[0] || h != want[1] {
+			t.Errorf("%s is %dx%d, want %dx%d", name, w, h, want[0], want[1])
+		}
+	}
+	for _, name := range []string{"hero-400w.jpg", "logo-50w.png"} {
+		if _, err := os.Stat(filepath.Join(outputDir, "img", name)); err == nil {
+			t.Errorf("expected no variant %s at or above the original width", name)
+		}
+	}
+	page := readTestFile(t, filepath.Join(outputDir, "docs", "index.html"))
+	for _, want := range []string{
+		`<img src="../img/hero.jpg" alt="Hero" title="The hero" srcset="../img/hero-50w.jpg 50w, ../img/hero-100w.jpg 100w, ../img/hero.jpg 200w" sizes="(min-width: 40em) 40em, 100vw" width="200" height="100" loading="lazy">`,
+		`<img src="/img/logo.png" alt="Logo" width="40" height="20" loading="lazy">`,
+		`<img src="https://example.com/x.jpg" alt="Remote">`,
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("expected page to contain %q, got:\n%s", want, page)
+		}
+	}
+
+	// Unchanged images are not resized again, even in a full build
+	variant := filepath.Join(outputDir, "img", "hero-100w.jpg")
+	old := time.Now().Add(-time.Hour).Truncate(time.Second)
+	os.Chtimes(variant, old, old)
+	if err := Build(BuildOptions{InputDir: inputDir, OutputDir: outputDir, NoIncremental: true}); err != nil {
+		t.Fatalf("second Build failed: %v", err)
+	}
+	if info, err := os.Stat(variant); err != nil || !info.ModTime().Equal(old) {
+		t.Errorf("expected the cached variant to be kept, got %v", err)
+	}
+	cache, err := loadCache(getCachePath(outputDir))
+	if err != nil || len(cache.Images[filepath.Join("img", "hero.jpg")].Variants) != 2 {
+		t.Errorf("expected the variants to be cached, got %+v (%v)", cache, err)
+	}
+
+	// A changed image rebuilds its variants and the pages showing it
+	writeTestImage(t, filepath.Join(inputDir, "img", "hero.jpg"), 80, 80)
+	later := time.Now().Add(2 * time.Second)
+	os.Chtimes(filepath.Join(inputDir, "img", "hero.jpg"), later, later)
+	if err := Build(BuildOptions{InputDir: inputDir, OutputDir: outputDir}); err != nil {
+		t.Fatalf("incremental Build failed: %v", err)
+	}
+	if w, h := imageSize(t, filepath.Join(outputDir, "img", "hero-50w.jpg")); w != 50 || h != 50 {
+		t.Errorf("hero-50w.jpg is %dx%d after the change, want 50x50", w, h)
+	}
+	if _, err := os.Stat(variant); err == nil {
+		t.Errorf("expected the variant wider than the new image to be removed")
+	}
+	if page := readTestFile(t, filepath.Join(outputDir, "docs", "index.html")); !strings.Contains(page, `srcset="../img/hero-50w.jpg 50w, ../img/hero.jpg 80w"`) {
+		t.Errorf("expected the page to be rebuilt with the new variants, got:\n%s", page)
+	}
+}
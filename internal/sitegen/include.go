@@ -0,0 +1,330 @@
+// include.go - Markdown files included in pages with {{< include >}} or ![[name]]
+package sitegen
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// includeShortcode is the built-in shortcode including a markdown file
+const includeShortcode = "include"
+
+var (
+	// embedPattern matches a ![[name]] embed on a line of its own
+	embedPattern = regexp.MustCompile(`^ {0,3}!\[\[([^\[\]|]+)\]\][ \t]*$`)
+	// atxHeadingPattern matches a # heading line, see markdownSection
+	atxHeadingPattern = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$`)
+	// inlineDestPattern matches the destination of an inline link or image
+	inlineDestPattern = regexp.MustCompile(`(\]\([ \t]*)(<[^<>\n]*>|[^\s()<>]+)`)
+	// refDefPattern matches the destination of a [label]: dest reference definition
+	refDefPattern = regexp.MustCompile(`^( {0,3}\[[^\]]+\]:[ \t]*)(<[^<>\n]*>|\S+)`)
+)
+
+// isIncludeFile reports whether a markdown file is only included in other
+// pages rather than published: its name or one of its directories starts with "_"
+func isIncludeFile(relPath string) bool {
+	for _, part := range strings.Split(filepath.ToSlash(relPath), "/") {
+		if strings.HasPrefix(part, "_") {
+			return true
+		}
+	}
+	return false
+}
+
+// includeKey normalizes the name of an embedded file like wikiKey, ignoring
+// the "_" prefixes of include files
+func includeKey(name string) string {
+	parts := strings.Split(filepath.ToSlash(strings.TrimSpace(name)), "/")
+	for i, part := range parts {
+		parts[i] = strings.TrimPrefix(part, "_")
+	}
+	return wikiKey(strings.Join(parts, "/"))
+}
+
+// SetIncludeFiles indexes the markdown files ![[name]] embeds are resolved
+// against, by file name and path without extension. Include files win over
+// pages of the same name.
+func (mp *MarkdownProcessor) SetIncludeFiles(markdownFiles []string) {
+	mp.includes = make(map[string]string)
+	for _, includeOnly := range []bool{true, false} {
+		for _, f := range markdownFiles {
+			if isIncludeFile(f) != includeOnly {
+				continue
+			}
+			stem := strings.TrimSuffix(filepath.ToSlash(f), path.Ext(f))
+			for _, k := range []string{includeKey(stem), includeKey(path.Base(stem))} {
+				if _, ok := mp.includes[k]; !ok {
+					mp.includes[k] = f
+				}
+			}
+		}
+	}
+}
+
+// expandAll expands the shortcodes and then the embeds of markdown
+func (e *shortcodeExpansion) expandAll(src []byte) ([]byte, error) {
+	out, err := e.expand(src)
+	if err != nil {
+		return nil, err
+	}
+	return e.expandEmbeds(out)
+}
+
+// expandEmbeds replaces the ![[name]] lines outside fenced code with the
+// markdown of the files they name
+func (e *shortcodeExpansion) expandEmbeds(src []byte) ([]byte, error) {
+	if !bytes.Contains(src, []byte("![[")) {
+		return src, nil
+	}
+	var out bytes.Buffer
+	fence := ""
+	for _, line := range bytes.SplitAfter(src, []byte("\n")) {
+		trimmed := strings.TrimLeft(string(line), " ")
+		switch {
+		case fence != "":
+			if strings.HasPrefix(trimmed, fence) {
+				fence = ""
+			}
+		case strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~"):
+			fence = trimmed[:3]
+		default:
+			if m := embedPattern.FindSubmatch(bytes.TrimRight(line, "\r\n")); m != nil {
+				md, err := e.embed(string(m[1]))
+				if err != nil {
+					return nil, err
+				}
+				out.Write(md)
+				continue
+			}
+		}
+		out.Write(line)
+	}
+	return out.Bytes(), nil
+}
+
+// embed returns the markdown of the file a ![[name#heading]] embed names
+func (e *shortcodeExpansion) embed(target string) ([]byte, error) {
+	name, heading := splitWikiTarget(target)
+	relPath, ok := e.mp.includes[includeKey(name)]
+	if !ok {
+		return nil, fmt.Errorf("no file found for ![[%s]]", target)
+	}
+	return e.includeFile(filepath.Join(e.inputDir, relPath), heading)
+}
+
+// include returns the markdown of the file an include shortcode names,
+// relative to the including file or to the input directory if it starts
+// with "/", optionally followed by #heading
+func (e *shortcodeExpansion) include(tag shortcodeTag) ([]byte, error) {
+	name, heading := splitWikiTarget(tag.params["0"])
+	if name == "" {
+		return nil, fmt.Errorf("shortcode %q needs a file", includeShortcode)
+	}
+	if strings.HasPrefix(name, "/") {
+		return e.includeFile(filepath.Join(e.inputDir, filepath.FromSlash(name)), heading)
+	}
+	dir := filepath.Join(e.inputDir, filepath.Dir(e.relPath))
+	if len(e.including) > 0 {
+		dir = filepath.Dir(e.including[len(e.including)-1])
+	}
+	return e.includeFile(filepath.Join(dir, filepath.FromSlash(name)), heading)
+}
+
+// includeFile returns the markdown of a file without its frontmatter, or the
+// section under one of its headings, with its own shortcodes and embeds
+// expanded in the context of the including page. The page depends on the file.
+func (e *shortcodeExpansion) includeFile(p, heading string) ([]byte, error) {
+	rel := depKey(e.inputDir, p)
+	if !inDir(e.inputDir, p) {
+		return nil, fmt.Errorf("failed to include '%s': outside the input directory", rel)
+	}
+	if slices.Contains(e.including, p) {
+		var chain []string
+		for _, f := range e.including {
+			chain = append(chain, depKey(e.inputDir, f))
+		}
+		return nil, fmt.Errorf("include cycle: %s -> %s", strings.Join(chain, " -> "), rel)
+	}
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return nil, fmt.Errorf("failed to include '%s': %w", rel, err)
+	}
+	e.deps = append(e.deps, rel)
+	includer := filepath.Join(e.inputDir, e.relPath)
+	if len(e.including) > 0 {
+		includer = e.including[len(e.including)-1]
+	}
+	data = stripFrontmatter(data)
+	if heading != "" {
+		section, ok := markdownSection(data, heading)
+		if !ok {
+			return nil, fmt.Errorf("failed to include '%s': no heading %q", rel, heading)
+		}
+		data = section
+	}
+
+	e.including = append(e.including, p)
+	defer func() { e.including = e.including[:len(e.including)-1] }()
+	out, err := e.expandAll(data)
+	if err != nil {
+		return nil, err
+	}
+	if dir, err := filepath.Rel(filepath.Dir(includer), filepath.Dir(p)); err == nil && dir != "." {
+		out = rebaseMarkdownLinks(out, filepath.ToSlash(dir))
+	}
+	if len(out) > 0 && out[len(out)-1] != '\n' {
+		out = append(out, '\n')
+	}
+	return out, nil
+}
+
+// rebaseMarkdownLinks prefixes the relative link, image and raw HTML URLs of
+// included markdown with dir, the included file's directory relative to the
+// file including it. Fenced code and code spans are left alone.
+func rebaseMarkdownLinks(src []byte, dir string) []byte {
+	rebase := func(u string) string {
+		target, suffix := splitURL(u)
+		if !isRelativeURL(u) || target == "" {
+			return u
+		}
+		rebased := path.Join(dir, target)
+		if strings.HasSuffix(target, "/") {
+			rebased += "/"
+		}
+		return rebased + suffix
+	}
+	rebaseDest := func(pattern *regexp.Regexp, text []byte) []byte {
+		return pattern.ReplaceAllFunc(text, func(m []byte) []byte {
+			parts := pattern.FindSubmatch(m)
+			dest := string(parts[2])
+			if strings.HasPrefix(dest, "<") {
+				dest = "<" + rebase(strings.Trim(dest, "<>")) + ">"
+			} else {
+				dest = rebase(dest)
+			}
+			return append(append([]byte{}, parts[1]...), dest...)
+		})
+	}
+
+	var out bytes.Buffer
+	fence := ""
+	for _, line := range bytes.SplitAfter(src, []byte("\n")) {
+		trimmed := strings.TrimLeft(string(line), " ")
+		switch {
+		case fence != "":
+			if strings.HasPrefix(trimmed, fence) {
+				fence = ""
+			}
+		case strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~"):
+			fence = trimmed[:3]
+		default:
+			line = rebaseDest(refDefPattern, line)
+			line = outsideCodeSpans(line, func(text []byte) []byte {
+				return rewriteURLs(rebaseDest(inlineDestPattern, text), rebase)
+			})
+		}
+		out.Write(line)
+	}
+	return out.Bytes()
+}
+
+// outsideCodeSpans applies fn to the parts of a line that are not in `code spans`
+func outsideCodeSpans(line []byte, fn func([]byte) []byte) []byte {
+	var out []byte
+	start := 0
+	for i := 0; i < len(line); {
+		if line[i] != '`' {
+			i++
+			continue
+		}
+		n := 0
+		for i+n < len(line) && line[i+n] == '`' {
+			n++
+		}
+		end := closingBackticks(line, i+n, n)
+		if end < 0 {
+			i += n
+			continue
+		}
+		out = append(out, fn(line[start:i])...)
+		out = append(out, line[i:end]...)
+		start, i = end, end
+	}
+	return append(out, fn(line[start:])...)
+}
+
+// closingBackticks returns the end of the run of exactly n backticks closing
+// a code span opened before from, or -1
+func closingBackticks(line []byte, from, n int) int {
+	for i := from; i < len(line); {
+		if line[i] != '`' {
+			i++
+			continue
+		}
+		m := 0
+		for i+m < len(line) && line[i+m] == '`' {
+			m++
+		}
+		if m == n {
+			return i + m
+		}
+		i += m
+	}
+	return -1
+}
+
+// stripFrontmatter removes the YAML (---) or TOML (+++) frontmatter of markdown
+func stripFrontmatter(src []byte) []byte {
+	for _, delim := range []string{"---", "+++"} {
+		rest, ok := bytes.CutPrefix(src, []byte(delim+"\n"))
+		if !ok {
+			continue
+		}
+		if bytes.HasPrefix(rest, []byte(delim+"\n")) {
+			return rest[len(delim)+1:]
+		}
+		if i := bytes.Index(rest, []byte("\n"+delim+"\n")); i >= 0 {
+			return rest[i+len(delim)+2:]
+		}
+		if bytes.HasSuffix(rest, []byte("\n"+delim)) {
+			return nil
+		}
+	}
+	return src
+}
+
+// markdownSection returns the # heading of markdown whose id matches the
+// given heading and the lines up to the next heading of the same or a higher level
+func markdownSection(src []byte, heading string) ([]byte, bool) {
+	id := headingID(heading)
+	var out bytes.Buffer
+	level := 0
+	fence := ""
+	for _, line := range bytes.SplitAfter(src, []byte("\n")) {
+		trimmed := strings.TrimLeft(string(line), " ")
+		if fence != "" {
+			if strings.HasPrefix(trimmed, fence) {
+				fence = ""
+			}
+		} else if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			fence = trimmed[:3]
+		} else if m := atxHeadingPattern.FindSubmatch(bytes.TrimRight(line, "\r\n")); m != nil {
+			switch {
+			case level == 0 && headingID(string(m[2])) == id:
+				level = len(m[1])
+			case level > 0 && len(m[1]) <= level:
+				return out.Bytes(), true
+			}
+		}
+		if level > 0 {
+			out.Write(line)
+		}
+	}
+	return out.Bytes(), level > 0
+}
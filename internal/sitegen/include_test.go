@@ -0,0 +1,150 @@
+package sitegen
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMarkdownSection(t *testing.T) {
+	src := []byte("# Install\n\nIntro\n\n## Linux ##\n\n```sh\n# not a heading\n```\n\n### Debian\n\napt\n\n## macOS\n\nbrew\n")
+	got, ok := markdownSection(src, "linux")
+	if want := "## Linux ##\n\n```sh\n# not a heading\n```\n\n### Debian\n\napt\n\n"; !ok || string(got) != want {
+		t.Errorf("markdownSection() = %q, %v, want %q", got, ok, want)
+	}
+	if got, ok := markdownSection(src, "macOS"); !ok || string(got) != "## macOS\n\nbrew\n" {
+		t.Errorf("markdownSection() = %q, %v", got, ok)
+	}
+	if _, ok := markdownSection(src, "Windows"); ok {
+		t.Errorf("expected no section for a missing heading")
+	}
+	if got := stripFrontmatter([]byte("---\ntitle: x\n---\nBody\n")); string(got) != "Body\n" {
+		t.Errorf("stripFrontmatter() = %q", got)
+	}
+	if !isIncludeFile(filepath.Join("_snippets", "install.md")) || !isIncludeFile("_install.md") || isIncludeFile("docs/install.md") {
+		t.Errorf("isIncludeFile() misclassified a file")
+	}
+}
+
+func TestBuild_Includes(t *testing.T) {
+	inputDir := t.TempDir()
+	outputDir := t.TempDir()
+	writeTestFile(t, filepath.Join(inputDir, "index.md"), "# Home\n\n{{< include \"snippets/_install.md\" >}}\n\n![[install#macOS]]\n\n```\n![[install]]\n```\n")
+	writeTestFile(t, filepath.Join(inputDir, "snippets", "_install.md"), "---\ntitle: Install\n---\n## Linux\n\nRun apt.\n\n{{< include \"_note.md\" >}}\n\n## macOS\n\nRun brew.\n")
+	writeTestFile(t, filepath.Join(inputDir, "snippets", "_note.md"), "{{< details summary=\"Note\" >}}Read *this*.{{< /details >}}\n")
+
+	if err := Build(BuildOptions{InputDir: inputDir, OutputDir: outputDir, RSSURL: "https://example.com"}); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	index := readTestFile(t, filepath.Join(outputDir, "index.html"))
+	for _, want := range []string{
+		`<h2 id="linux">Linux</h2>`,
+		"<p>Run apt.</p>",
+		"<summary>Note</summary>\n<p>Read <em>this</em>.</p>",
+		`<h2 id="macos-1">macOS</h2>`,
+		"<pre><code>![[install]]\n</code></pre>",
+	} {
+		if !strings.Contains(index, want) {
+			t.Errorf("expected index.html to contain %q, got:\n%s", want, index)
+		}
+	}
+	if strings.Contains(index, "title: Install") {
+		t.Errorf("expected the frontmatter of included files to be dropped, got:\n%s", index)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "snippets", "_install.html")); err == nil {
+		t.Errorf("expected include files not to be published")
+	}
+	if feed := readTestFile(t, filepath.Join(outputDir, "feed.xml")); strings.Contains(feed, "_install.html") || strings.Contains(feed, "_note.html") {
+		t.Errorf("expected include files to be left out of the feed, got:\n%s", feed)
+	}
+
+	// Editing an included file rebuilds the pages including it
+	writeTestFile(t, filepath.Join(inputDir, "snippets", "_note.md"), "Updated note.\n")
+	later := time.Now().Add(2 * time.Second)
+	os.Chtimes(filepath.Join(inputDir, "snippets", "_note.md"), later, later)
+	if err := Build(BuildOptions{InputDir: inputDir, OutputDir: outputDir}); err != nil {
+		t.Fatalf("incremental Build failed: %v", err)
+	}
+	if index := readTestFile(t, filepath.Join(outputDir, "index.html")); !strings.Contains(index, "<p>Updated note.</p>") {
+		t.Errorf("expected the page to be rebuilt with the new note, got:\n%s", index)
+	}
+}
+
+func TestRebaseMarkdownLinks(t *testing.T) {
+	src := "[a](../docs/index.md#x) ![b](img/b.png \"B\") [c](<my file.md>) [d](#top) [e](https://example.com/e.md) [f](/root.md)\n" +
+		"<a href=\"other.md\">g</a> `[code](x.md)` [h][r]\n" +
+		"[r]: sub/\n\n" +
+		"```\n[fenced](x.md)\n```\n"
+	want := "[a](docs/index.md#x) ![b](snippets/img/b.png \"B\") [c](<snippets/my file.md>) [d](#top) [e](https://example.com/e.md) [f](/root.md)\n" +
+		"<a href=\"snippets/other.md\">g</a> `[code](x.md)` [h][r]\n" +
+		"[r]: snippets/sub/\n\n" +
+		"```\n[fenced](x.md)\n```\n"
+	if got := string(rebaseMarkdownLinks([]byte(src), "snippets")); got != want {
+		t.Errorf("rebaseMarkdownLinks() = %q, want %q", got, want)
+	}
+}
+
+func TestBuild_IncludeFromOtherDirectory(t *testing.T) {
+	inputDir := t.TempDir()
+	outputDir := t.TempDir()
+	writeTestFile(t, filepath.Join(inputDir, "snippets", "_links.md"), "[Docs](../docs/index.md) ![Logo](img/logo.png) [Nested](nested/page.md)\n\n{{< include \"nested/_more.md\" >}}\n")
+	writeTestFile(t, filepath.Join(inputDir, "snippets", "nested", "_more.md"), "[Back](../../index.md)\n")
+	writeTestFile(t, filepath.Join(inputDir, "snippets", "img", "logo.png"), "png")
+	writeTestFile(t, filepath.Join(inputDir, "snippets", "nested", "page.md"), "# Nested")
+	writeTestFile(t, filepath.Join(inputDir, "index.md"), "# Home\n\n{{< include \"snippets/_links.md\" >}}\n")
+	writeTestFile(t, filepath.Join(inputDir, "docs", "index.md"), "# Docs\n\n![[links]]\n")
+
+	if err := Build(BuildOptions{InputDir: inputDir, OutputDir: outputDir, Check: true}); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	for page, wants := range map[string][]string{
+		"index.html":                        {`<a href="docs/index.html">Docs</a>`, `<img src="snippets/img/logo.png" alt="Logo">`, `<a href="snippets/nested/page.html">Nested</a>`, `<a href="index.html">Back</a>`},
+		filepath.Join("docs", "index.html"): {`<a href="index.html">Docs</a>`, `<img src="../snippets/img/logo.png" alt="Logo">`, `<a href="../snippets/nested/page.html">Nested</a>`, `<a href="../index.html">Back</a>`},
+	} {
+		out := readTestFile(t, filepath.Join(outputDir, page))
+		for _, want := range wants {
+			if !strings.Contains(out, want) {
+				t.Errorf("expected %s to contain %q, got:\n%s", page, want, out)
+			}
+		}
+	}
+}
+
+func TestBuild_IncludeErrors(t *testing.T) {
+	for name, tc := range map[string]struct {
+		files map[string]string
+		want  string
+	}{
+		"cycle": {
+			map[string]string{"index.md": "![[a]]\n", "_a.md": "![[b]]\n", "_b.md": "{{< include \"/_a.md\" >}}\n"},
+			"include cycle: _a.md -> _b.md -> _a.md",
+		},
+		"missing embed": {map[string]string{"index.md": "![[nowhere]]\n"}, "no file found for ![[nowhere]]"},
+		"missing file":  {map[string]string{"index.md": "{{< include \"nowhere.md\" >}}\n"}, "failed to include 'nowhere.md'"},
+		"missing heading": {
+			map[string]string{"index.md": "{{< include \"_a.md#setup\" >}}\n", "_a.md": "# A\n"},
+			`no heading "setup"`,
+		},
+		"outside from root": {
+			map[string]string{"index.md": "{{< include \"/../secret.txt\" >}}\n"},
+			"outside the input directory",
+		},
+		"outside from include": {
+			map[string]string{"index.md": "![[a]]\n", "docs/_a.md": "{{< include \"../../secret.txt\" >}}\n"},
+			"outside the input directory",
+		},
+	} {
+		root := t.TempDir()
+		writeTestFile(t, filepath.Join(root, "secret.txt"), "SECRET-OUTSIDE")
+		inputDir := filepath.Join(root, "site")
+		for f, content := range tc.files {
+			writeTestFile(t, filepath.Join(inputDir, f), content)
+		}
+		err := Build(BuildOptions{InputDir: inputDir, OutputDir: t.TempDir()})
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: expected an error containing %q, got %v", name, tc.want, err)
+		}
+	}
+}
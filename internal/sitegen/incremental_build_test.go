@@ -41,7 +41,7 @@ func TestIncrementalBuildCacheFilenames(t *testing.T) {
 	if err := BuildSite(inputDir, outputDir, 0, false, "", 0, false, "default", "", "", false, false, ""); err != nil {
 		t.Fatalf("first build failed: %v", err)
 	}
-	checkOutputFiles(t, outputDir, []string{"one.html", "two.html", "three.html", ".colade-cache"})
+	checkOutputFiles(t, outputDir, []string{"one.html", "two.html", "three.html", "style.css", ".colade-cache"})
 
 	// Modify one file
 	md2mod := `# Page Two\n\nBack to [Page One](one.md) or forward to [Page Three](three.md)\n\nExtra line!`
@@ -53,7 +53,7 @@ func TestIncrementalBuildCacheFilenames(t *testing.T) {
 	if err := BuildSite(inputDir, outputDir, 0, false, "", 0, false, "default", "", "", false, false, ""); err != nil {
 		t.Fatalf("second build failed: %v", err)
 	}
-	checkOutputFiles(t, outputDir, []string{"one.html", "two.html", "three.html", ".colade-cache"})
+	checkOutputFiles(t, outputDir, []string{"one.html", "two.html", "three.html", "style.css", ".colade-cache"})
 }
 
 func checkOutputFiles(t *testing.T, outputDir string, expected []string) {
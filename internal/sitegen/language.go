@@ -0,0 +1,167 @@
+// language.go - Multilingual sites: page languages, output prefixes and i18n strings
+package sitegen
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// I18nDirName is the directory in the input directory holding translated strings
+const I18nDirName = "i18n"
+
+// LanguageConfig configures one language of a multilingual site
+type LanguageConfig struct {
+	Code   string `yaml:"code" json:"code"`     // used in file names (page.ga.md) and directories (ga/page.md)
+	Name   string `yaml:"name" json:"name"`     // display name, defaults to the code
+	Locale string `yaml:"locale" json:"locale"` // hreflang, <html lang> and RSS language, defaults to the code
+	Prefix string `yaml:"prefix" json:"prefix"` // output directory, defaults to the code ("" for the first language)
+}
+
+// Languages maps pages to the languages of a site. The first configured
+// language is the default: pages without a language marker belong to it. A
+// nil *Languages is a single-language site where paths are left unchanged.
+type Languages struct {
+	list    []*LanguageConfig
+	byCode  map[string]*LanguageConfig
+	strings map[string]map[string]string // i18n strings by language code
+}
+
+// NewLanguages validates the configured languages and loads their i18n/<code>
+// strings, falling back to the default language for missing keys. Returns nil
+// if no languages are configured.
+func NewLanguages(inputDir string, configs []LanguageConfig) (*Languages, error) {
+	if len(configs) == 0 {
+		return nil, nil
+	}
+	l := &Languages{byCode: make(map[string]*LanguageConfig), strings: make(map[string]map[string]string)}
+	for i, c := range configs {
+		if c.Code == "" || strings.ContainsAny(c.Code, `./\`) {
+			return nil, fmt.Errorf("invalid language code %q", c.Code)
+		}
+		if l.byCode[c.Code] != nil {
+			return nil, fmt.Errorf("language %q is configured twice", c.Code)
+		}
+		if c.Name == "" {
+			c.Name = c.Code
+		}
+		if c.Locale == "" {
+			c.Locale = c.Code
+		}
+		if c.Prefix == "" && i > 0 {
+			c.Prefix = c.Code
+		}
+		c.Prefix = strings.Trim(filepath.ToSlash(c.Prefix), "/")
+		lang := c
+		l.list = append(l.list, &lang)
+		l.byCode[c.Code] = &lang
+	}
+
+	for _, lang := range l.list {
+		strs, err := loadI18n(inputDir, lang.Code)
+		if err != nil {
+			return nil, err
+		}
+		if lang != l.list[0] {
+			for k, v := range l.strings[l.list[0].Code] {
+				if _, ok := strs[k]; !ok {
+					strs[k] = v
+				}
+			}
+		}
+		l.strings[lang.Code] = strs
+	}
+	return l, nil
+}
+
+// loadI18n reads i18n/<code>.yaml (or .yml, .json, .toml) as a flat map of strings
+func loadI18n(inputDir, code string) (map[string]string, error) {
+	strs := make(map[string]string)
+	for _, ext := range []string{".yaml", ".yml", ".json", ".toml"} {
+		p := filepath.Join(inputDir, I18nDirName, code+ext)
+		if !fileExists(p) {
+			continue
+		}
+		value, _, err := readDataFile(p)
+		if err != nil {
+			return nil, fmt.Errorf("error reading i18n file '%s': %w", code+ext, err)
+		}
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("i18n file '%s' must map keys to strings", code+ext)
+		}
+		for k, v := range m {
+			strs[k] = fmt.Sprint(v)
+		}
+		break
+	}
+	return strs, nil
+}
+
+// Default returns the default language, or nil for a single-language site
+func (l *Languages) Default() *LanguageConfig {
+	if l == nil {
+		return nil
+	}
+	return l.list[0]
+}
+
+// List returns the configured languages, default first
+func (l *Languages) List() []*LanguageConfig {
+	if l == nil {
+		return nil
+	}
+	return l.list
+}
+
+// Strings returns the i18n strings of a language
+func (l *Languages) Strings(lang *LanguageConfig) map[string]string {
+	if l == nil || lang == nil {
+		return nil
+	}
+	return l.strings[lang.Code]
+}
+
+// Split returns the language of a source file and its path without the
+// language marker, which is shared by its translations. A top-level
+// directory named after a language (ga/about.md) or a language suffix
+// (about.ga.md) marks a translation; explicit is false for unmarked files.
+func (l *Languages) Split(relPath string) (lang *LanguageConfig, base string, explicit bool) {
+	if l == nil {
+		return nil, relPath, false
+	}
+	slashPath := filepath.ToSlash(relPath)
+	if dir, rest, ok := strings.Cut(slashPath, "/"); ok && l.byCode[dir] != nil {
+		return l.byCode[dir], filepath.FromSlash(rest), true
+	}
+	ext := path.Ext(slashPath)
+	stem := strings.TrimSuffix(slashPath, ext)
+	if code := strings.TrimPrefix(path.Ext(stem), "."); code != "" && l.byCode[code] != nil {
+		return l.byCode[code], filepath.FromSlash(strings.TrimSuffix(stem, "."+code) + ext), true
+	}
+	return l.list[0], relPath, false
+}
+
+// OutputPath returns the output path of a markdown file: its path without
+// language marker, as .html, below the language's prefix
+func (l *Languages) OutputPath(relPath string) string {
+	lang, base, _ := l.Split(relPath)
+	out := strings.TrimSuffix(base, filepath.Ext(base)) + ".html"
+	if lang != nil && lang.Prefix != "" {
+		out = filepath.Join(filepath.FromSlash(lang.Prefix), out)
+	}
+	return out
+}
+
+// feedPaths lists the output paths of the RSS feeds, one per language
+func (l *Languages) feedPaths() []string {
+	if l == nil {
+		return []string{"feed.xml"}
+	}
+	var paths []string
+	for _, lang := range l.list {
+		paths = append(paths, filepath.Join(filepath.FromSlash(lang.Prefix), "feed.xml"))
+	}
+	return paths
+}
@@ -0,0 +1,141 @@
+package sitegen
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLanguages_Split(t *testing.T) {
+	languages, err := NewLanguages(t.TempDir(), []LanguageConfig{{Code: "en"}, {Code: "ga", Name: "Gaeilge"}})
+	if err != nil {
+		t.Fatalf("NewLanguages failed: %v", err)
+	}
+	tests := []struct {
+		relPath  string
+		lang     string
+		base     string
+		explicit bool
+		output   string
+	}{
+		{"about.md", "en", "about.md", false, "about.html"},
+		{"about.ga.md", "ga", "about.md", true, filepath.Join("ga", "about.html")},
+		{filepath.Join("ga", "blog", "post.md"), "ga", filepath.Join("blog", "post.md"), true, filepath.Join("ga", "blog", "post.html")},
+		{filepath.Join("en", "about.md"), "en", "about.md", true, "about.html"},
+		{"notes.v2.md", "en", "notes.v2.md", false, "notes.v2.html"},
+	}
+	for _, test := range tests {
+		lang, base, explicit := languages.Split(test.relPath)
+		if lang.Code != test.lang || base != test.base || explicit != test.explicit {
+			t.Errorf("Split(%q) = %s, %q, %t, want %s, %q, %t", test.relPath, lang.Code, base, explicit, test.lang, test.base, test.explicit)
+		}
+		if out := languages.OutputPath(test.relPath); out != test.output {
+			t.Errorf("OutputPath(%q) = %q, want %q", test.relPath, out, test.output)
+		}
+	}
+
+	var single *Languages
+	if lang, base, _ := single.Split("about.ga.md"); lang != nil || base != "about.ga.md" || single.OutputPath("about.ga.md") != "about.ga.html" {
+		t.Errorf("expected paths to be unchanged without languages")
+	}
+	if _, err := NewLanguages(t.TempDir(), []LanguageConfig{{Code: "en"}, {Code: "en"}}); err == nil {
+		t.Errorf("expected an error for a duplicate language")
+	}
+}
+
+func TestBuild_Multilingual(t *testing.T) {
+	inputDir := t.TempDir()
+	outputDir := t.TempDir()
+	writeTestFile(t, filepath.Join(inputDir, "colade.yaml"), `languages:
+  - code: en
+    name: English
+    locale: en-gb
+  - code: ga
+    name: Gaeilge
+`)
+	writeTestFile(t, filepath.Join(inputDir, "header.md"), "English header")
+	writeTestFile(t, filepath.Join(inputDir, "header.ga.md"), "Ceanntásc")
+	writeTestFile(t, filepath.Join(inputDir, "index.md"), "# Home\n\nWelcome.")
+	writeTestFile(t, filepath.Join(inputDir, "index.ga.md"), "# Baile\n\nFáilte.")
+	writeTestFile(t, filepath.Join(inputDir, "about.md"), "# About")
+	writeTestFile(t, filepath.Join(inputDir, "ga", "about.md"), "# Fúinn")
+	writeTestFile(t, filepath.Join(inputDir, "contact.md"), "# Contact")
+
+	if err := Build(BuildOptions{InputDir: inputDir, OutputDir: outputDir, RSSURL: "https://example.com"}); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	home := readTestFile(t, filepath.Join(outputDir, "index.html"))
+	for _, want := range []string{
+		`<html lang="en-gb">`,
+		`<link rel="alternate" hreflang="en-gb" href="/index.html">`,
+		`<link rel="alternate" hreflang="ga" href="/ga/index.html">`,
+		`<a href="/ga/index.html" hreflang="ga" lang="ga">Gaeilge</a>`,
+		"English header",
+	} {
+		if !strings.Contains(home, want) {
+			t.Errorf("expected home page to contain %q, got:\n%s", want, home)
+		}
+	}
+	gaHome := readTestFile(t, filepath.Join(outputDir, "ga", "index.html"))
+	if !strings.Contains(gaHome, `<html lang="ga">`) || !strings.Contains(gaHome, "Ceanntásc") || strings.Contains(gaHome, "English header") {
+		t.Errorf("expected Irish home page with the Irish header, got:\n%s", gaHome)
+	}
+	if about := readTestFile(t, filepath.Join(outputDir, "ga", "about.html")); !strings.Contains(about, `hreflang="en-gb" href="/about.html"`) {
+		t.Errorf("expected translation from the ga/ directory to link to the English page, got:\n%s", about)
+	}
+	if contact := readTestFile(t, filepath.Join(outputDir, "contact.html")); strings.Contains(contact, "hreflang") {
+		t.Errorf("untranslated pages should have no alternates, got:\n%s", contact)
+	}
+	for _, p := range []string{"index.ga.html", filepath.Join("ga", "header.html"), "header.ga.html"} {
+		if _, err := os.Stat(filepath.Join(outputDir, p)); !os.IsNotExist(err) {
+			t.Errorf("did not expect %s in the output", p)
+		}
+	}
+
+	feed := readTestFile(t, filepath.Join(outputDir, "feed.xml"))
+	if !strings.Contains(feed, "<language>en-gb</language>") || strings.Contains(feed, "/ga/") {
+		t.Errorf("expected an English-only feed, got:\n%s", feed)
+	}
+	gaFeed := readTestFile(t, filepath.Join(outputDir, "ga", "feed.xml"))
+	for _, want := range []string{"<language>ga</language>", "<link>https://example.com/ga</link>", "<link>https://example.com/ga/about.html</link>"} {
+		if !strings.Contains(gaFeed, want) {
+			t.Errorf("expected Irish feed to contain %q, got:\n%s", want, gaFeed)
+		}
+	}
+
+	// A second build keeps the translated outputs and feeds
+	if err := Build(BuildOptions{InputDir: inputDir, OutputDir: outputDir, RSSURL: "https://example.com", NoIncremental: true}); err != nil {
+		t.Fatalf("second Build failed: %v", err)
+	}
+	for _, p := range []string{filepath.Join("ga", "index.html"), filepath.Join("ga", "feed.xml")} {
+		if _, err := os.Stat(filepath.Join(outputDir, p)); err != nil {
+			t.Errorf("expected %s to survive cleanup: %v", p, err)
+		}
+	}
+}
+
+func TestBuild_I18nStrings(t *testing.T) {
+	inputDir := t.TempDir()
+	outputDir := t.TempDir()
+	writeTestFile(t, filepath.Join(inputDir, "colade.yaml"), "languages:\n  - code: en\n  - code: ga\n")
+	writeTestFile(t, filepath.Join(inputDir, "i18n", "en.yaml"), "read_more: Read more\nhome: Home\n")
+	writeTestFile(t, filepath.Join(inputDir, "i18n", "ga.yaml"), "read_more: Léigh tuilleadh\n")
+	writeTestFile(t, filepath.Join(inputDir, "templates", "strings.html"), "{{ .I18n.read_more }}|{{ .I18n.home }}")
+	writeTestFile(t, filepath.Join(inputDir, "post.md"), "# Post")
+	writeTestFile(t, filepath.Join(inputDir, "post.ga.md"), "# Alt")
+
+	if err := Build(BuildOptions{InputDir: inputDir, OutputDir: outputDir, Template: "strings"}); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	if out := readTestFile(t, filepath.Join(outputDir, "post.html")); out != "Read more|Home" {
+		t.Errorf("English strings = %q", out)
+	}
+	if out := readTestFile(t, filepath.Join(outputDir, "ga", "post.html")); out != "Léigh tuilleadh|Home" {
+		t.Errorf("Irish strings with English fallback = %q", out)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "i18n")); !os.IsNotExist(err) {
+		t.Errorf("i18n files should not be copied to the output")
+	}
+}
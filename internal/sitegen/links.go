@@ -0,0 +1,198 @@
+// links.go - Rewriting of links to markdown sources as links to their output pages
+package sitegen
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// mdLinkKey holds the function rewriting the link destinations of the page
+// being parsed, see MarkdownProcessor.mdLinkURL
+var mdLinkKey = parser.NewContextKey()
+
+// rewrittenHTMLAttr holds raw HTML whose links were rewritten, which
+// rawHTMLRenderer writes in place of the source
+var rewrittenHTMLAttr = []byte("colade-rewritten-html")
+
+// isMarkdownLink reports whether a link destination points at a markdown source
+func isMarkdownLink(dest string) bool {
+	if !isRelativeURL(dest) && !isRootRelative(dest) {
+		return false
+	}
+	target, _ := splitURL(dest)
+	switch strings.ToLower(path.Ext(target)) {
+	case ".md", ".markdown":
+		return true
+	}
+	return false
+}
+
+// mdLinkURL returns the URL of the page a link from the page at relPath to
+// a markdown source points to, keeping its query and fragment. Relative links
+// stay relative to the page's output; links from markdown that is not a page
+// (relPath "") only get the .html extension, or become foo/ with pretty URLs.
+func (mp *MarkdownProcessor) mdLinkURL(relPath, dest string) string {
+	if !isMarkdownLink(dest) {
+		return dest
+	}
+	target, suffix := splitURL(dest)
+	if relPath == "" {
+		out := strings.TrimSuffix(target, path.Ext(target)) + ".html"
+		if mp.config.PrettyURLs {
+			out = prettyLink(filepath.ToSlash(prettyOutputPath(out)))
+		}
+		return out + suffix
+	}
+	if isRootRelative(target) {
+		return mp.urlPath(filepath.FromSlash(strings.TrimPrefix(target, "/"))) + suffix
+	}
+	src := path.Join(path.Dir(filepath.ToSlash(relPath)), target)
+	return mp.pageURL(relPath, filepath.FromSlash(src)) + suffix
+}
+
+// pageURL returns the URL of the page rendered from the source at toRel,
+// relative to the page rendered from fromRel
+func (mp *MarkdownProcessor) pageURL(fromRel, toRel string) string {
+	out := mp.outputPath(toRel)
+	link := filepath.ToSlash(out)
+	if rel, err := filepath.Rel(filepath.Dir(mp.baseOutputPath(fromRel)), out); err == nil {
+		link = filepath.ToSlash(rel)
+	}
+	if mp.config.PrettyURLs {
+		link = prettyLink(link)
+	}
+	return link
+}
+
+// mdLinks is a goldmark extension rewriting links to markdown sources,
+// including reference links and <a href> in raw HTML. Code is left alone.
+type mdLinks struct{}
+
+func (e *mdLinks) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(parser.WithASTTransformers(util.Prioritized(&mdLinkTransformer{}, 100)))
+	m.Renderer().AddOptions(renderer.WithNodeRenderers(
+		util.Prioritized(&rawHTMLRenderer{Config: html.NewConfig()}, 500),
+	))
+}
+
+type mdLinkTransformer struct{}
+
+func (t *mdLinkTransformer) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	rewrite, _ := pc.Get(mdLinkKey).(func(string) string)
+	if rewrite == nil {
+		return
+	}
+	source := reader.Source()
+	ast.Walk(doc, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch n := node.(type) {
+		case *ast.Link:
+			n.Destination = []byte(rewrite(string(n.Destination)))
+		case *ast.RawHTML, *ast.HTMLBlock:
+			rewriteRawHTML(n, rawHTMLSource(n, source), rewrite)
+		}
+		return ast.WalkContinue, nil
+	})
+}
+
+// rawHTMLSource returns the source of a RawHTML or HTMLBlock node
+func rawHTMLSource(node ast.Node, source []byte) []byte {
+	var raw []byte
+	switch n := node.(type) {
+	case *ast.RawHTML:
+		for i := 0; i < n.Segments.Len(); i++ {
+			segment := n.Segments.At(i)
+			raw = append(raw, segment.Value(source)...)
+		}
+	case *ast.HTMLBlock:
+		for i := 0; i < n.Lines().Len(); i++ {
+			line := n.Lines().At(i)
+			raw = append(raw, line.Value(source)...)
+		}
+		if n.HasClosure() {
+			raw = append(raw, n.ClosureLine.Value(source)...)
+		}
+	}
+	return raw
+}
+
+// rewriteRawHTML records the raw HTML of a node with its links rewritten,
+// if any changed
+func rewriteRawHTML(n ast.Node, raw []byte, rewrite func(string) string) {
+	changed := false
+	rewritten := rewriteURLs(raw, func(u string) string {
+		if !isMarkdownLink(u) {
+			return u
+		}
+		changed = true
+		return rewrite(u)
+	})
+	if changed {
+		n.SetAttribute(rewrittenHTMLAttr, rewritten)
+	}
+}
+
+// rawHTMLRenderer renders raw HTML as goldmark does, using the rewritten
+// HTML recorded by mdLinkTransformer where there is one
+type rawHTMLRenderer struct {
+	html.Config
+}
+
+func (r *rawHTMLRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(ast.KindRawHTML, r.renderRawHTML)
+	reg.Register(ast.KindHTMLBlock, r.renderHTMLBlock)
+}
+
+func (r *rawHTMLRenderer) renderRawHTML(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkSkipChildren, nil
+	}
+	if !r.Unsafe {
+		_, _ = w.WriteString("<!-- raw HTML omitted -->")
+		return ast.WalkSkipChildren, nil
+	}
+	if rewritten, ok := node.Attribute(rewrittenHTMLAttr); ok {
+		_, _ = w.Write(rewritten.([]byte))
+		return ast.WalkSkipChildren, nil
+	}
+	n := node.(*ast.RawHTML)
+	for i := 0; i < n.Segments.Len(); i++ {
+		segment := n.Segments.At(i)
+		_, _ = w.Write(segment.Value(source))
+	}
+	return ast.WalkSkipChildren, nil
+}
+
+func (r *rawHTMLRenderer) renderHTMLBlock(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	if !r.Unsafe {
+		_, _ = w.WriteString("<!-- raw HTML omitted -->\n")
+		return ast.WalkSkipChildren, nil
+	}
+	if rewritten, ok := node.Attribute(rewrittenHTMLAttr); ok {
+		r.Writer.SecureWrite(w, rewritten.([]byte))
+		return ast.WalkSkipChildren, nil
+	}
+	n := node.(*ast.HTMLBlock)
+	for i := 0; i < n.Lines().Len(); i++ {
+		line := n.Lines().At(i)
+		r.Writer.SecureWrite(w, line.Value(source))
+	}
+	if n.HasClosure() {
+		r.Writer.SecureWrite(w, n.ClosureLine.Value(source))
+	}
+	return ast.WalkSkipChildren, nil
+}
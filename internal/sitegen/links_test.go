@@ -0,0 +1,148 @@
+package sitegen
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMdLinks_Rewrite(t *testing.T) {
+	mp := NewMarkdownProcessor("")
+	src := "[a](foo.md) [b](foo.md#setup) [c](sub/bar.markdown?v=1#x) [d][ref] [![img](pic.png)](foo.md)\n" +
+		"[ext](https://example.com/readme.md) [plain](page.html) [anchor](#top)\n" +
+		"Inline <a href=\"foo.md#a\">raw</a> and `[code](foo.md)`.\n\n" +
+		"<div>\n<a href=\"sub/bar.md\">block</a>\n</div>\n\n" +
+		"```\n[fenced](foo.md)\n```\n\n" +
+		"[ref]: docs/ref.md\n"
+	out, _, err := mp.renderPageMarkdown([]byte(src), "index.md")
+	if err != nil {
+		t.Fatalf("renderPageMarkdown failed: %v", err)
+	}
+	html := string(out)
+	for _, want := range []string{
+		`<a href="foo.html">a</a>`,
+		`<a href="foo.html#setup">b</a>`,
+		`<a href="sub/bar.html?v=1#x">c</a>`,
+		`<a href="docs/ref.html">d</a>`,
+		`<a href="foo.html"><img src="pic.png" alt="img"></a>`,
+		`<a href="https://example.com/readme.md">ext</a>`,
+		`<a href="page.html">plain</a>`,
+		`<a href="#top">anchor</a>`,
+		`<a href="foo.html#a">raw</a>`,
+		"<div>\n<a href=\"sub/bar.html\">block</a>\n</div>",
+		"<code>[code](foo.md)</code>",
+		"<pre><code>[fenced](foo.md)\n</code></pre>",
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, html)
+		}
+	}
+}
+
+func TestMdLinks_OutputPaths(t *testing.T) {
+	languages, err := NewLanguages(t.TempDir(), []LanguageConfig{{Code: "en"}, {Code: "ga"}})
+	if err != nil {
+		t.Fatalf("NewLanguages failed: %v", err)
+	}
+	mp := NewMarkdownProcessor("")
+	mp.SetLanguages(languages)
+	tests := []struct {
+		relPath, dest, want string
+	}{
+		{filepath.Join("docs", "guide.md"), "../index.md", "../index.html"},
+		{filepath.Join("docs", "guide.md"), "intro.md#start", "intro.html#start"},
+		{filepath.Join("docs", "guide.md"), "/blog/post.md", "/blog/post.html"},
+		{"about.md", "contact.ga.md", "ga/contact.html"},
+		{"about.ga.md", "contact.ga.md", "contact.html"},
+		{"about.ga.md", "index.md", "../index.html"},
+		{"about.md", "/about.ga.md", "/ga/about.html"},
+		{"about.md", "mailto:me@example.com", "mailto:me@example.com"},
+		{"", "sub/page.md#x", "sub/page.html#x"},
+	}
+	for _, test := range tests {
+		if got := mp.mdLinkURL(test.relPath, test.dest); got != test.want {
+			t.Errorf("mdLinkURL(%q, %q) = %q, want %q", test.relPath, test.dest, got, test.want)
+		}
+	}
+}
+
+func TestMdLinks_PrettyURLs(t *testing.T) {
+	mp := NewMarkdownProcessorWithConfig("", &Config{PrettyURLs: true})
+	tests := []struct {
+		relPath, dest, want string
+	}{
+		{"index.md", "docs/guide.md#install", "docs/guide/#install"},
+		{"index.md", "docs/index.md", "docs/"},
+		{filepath.Join("docs", "guide.md"), "intro.md?v=1", "intro/?v=1"},
+		{filepath.Join("docs", "guide.md"), "index.md", "./"},
+		{filepath.Join("docs", "guide.md"), "../index.md", "../"},
+		{filepath.Join("docs", "guide.md"), "/blog/post.md", "/blog/post/"},
+		{filepath.Join("docs", "guide.md"), "/index.md#top", "/#top"},
+		{"", "sub/page.md#x", "sub/page/#x"},
+		{"", "index.md", "./"},
+	}
+	for _, test := range tests {
+		if got := mp.mdLinkURL(test.relPath, test.dest); got != test.want {
+			t.Errorf("mdLinkURL(%q, %q) = %q, want %q", test.relPath, test.dest, got, test.want)
+		}
+	}
+}
+
+func TestBuild_PrettyURLs(t *testing.T) {
+	inputDir := t.TempDir()
+	outputDir := t.TempDir()
+	writeTestFile(t, filepath.Join(inputDir, "index.md"), "# Home\n\n[Guide](docs/guide.md#guide) [Docs](docs/index.md)\n")
+	writeTestFile(t, filepath.Join(inputDir, "docs", "index.md"), "# Docs\n\n[Guide](guide.md)\n")
+	writeTestFile(t, filepath.Join(inputDir, "docs", "guide.md"), "# Guide\n\n[Home](../index.md) [Docs](index.md) [Self](/docs/guide.md) [Top](#guide)\n\n![Diagram](img/diagram.png)\n")
+	writeTestFile(t, filepath.Join(inputDir, "docs", "img", "diagram.png"), "png")
+	writeTestFile(t, filepath.Join(inputDir, "404.md"), "# Lost\n")
+	writeTestFile(t, filepath.Join(outputDir, "docs", "guide.html"), "stale")
+
+	if err := Build(BuildOptions{InputDir: inputDir, OutputDir: outputDir, PrettyURLs: true, RSSURL: "https://example.com", Check: true}); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	pages := map[string][]string{
+		"index.html":                                 {`<a href="docs/guide/#guide">Guide</a>`, `<a href="docs/">Docs</a>`},
+		filepath.Join("docs", "index.html"):          {`<a href="guide/">Guide</a>`},
+		filepath.Join("docs", "guide", "index.html"): {`<a href="../../">Home</a>`, `<a href="../">Docs</a>`, `<a href="/docs/guide/">Self</a>`, `<a href="#guide">Top</a>`, `<img src="../img/diagram.png" alt="Diagram">`},
+	}
+	for page, wants := range pages {
+		out := readTestFile(t, filepath.Join(outputDir, page))
+		for _, want := range wants {
+			if !strings.Contains(out, want) {
+				t.Errorf("expected %s to contain %q, got:\n%s", page, want, out)
+			}
+		}
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "404.html")); err != nil {
+		t.Errorf("expected the 404 page to stay at 404.html: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "docs", "guide.html")); err == nil {
+		t.Errorf("expected the page without pretty URLs to be removed")
+	}
+	if feed := readTestFile(t, filepath.Join(outputDir, "feed.xml")); !strings.Contains(feed, "<link>https://example.com/docs/guide/</link>") {
+		t.Errorf("expected the feed to link to docs/guide/, got:\n%s", feed)
+	}
+}
+
+func TestBuild_MdLinksInSubdirectory(t *testing.T) {
+	inputDir := t.TempDir()
+	outputDir := t.TempDir()
+	writeTestFile(t, filepath.Join(inputDir, "index.md"), "# Home\n\n[Guide](docs/guide.md#install)\n")
+	writeTestFile(t, filepath.Join(inputDir, "docs", "guide.md"), "# Guide\n\n[Home](../index.md) [Self](/docs/guide.md)\n")
+
+	if err := Build(BuildOptions{InputDir: inputDir, OutputDir: outputDir, NoIncremental: true}); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	if out := readTestFile(t, filepath.Join(outputDir, "index.html")); !strings.Contains(out, `<a href="docs/guide.html#install">Guide</a>`) {
+		t.Errorf("expected rewritten link with fragment, got:\n%s", out)
+	}
+	guide := []byte(readTestFile(t, filepath.Join(outputDir, "docs", "guide.html")))
+	for _, want := range []string{`<a href="../index.html">Home</a>`, `<a href="/docs/guide.html">Self</a>`} {
+		if !bytes.Contains(guide, []byte(want)) {
+			t.Errorf("expected guide.html to contain %q, got:\n%s", want, guide)
+		}
+	}
+}
@@ -2,18 +2,60 @@
 package sitegen
 
 import (
-	"regexp"
-	"strings"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/renderer/html"
 )
 
-// replaceMdLinks replaces links to .md/.markdown files with .html in markdown content.
-func replaceMdLinks(content []byte) []byte {
-	s := string(content)
-	// Replace [text](foo.md) and [text](foo.markdown) with [text](foo.html)
-	// Use regex to specifically target markdown link syntax
-	re := regexp.MustCompile(`\[([^\]]*)\]\(([^)]*\.(?:md|markdown))\)`)
-	s = re.ReplaceAllStringFunc(s, func(match string) string {
-		return strings.ReplaceAll(strings.ReplaceAll(match, ".md)", ".html)"), ".markdown)", ".html)")
-	})
-	return []byte(s)
+// MarkdownConfig toggles Markdown syntax extensions and HTML output options
+type MarkdownConfig struct {
+	Footnotes       bool  `yaml:"footnotes"`        // [^1] references and footnote definitions
+	DefinitionLists bool  `yaml:"definition_lists"` // terms followed by ": definition" lines
+	Typographer     bool  `yaml:"typographer"`      // smart quotes, dashes and ellipses
+	Linkify         *bool `yaml:"linkify"`          // turn bare URLs into links (default true)
+	HardWraps       bool  `yaml:"hard_wraps"`       // render line breaks in paragraphs as <br>
+	XHTML           bool  `yaml:"xhtml"`            // write self-closing tags such as <br />
+	Attributes      bool  `yaml:"attributes"`       // {#id .class} attributes on headings
+}
+
+// extensions returns the goldmark syntax extensions enabled by the config.
+// Tables, strikethrough and task lists from GFM are always on.
+func (c MarkdownConfig) extensions() []goldmark.Extender {
+	extensions := []goldmark.Extender{extension.Table, extension.Strikethrough, extension.TaskList}
+	if c.Linkify == nil || *c.Linkify {
+		extensions = append(extensions, extension.Linkify)
+	}
+	if c.Footnotes {
+		extensions = append(extensions, extension.Footnote)
+	}
+	if c.DefinitionLists {
+		extensions = append(extensions, extension.DefinitionList)
+	}
+	if c.Typographer {
+		extensions = append(extensions, extension.Typographer)
+	}
+	return extensions
+}
+
+// parserOptions returns the goldmark parser options for the config
+func (c MarkdownConfig) parserOptions() []parser.Option {
+	options := []parser.Option{parser.WithAutoHeadingID()}
+	if c.Attributes {
+		options = append(options, parser.WithAttribute())
+	}
+	return options
+}
+
+// rendererOptions returns the goldmark HTML renderer options for the config
+func (c MarkdownConfig) rendererOptions() []renderer.Option {
+	options := []renderer.Option{html.WithUnsafe()}
+	if c.HardWraps {
+		options = append(options, html.WithHardWraps())
+	}
+	if c.XHTML {
+		options = append(options, html.WithXHTML())
+	}
+	return options
 }
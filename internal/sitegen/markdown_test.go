@@ -0,0 +1,79 @@
+package sitegen
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMarkdownConfig_Defaults(t *testing.T) {
+	mp := NewMarkdownProcessor("")
+	src := "See https://example.com and \"quotes\" -- here[^1].\nNext line\n\n| a |\n|---|\n| 1 |\n\n[^1]: Note.\n\n## Title {#custom}\n"
+	out, _, _ := mp.renderMarkdown([]byte(src))
+	html := string(out)
+	for _, want := range []string{
+		`<a href="https://example.com">https://example.com</a>`,
+		`&quot;quotes&quot; --`,
+		".\nNext line</p>",
+		"<table>",
+		`<h2 id="title-custom">Title {#custom}</h2>`,
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("expected default output to contain %q, got:\n%s", want, html)
+		}
+	}
+}
+
+func TestMarkdownConfig_Options(t *testing.T) {
+	linkify := false
+	cfg := &Config{Markdown: MarkdownConfig{
+		Footnotes:       true,
+		DefinitionLists: true,
+		Typographer:     true,
+		Linkify:         &linkify,
+		HardWraps:       true,
+		XHTML:           true,
+		Attributes:      true,
+	}}
+	mp := NewMarkdownProcessorWithConfig("", cfg)
+	src := "See https://example.com and \"quotes\" -- here[^1].\nNext line\n\n[^1]: Note.\n\nTerm\n: Definition\n\n## Title {#custom .big}\n"
+	out, _, _ := mp.renderMarkdown([]byte(src))
+	html := string(out)
+	for _, want := range []string{
+		"See https://example.com and",
+		"&ldquo;quotes&rdquo; &ndash;",
+		`<sup id="fnref:1"><a href="#fn:1" class="footnote-ref" role="doc-noteref">1</a></sup>`,
+		"<br />\nNext line",
+		"<dl>\n<dt>Term</dt>\n<dd>Definition</dd>\n</dl>",
+		`<h2 id="custom" class="big">Title</h2>`,
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("expected configured output to contain %q, got:\n%s", want, html)
+		}
+	}
+	if strings.Contains(html, `<a href="https://example.com">`) {
+		t.Errorf("expected bare URLs not to be linked with linkify off, got:\n%s", html)
+	}
+}
+
+func TestBuild_MarkdownConfigRebuilds(t *testing.T) {
+	inputDir := t.TempDir()
+	outputDir := t.TempDir()
+	writeTestFile(t, filepath.Join(inputDir, "index.md"), "# Home\n\nSay \"hi\"\n")
+
+	if err := Build(BuildOptions{InputDir: inputDir, OutputDir: outputDir}); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	if out := readTestFile(t, filepath.Join(outputDir, "index.html")); !strings.Contains(out, "&quot;hi&quot;") {
+		t.Errorf("expected straight quotes, got:\n%s", out)
+	}
+
+	// Changing the markdown settings rebuilds unchanged pages in an incremental build
+	writeTestFile(t, filepath.Join(inputDir, "colade.yaml"), "markdown:\n  typographer: true\n")
+	if err := Build(BuildOptions{InputDir: inputDir, OutputDir: outputDir}); err != nil {
+		t.Fatalf("incremental Build failed: %v", err)
+	}
+	if out := readTestFile(t, filepath.Join(outputDir, "index.html")); !strings.Contains(out, "&ldquo;hi&rdquo;") {
+		t.Errorf("expected the page to be rebuilt with smart quotes, got:\n%s", out)
+	}
+}
@@ -0,0 +1,274 @@
+// math.go - TeX math in $...$ and $$...$$, rendered to MathML or left for KaTeX
+package sitegen
+
+import (
+	"bytes"
+	"fmt"
+	stdhtml "html"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// DefaultKaTeXURL is where KaTeX assets are loaded from unless math.katex_url is set
+const DefaultKaTeXURL = "https://cdn.jsdelivr.net/npm/katex@0.16.11/dist"
+
+// MathConfig configures math typesetting
+type MathConfig struct {
+	Disabled bool   `yaml:"disabled"`  // leave $ signs as text
+	KaTeX    bool   `yaml:"katex"`     // render math with KaTeX in the browser instead of MathML
+	KaTeXURL string `yaml:"katex_url"` // directory holding katex.min.js, katex.min.css and contrib/
+}
+
+// KindMathInline is the node kind of inline math
+var KindMathInline = ast.NewNodeKind("MathInline")
+
+// MathInline is math inside a paragraph, $...$ or $$...$$
+type MathInline struct {
+	ast.BaseInline
+	Segment text.Segment // the TeX source
+	Display bool         // written as $$...$$
+	KaTeX   bool         // left for KaTeX, see useKaTeX
+}
+
+func (n *MathInline) Kind() ast.NodeKind { return KindMathInline }
+
+func (n *MathInline) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"Display": fmt.Sprint(n.Display)}, nil)
+}
+
+// KindMathBlock is the node kind of display math blocks
+var KindMathBlock = ast.NewNodeKind("MathBlock")
+
+// MathBlock is display math on lines of its own between $$ delimiters
+type MathBlock struct {
+	ast.BaseBlock
+	KaTeX  bool // left for KaTeX, see useKaTeX
+	closed bool
+}
+
+func (n *MathBlock) Kind() ast.NodeKind { return KindMathBlock }
+
+func (n *MathBlock) IsRaw() bool { return true }
+
+func (n *MathBlock) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, nil, nil)
+}
+
+// mathematics is a goldmark extension for TeX math
+type mathematics struct{}
+
+func (e *mathematics) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(
+		parser.WithBlockParsers(util.Prioritized(&mathBlockParser{}, 700)),
+		parser.WithInlineParsers(util.Prioritized(&mathInlineParser{}, 500)),
+	)
+	m.Renderer().AddOptions(renderer.WithNodeRenderers(
+		util.Prioritized(&mathRenderer{}, 500),
+	))
+}
+
+// mathInlineParser parses $...$ and $$...$$ within a line. The opening $ must
+// not be followed by a space and the closing $ must not follow a space or
+// precede a digit, so prices such as "$5 and $10" stay text.
+type mathInlineParser struct{}
+
+func (p *mathInlineParser) Trigger() []byte {
+	return []byte{'$'}
+}
+
+func (p *mathInlineParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	line, seg := block.PeekLine()
+	if bytes.HasPrefix(line, []byte("$$")) {
+		end := bytes.Index(line[2:], []byte("$$"))
+		if end <= 0 {
+			return nil
+		}
+		block.Advance(end + 4)
+		return &MathInline{Segment: text.NewSegment(seg.Start+2, seg.Start+2+end), Display: true}
+	}
+	if len(line) < 3 || isSpaceByte(line[1]) {
+		return nil
+	}
+	for i := 1; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			i++
+		case '$':
+			if isSpaceByte(line[i-1]) || i+1 < len(line) && isDigit(line[i+1]) {
+				return nil
+			}
+			block.Advance(i + 1)
+			return &MathInline{Segment: text.NewSegment(seg.Start+1, seg.Start+i)}
+		}
+	}
+	return nil
+}
+
+// mathBlockParser parses display math starting with a line beginning with $$
+// and ending with a line ending with $$
+type mathBlockParser struct{}
+
+func (p *mathBlockParser) Trigger() []byte {
+	return []byte{'$'}
+}
+
+func (p *mathBlockParser) Open(parent ast.Node, reader text.Reader, pc parser.Context) (ast.Node, parser.State) {
+	line, seg := reader.PeekLine()
+	pos := pc.BlockOffset()
+	if pos < 0 || !bytes.HasPrefix(line[pos:], []byte("$$")) {
+		return nil, parser.NoChildren
+	}
+	node := &MathBlock{}
+	start := pos + 2
+	rest := util.TrimRightSpace(line[start:])
+	if end := bytes.Index(rest, []byte("$$")); end >= 0 {
+		// $$ ... $$ on a single line, which must end there
+		if end+2 != len(rest) {
+			return nil, parser.NoChildren
+		}
+		node.Lines().Append(text.NewSegment(seg.Start+start, seg.Start+start+end))
+		node.closed = true
+	} else if len(util.TrimLeftSpace(rest)) > 0 {
+		node.Lines().Append(text.NewSegment(seg.Start+start, seg.Stop))
+	}
+	reader.AdvanceToEOL()
+	return node, parser.NoChildren
+}
+
+func (p *mathBlockParser) Continue(node ast.Node, reader text.Reader, pc parser.Context) parser.State {
+	n := node.(*MathBlock)
+	if n.closed {
+		return parser.Close
+	}
+	line, seg := reader.PeekLine()
+	if trimmed := util.TrimRightSpace(line); bytes.HasSuffix(trimmed, []byte("$$")) {
+		if end := len(trimmed) - 2; end > 0 {
+			n.Lines().Append(text.NewSegment(seg.Start, seg.Start+end))
+		}
+		reader.AdvanceToEOL()
+		n.closed = true
+		return parser.Close
+	}
+	n.Lines().Append(seg)
+	reader.AdvanceToEOL()
+	return parser.Continue | parser.NoChildren
+}
+
+func (p *mathBlockParser) Close(node ast.Node, reader text.Reader, pc parser.Context) {}
+
+func (p *mathBlockParser) CanInterruptParagraph() bool { return true }
+
+func (p *mathBlockParser) CanAcceptIndentedLine() bool { return false }
+
+// mathRenderer writes math as MathML, or as TeX in \( \) and \[ \] delimiters
+// for KaTeX's auto-render script
+type mathRenderer struct{}
+
+func (r *mathRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(KindMathInline, r.renderInline)
+	reg.Register(KindMathBlock, r.renderBlock)
+}
+
+func (r *mathRenderer) renderInline(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	n := node.(*MathInline)
+	tex := string(n.Segment.Value(source))
+	switch {
+	case n.KaTeX && n.Display:
+		_, _ = w.WriteString(`<span class="math display">\[` + stdhtml.EscapeString(tex) + `\]</span>`)
+	case n.KaTeX:
+		_, _ = w.WriteString(`<span class="math inline">\(` + stdhtml.EscapeString(tex) + `\)</span>`)
+	default:
+		writeMathML(w, tex, n.Display)
+	}
+	return ast.WalkSkipChildren, nil
+}
+
+func (r *mathRenderer) renderBlock(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	n := node.(*MathBlock)
+	var tex bytes.Buffer
+	for i := 0; i < n.Lines().Len(); i++ {
+		line := n.Lines().At(i)
+		tex.Write(line.Value(source))
+	}
+	if n.KaTeX {
+		_, _ = w.WriteString(`<div class="math display">\[` + stdhtml.EscapeString(tex.String()) + "\\]</div>\n")
+		return ast.WalkSkipChildren, nil
+	}
+	writeMathML(w, tex.String(), true)
+	_ = w.WriteByte('\n')
+	return ast.WalkSkipChildren, nil
+}
+
+// writeMathML writes a formula as MathML, or as code with a warning when it
+// cannot be converted
+func writeMathML(w util.BufWriter, tex string, display bool) {
+	mathML, err := texToMathML(tex, display)
+	if err == nil {
+		_, _ = w.WriteString(mathML)
+		return
+	}
+	fmt.Printf("[Math] Warning: cannot convert %q: %v\n", tex, err)
+	delim := "$"
+	if display {
+		delim = "$$"
+	}
+	_, _ = w.WriteString(`<code class="math-error" title="` + stdhtml.EscapeString(err.Error()) + `">` + stdhtml.EscapeString(delim+tex+delim) + "</code>")
+}
+
+// useKaTeX marks the math of a page to be left for KaTeX and reports whether
+// the page has any
+func useKaTeX(root ast.Node) bool {
+	found := false
+	ast.Walk(root, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch m := n.(type) {
+		case *MathInline:
+			m.KaTeX, found = true, true
+		case *MathBlock:
+			m.KaTeX, found = true, true
+		}
+		return ast.WalkContinue, nil
+	})
+	return found
+}
+
+// katexPage reports whether a page's math is rendered by KaTeX: pages with
+// math: true in their frontmatter, or all pages but those with math: false
+// when the site sets math.katex
+func (mp *MarkdownProcessor) katexPage(metaData map[string]interface{}) bool {
+	if v, ok := metaData["math"].(bool); ok {
+		return v
+	}
+	return mp.config.Math.KaTeX
+}
+
+// katexAssets returns the stylesheet and scripts added to pages rendered with KaTeX
+func (mp *MarkdownProcessor) katexAssets() string {
+	url := mp.config.Math.KaTeXURL
+	if url == "" {
+		url = DefaultKaTeXURL
+	}
+	for len(url) > 1 && url[len(url)-1] == '/' {
+		url = url[:len(url)-1]
+	}
+	return fmt.Sprintf("<link rel=\"stylesheet\" href=\"%[1]s/katex.min.css\">\n"+
+		"<script defer src=\"%[1]s/katex.min.js\"></script>\n"+
+		"<script defer src=\"%[1]s/contrib/auto-render.min.js\" onload=\"renderMathInElement(document.body);\"></script>\n", url)
+}
+
+func isSpaceByte(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
@@ -0,0 +1,142 @@
+package sitegen
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMath_MathML(t *testing.T) {
+	mp := NewMarkdownProcessor("")
+	src := "Euler: $e^{i\\pi} + 1 = 0$.\n\n$$\n\\sum_{i=1}^{n} i = \\frac{n(n+1)}{2}\n$$\n\nInline display $$\\sqrt{x}$$ here.\n"
+	out, _, err := mp.renderMarkdown([]byte(src))
+	if err != nil {
+		t.Fatalf("renderMarkdown failed: %v", err)
+	}
+	html := string(out)
+	for _, want := range []string{
+		`<p>Euler: <math xmlns="http://www.w3.org/1998/Math/MathML"><semantics><mrow><msup><mi>e</mi><mrow><mi>i</mi><mi>π</mi></mrow></msup><mo>+</mo><mn>1</mn><mo>=</mo><mn>0</mn></mrow>`,
+		`<annotation encoding="application/x-tex">e^{i\pi} + 1 = 0</annotation></semantics></math>.</p>`,
+		`<math xmlns="http://www.w3.org/1998/Math/MathML" display="block"><semantics><mrow><munderover><mo largeop="true" movablelimits="true">∑</mo>`,
+		`<mfrac><mrow><mi>n</mi><mo stretchy="false">(</mo>`,
+		`<p>Inline display <math xmlns="http://www.w3.org/1998/Math/MathML" display="block"><semantics><msqrt><mi>x</mi></msqrt>`,
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, html)
+		}
+	}
+	if strings.Contains(html, "$") {
+		t.Errorf("expected no raw $ signs, got:\n%s", html)
+	}
+}
+
+func TestMath_LeavesTextAlone(t *testing.T) {
+	mp := NewMarkdownProcessor("")
+	for src, want := range map[string]string{
+		"It costs $5 and $10.":        "<p>It costs $5 and $10.</p>\n",
+		"Escaped \\$x\\$ signs.":      "<p>Escaped $x$ signs.</p>\n",
+		"Code `$x$` span.":            "<p>Code <code>$x$</code> span.</p>\n",
+		"A lone $ sign.":              "<p>A lone $ sign.</p>\n",
+		"```\n$$\nx\n$$\n```\n":       "<pre><code>$$\nx\n$$\n</code></pre>\n",
+		"$ not math $":                "<p>$ not math $</p>\n",
+		"Bad $\\nosuchcommand$ math.": "<p>Bad <code class=\"math-error\" title=\"unsupported command \\nosuchcommand\">$\\nosuchcommand$</code> math.</p>\n",
+	} {
+		out, _, _ := mp.renderMarkdown([]byte(src))
+		if string(out) != want {
+			t.Errorf("renderMarkdown(%q) = %q, want %q", src, out, want)
+		}
+	}
+
+	out, _, _ := NewMarkdownProcessorWithConfig("", &Config{Math: MathConfig{Disabled: true}}).renderMarkdown([]byte("$x$"))
+	if string(out) != "<p>$x$</p>\n" {
+		t.Errorf("expected math to be left as text when disabled, got %q", out)
+	}
+}
+
+func TestTexToMathML(t *testing.T) {
+	for tex, want := range map[string]string{
+		`x_i^2`:                                `<msubsup><mi>x</mi><mi>i</mi><mn>2</mn></msubsup>`,
+		`x^23`:                                 `<mrow><msup><mi>x</mi><mn>2</mn></msup><mn>3</mn></mrow>`,
+		`3.14`:                                 `<mn>3.14</mn>`,
+		`f'(x)`:                                `<msup><mi>f</mi><mo>′</mo></msup>`,
+		`\sqrt[3]{x}`:                          `<mroot><mi>x</mi><mn>3</mn></mroot>`,
+		`\mathbb{R}`:                           `<mi mathvariant="normal">ℝ</mi>`,
+		`\mathbf{v}`:                           `<mi mathvariant="normal">𝐯</mi>`,
+		`\Gamma`:                               `<mi mathvariant="normal">Γ</mi>`,
+		`\text{if } x<y`:                       `<mtext>if </mtext><mi>x</mi><mo>&lt;</mo><mi>y</mi>`,
+		`\left( x \right]`:                     `<mrow><mo fence="true" stretchy="true">(</mo><mi>x</mi><mo fence="true" stretchy="true">]</mo></mrow>`,
+		`\hat{x}`:                              `<mover accent="true"><mi>x</mi><mo stretchy="false">^</mo></mover>`,
+		`\binom{n}{k}`:                         `<mfrac linethickness="0"><mi>n</mi><mi>k</mi></mfrac>`,
+		`\not=`:                                `<mo>=&#x338;</mo>`,
+		`\begin{bmatrix}1&0\\0&1\end{bmatrix}`: `<mrow><mo fence="true" stretchy="true">[</mo><mtable><mtr><mtd><mn>1</mn></mtd><mtd><mn>0</mn></mtd></mtr><mtr><mtd><mn>0</mn></mtd><mtd><mn>1</mn></mtd></mtr></mtable><mo fence="true" stretchy="true">]</mo></mrow>`,
+	} {
+		got, err := texToMathML(tex, false)
+		if err != nil {
+			t.Errorf("texToMathML(%q) failed: %v", tex, err)
+			continue
+		}
+		if !strings.Contains(got, want) {
+			t.Errorf("texToMathML(%q) = %s, want it to contain %s", tex, got, want)
+		}
+	}
+
+	// Limits go below and above big operators only in display math
+	inline, _ := texToMathML(`\lim_{x \to 0} x`, false)
+	display, _ := texToMathML(`\lim_{x \to 0} x`, true)
+	if !strings.Contains(inline, "<msub><mi>lim</mi>") || !strings.Contains(display, "<munder><mi>lim</mi>") {
+		t.Errorf("unexpected limits placement:\ninline: %s\ndisplay: %s", inline, display)
+	}
+
+	for _, tex := range []string{`\frac{a}`, `{x`, `x}`, `x^`, `\left( x`, `\begin{matrix} a`, `\begin{matrix} a \end{pmatrix}`, `x^1^2`} {
+		if _, err := texToMathML(tex, false); err == nil {
+			t.Errorf("expected an error for %q", tex)
+		}
+	}
+}
+
+func TestBuild_MathKaTeX(t *testing.T) {
+	inputDir := t.TempDir()
+	outputDir := t.TempDir()
+	writeTestFile(t, filepath.Join(inputDir, "katex.md"), "---\nmath: true\n---\n# KaTeX\n\nInline $a<b$ and\n\n$$\nx^2\n$$\n")
+	writeTestFile(t, filepath.Join(inputDir, "mathml.md"), "# MathML\n\nInline $a<b$.\n")
+	writeTestFile(t, filepath.Join(inputDir, "plain.md"), "---\nmath: true\n---\n# Plain\n")
+
+	if err := Build(BuildOptions{InputDir: inputDir, OutputDir: outputDir, NoIncremental: true}); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	katex := readTestFile(t, filepath.Join(outputDir, "katex.html"))
+	for _, want := range []string{
+		`<span class="math inline">\(a&lt;b\)</span>`,
+		`<div class="math display">\[x^2` + "\n" + `\]</div>`,
+		`<link rel="stylesheet" href="` + DefaultKaTeXURL + `/katex.min.css">`,
+		`renderMathInElement(document.body);`,
+	} {
+		if !strings.Contains(katex, want) {
+			t.Errorf("expected katex.html to contain %q, got:\n%s", want, katex)
+		}
+	}
+	if strings.Contains(katex, "<math") {
+		t.Errorf("expected no MathML on a KaTeX page, got:\n%s", katex)
+	}
+	mathML := readTestFile(t, filepath.Join(outputDir, "mathml.html"))
+	if !strings.Contains(mathML, "<math") || strings.Contains(mathML, "katex") {
+		t.Errorf("expected MathML without KaTeX assets, got:\n%s", mathML)
+	}
+	if plain := readTestFile(t, filepath.Join(outputDir, "plain.html")); strings.Contains(plain, "katex") {
+		t.Errorf("expected no KaTeX assets on a page without math, got:\n%s", plain)
+	}
+
+	// math.katex applies to every page not opting out, with local assets
+	writeTestFile(t, filepath.Join(inputDir, "colade.yaml"), "math:\n  katex: true\n  katex_url: /katex/\n")
+	writeTestFile(t, filepath.Join(inputDir, "katex.md"), "---\nmath: false\n---\n# Opted out\n\n$x$\n")
+	if err := Build(BuildOptions{InputDir: inputDir, OutputDir: outputDir, NoIncremental: true}); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	mathML = readTestFile(t, filepath.Join(outputDir, "mathml.html"))
+	if !strings.Contains(mathML, `<script defer src="/katex/katex.min.js"></script>`) {
+		t.Errorf("expected local KaTeX assets, got:\n%s", mathML)
+	}
+	if optedOut := readTestFile(t, filepath.Join(outputDir, "katex.html")); !strings.Contains(optedOut, "<math") {
+		t.Errorf("expected MathML on a page with math: false, got:\n%s", optedOut)
+	}
+}
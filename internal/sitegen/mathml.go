@@ -0,0 +1,643 @@
+// mathml.go - Conversion of TeX math to MathML at build time
+package sitegen
+
+import (
+	"fmt"
+	"html"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// texToMathML converts a TeX formula to a <math> element. The TeX source is
+// kept as an annotation so it can be copied and read by assistive technology.
+func texToMathML(tex string, display bool) (string, error) {
+	p := &texParser{src: tex, display: display}
+	body, err := p.parseRow(0)
+	if err != nil {
+		return "", err
+	}
+	if p.pos < len(p.src) {
+		return "", fmt.Errorf("unexpected %q", p.src[p.pos:])
+	}
+	var b strings.Builder
+	b.WriteString(`<math xmlns="http://www.w3.org/1998/Math/MathML"`)
+	if display {
+		b.WriteString(` display="block"`)
+	}
+	b.WriteString("><semantics>")
+	b.WriteString(mrow(body))
+	b.WriteString(`<annotation encoding="application/x-tex">`)
+	b.WriteString(html.EscapeString(strings.TrimSpace(tex)))
+	b.WriteString("</annotation></semantics></math>")
+	return b.String(), nil
+}
+
+// texParser converts TeX to MathML in a single pass
+type texParser struct {
+	src     string
+	pos     int
+	display bool
+	font    string // letter style set by \mathbf and friends, see texFonts
+}
+
+// atom is a converted element and how scripts attach to it
+type atom struct {
+	xml    string
+	limits bool // big operator taking its scripts above and below in display mode
+}
+
+// stop conditions of parseRow
+const (
+	stopGroup    = 1 << iota // "}"
+	stopRight                // \right
+	stopCell                 // & and \\ inside environments
+	stopEnd                  // \end
+	stopOptional             // "]" of an optional argument
+)
+
+// parseRow converts atoms until the end of the source or a stop token
+func (p *texParser) parseRow(stops int) ([]string, error) {
+	var row []string
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.src) || p.atStop(stops) {
+			return row, nil
+		}
+		a, err := p.parseAtom(false)
+		if err != nil {
+			return nil, err
+		}
+		if a == nil {
+			continue
+		}
+		xml, err := p.parseScripts(*a)
+		if err != nil {
+			return nil, err
+		}
+		row = append(row, xml)
+	}
+}
+
+func (p *texParser) atStop(stops int) bool {
+	rest := p.src[p.pos:]
+	switch {
+	case stops&stopGroup != 0 && rest[0] == '}':
+		return true
+	case stops&stopOptional != 0 && rest[0] == ']':
+		return true
+	case stops&stopCell != 0 && (rest[0] == '&' || strings.HasPrefix(rest, `\\`)):
+		return true
+	case stops&stopRight != 0 && p.peekCommand() == "right":
+		return true
+	case stops&stopEnd != 0 && p.peekCommand() == "end":
+		return true
+	case stops&stopRight != 0 && p.peekCommand() == "middle":
+		return true
+	}
+	return false
+}
+
+// parseScripts attaches any ^, _ and primes following an atom to it
+func (p *texParser) parseScripts(base atom) (string, error) {
+	var sub, sup string
+	primes := ""
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.src) {
+			break
+		}
+		c := p.src[p.pos]
+		if c == '\'' {
+			p.pos++
+			primes += "′"
+			continue
+		}
+		if c != '^' && c != '_' {
+			if cmd := p.peekCommand(); cmd == "limits" || cmd == "nolimits" {
+				p.pos += len(cmd) + 1
+				base.limits = cmd == "limits"
+				continue
+			}
+			break
+		}
+		p.pos++
+		arg, err := p.parseArg()
+		if err != nil {
+			return "", err
+		}
+		if c == '^' {
+			if sup != "" {
+				return "", fmt.Errorf("double superscript")
+			}
+			sup = arg
+		} else {
+			if sub != "" {
+				return "", fmt.Errorf("double subscript")
+			}
+			sub = arg
+		}
+	}
+	if primes != "" && sup == "" {
+		sup = "<mo>" + primes + "</mo>"
+	} else if primes != "" {
+		sup = "<mrow><mo>" + primes + "</mo>" + sup + "</mrow>"
+	}
+	tags := [3]string{"msub", "msup", "msubsup"}
+	if base.limits && p.display {
+		tags = [3]string{"munder", "mover", "munderover"}
+	}
+	switch {
+	case sub != "" && sup != "":
+		return "<" + tags[2] + ">" + base.xml + sub + sup + "</" + tags[2] + ">", nil
+	case sub != "":
+		return "<" + tags[0] + ">" + base.xml + sub + "</" + tags[0] + ">", nil
+	case sup != "":
+		return "<" + tags[1] + ">" + base.xml + sup + "</" + tags[1] + ">", nil
+	}
+	return base.xml, nil
+}
+
+// parseArg converts a command or script argument: a group or a single token
+func (p *texParser) parseArg() (string, error) {
+	p.skipSpace()
+	if p.pos >= len(p.src) {
+		return "", fmt.Errorf("missing argument")
+	}
+	a, err := p.parseAtom(true)
+	if err != nil {
+		return "", err
+	}
+	if a == nil {
+		return "<mrow></mrow>", nil
+	}
+	return a.xml, nil
+}
+
+// parseGroup converts the contents of a {...} group
+func (p *texParser) parseGroup() (string, error) {
+	p.skipSpace()
+	if p.pos >= len(p.src) || p.src[p.pos] != '{' {
+		return "", fmt.Errorf("expected {")
+	}
+	p.pos++
+	row, err := p.parseRow(stopGroup)
+	if err != nil {
+		return "", err
+	}
+	if p.pos >= len(p.src) {
+		return "", fmt.Errorf("missing }")
+	}
+	p.pos++
+	return mrow(row), nil
+}
+
+// parseAtom converts the next token. single limits numbers to one digit, as
+// in x^23. Returns nil for tokens that produce no output.
+func (p *texParser) parseAtom(single bool) (*atom, error) {
+	c := p.src[p.pos]
+	switch {
+	case c == '{':
+		xml, err := p.parseGroup()
+		if err != nil {
+			return nil, err
+		}
+		return &atom{xml: xml}, nil
+	case c == '}':
+		return nil, fmt.Errorf("unexpected }")
+	case c == '\\':
+		return p.parseCommand()
+	case c == '^' || c == '_':
+		return nil, fmt.Errorf("missing base before %c", c)
+	case c == '&':
+		return nil, fmt.Errorf("& outside of an environment")
+	case c == '~':
+		p.pos++
+		return &atom{xml: `<mspace width="0.333em"></mspace>`}, nil
+	case c >= '0' && c <= '9' || c == '.' && p.pos+1 < len(p.src) && isDigit(p.src[p.pos+1]):
+		start := p.pos
+		p.pos++
+		for !single && p.pos < len(p.src) && (isDigit(p.src[p.pos]) || p.src[p.pos] == '.' && p.pos+1 < len(p.src) && isDigit(p.src[p.pos+1])) {
+			p.pos++
+		}
+		return &atom{xml: "<mn>" + p.styled(p.src[start:p.pos]) + "</mn>"}, nil
+	case c < utf8.RuneSelf && (c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'):
+		p.pos++
+		return &atom{xml: p.identifier(string(c))}, nil
+	}
+	r, size := utf8.DecodeRuneInString(p.src[p.pos:])
+	p.pos += size
+	if op, ok := texOperators[r]; ok {
+		return &atom{xml: op}, nil
+	}
+	if unicode.IsLetter(r) {
+		return &atom{xml: p.identifier(string(r))}, nil
+	}
+	return &atom{xml: "<mo>" + html.EscapeString(string(r)) + "</mo>"}, nil
+}
+
+// identifier renders a letter in the current font
+func (p *texParser) identifier(s string) string {
+	if p.font == "normal" {
+		return `<mi mathvariant="normal">` + html.EscapeString(s) + "</mi>"
+	}
+	styled := p.styled(s)
+	if p.font != "" && styled != html.EscapeString(s) && utf8.RuneCountInString(s) == 1 {
+		// Styled letters are single characters that must not be italicised again
+		return `<mi mathvariant="normal">` + styled + "</mi>"
+	}
+	return "<mi>" + styled + "</mi>"
+}
+
+// styled maps letters and digits to the current font's Unicode characters
+func (p *texParser) styled(s string) string {
+	if p.font == "" || p.font == "normal" {
+		return html.EscapeString(s)
+	}
+	var b strings.Builder
+	for _, r := range s {
+		b.WriteString(html.EscapeString(string(mathFontRune(p.font, r))))
+	}
+	return b.String()
+}
+
+// peekCommand returns the name of the command at the current position, if any
+func (p *texParser) peekCommand() string {
+	if p.pos >= len(p.src) || p.src[p.pos] != '\\' {
+		return ""
+	}
+	end := p.pos + 1
+	for end < len(p.src) && isASCIILetter(p.src[end]) {
+		end++
+	}
+	return p.src[p.pos+1 : end]
+}
+
+// readCommand consumes a command and returns its name, which is a single
+// character for control symbols such as \{ and \,
+func (p *texParser) readCommand() (string, error) {
+	p.pos++ // backslash
+	if p.pos >= len(p.src) {
+		return "", fmt.Errorf("trailing backslash")
+	}
+	start := p.pos
+	if !isASCIILetter(p.src[p.pos]) {
+		_, size := utf8.DecodeRuneInString(p.src[p.pos:])
+		p.pos += size
+		return p.src[start:p.pos], nil
+	}
+	for p.pos < len(p.src) && isASCIILetter(p.src[p.pos]) {
+		p.pos++
+	}
+	return p.src[start:p.pos], nil
+}
+
+func (p *texParser) parseCommand() (*atom, error) {
+	name, err := p.readCommand()
+	if err != nil {
+		return nil, err
+	}
+	if r, ok := texGreek[name]; ok {
+		if unicode.IsUpper(r) {
+			return &atom{xml: `<mi mathvariant="normal">` + string(r) + "</mi>"}, nil
+		}
+		return &atom{xml: "<mi>" + string(r) + "</mi>"}, nil
+	}
+	if s, ok := texSymbols[name]; ok {
+		return &atom{xml: s}, nil
+	}
+	if op, ok := texBigOperators[name]; ok {
+		return &atom{xml: `<mo largeop="true" movablelimits="true">` + op + "</mo>", limits: !strings.Contains("∫∬∭∮", op)}, nil
+	}
+	if limits, ok := texFunctions[name]; ok {
+		return &atom{xml: "<mi>" + name + "</mi>", limits: limits}, nil
+	}
+	if width, ok := texSpaces[name]; ok {
+		return &atom{xml: `<mspace width="` + width + `"></mspace>`}, nil
+	}
+	if accent, ok := texAccents[name]; ok {
+		arg, err := p.parseArg()
+		if err != nil {
+			return nil, err
+		}
+		if name == "underline" || name == "underbrace" {
+			return &atom{xml: `<munder accentunder="true">` + arg + `<mo stretchy="true">` + accent + "</mo></munder>", limits: name == "underbrace"}, nil
+		}
+		return &atom{xml: `<mover accent="true">` + arg + `<mo stretchy="` + fmt.Sprint(strings.HasPrefix(name, "wide") || strings.HasPrefix(name, "over")) + `">` + accent + "</mo></mover>", limits: name == "overbrace"}, nil
+	}
+	if font, ok := texFonts[name]; ok {
+		saved := p.font
+		p.font = font
+		arg, err := p.parseArg()
+		p.font = saved
+		if err != nil {
+			return nil, err
+		}
+		return &atom{xml: arg}, nil
+	}
+
+	switch name {
+	case "frac", "dfrac", "tfrac", "cfrac":
+		num, err := p.parseArg()
+		if err != nil {
+			return nil, err
+		}
+		den, err := p.parseArg()
+		if err != nil {
+			return nil, err
+		}
+		return &atom{xml: "<mfrac>" + num + den + "</mfrac>"}, nil
+	case "binom", "dbinom", "tbinom":
+		top, err := p.parseArg()
+		if err != nil {
+			return nil, err
+		}
+		bottom, err := p.parseArg()
+		if err != nil {
+			return nil, err
+		}
+		return &atom{xml: `<mrow><mo>(</mo><mfrac linethickness="0">` + top + bottom + "</mfrac><mo>)</mo></mrow>"}, nil
+	case "sqrt":
+		p.skipSpace()
+		var index string
+		if p.pos < len(p.src) && p.src[p.pos] == '[' {
+			p.pos++
+			row, err := p.parseRow(stopOptional)
+			if err != nil {
+				return nil, err
+			}
+			if p.pos >= len(p.src) {
+				return nil, fmt.Errorf("missing ] in \\sqrt")
+			}
+			p.pos++
+			index = mrow(row)
+		}
+		arg, err := p.parseArg()
+		if err != nil {
+			return nil, err
+		}
+		if index != "" {
+			return &atom{xml: "<mroot>" + arg + index + "</mroot>"}, nil
+		}
+		return &atom{xml: "<msqrt>" + arg + "</msqrt>"}, nil
+	case "text", "textrm", "textit", "textbf", "mbox", "textnormal":
+		text, err := p.rawGroup()
+		if err != nil {
+			return nil, err
+		}
+		return &atom{xml: "<mtext>" + html.EscapeString(text) + "</mtext>"}, nil
+	case "operatorname":
+		text, err := p.rawGroup()
+		if err != nil {
+			return nil, err
+		}
+		return &atom{xml: "<mi>" + html.EscapeString(text) + "</mi>"}, nil
+	case "left":
+		return p.parseLeftRight()
+	case "right", "middle":
+		return nil, fmt.Errorf("\\%s without \\left", name)
+	case "big", "Big", "bigg", "Bigg", "bigl", "Bigl", "biggl", "Biggl", "bigr", "Bigr", "biggr", "Biggr", "bigm", "Bigm":
+		delim, err := p.parseDelimiter()
+		if err != nil {
+			return nil, err
+		}
+		size := map[byte]string{'b': "1.2em", 'B': "1.8em"}[name[0]]
+		if strings.HasPrefix(strings.ToLower(name), "bigg") {
+			size = map[byte]string{'b': "2.4em", 'B': "3em"}[name[0]]
+		}
+		return &atom{xml: `<mo stretchy="true" minsize="` + size + `" maxsize="` + size + `">` + delim + "</mo>"}, nil
+	case "begin":
+		return p.parseEnvironment()
+	case "end":
+		return nil, fmt.Errorf("\\end without \\begin")
+	case "not":
+		p.skipSpace()
+		if p.pos >= len(p.src) {
+			return nil, fmt.Errorf("missing argument of \\not")
+		}
+		a, err := p.parseAtom(true)
+		if err != nil {
+			return nil, err
+		}
+		return &atom{xml: strings.Replace(a.xml, "</mo>", "&#x338;</mo>", 1)}, nil
+	case "pmod":
+		arg, err := p.parseArg()
+		if err != nil {
+			return nil, err
+		}
+		return &atom{xml: `<mrow><mspace width="1em"></mspace><mo>(</mo><mi>mod</mi><mspace width="0.333em"></mspace>` + arg + "<mo>)</mo></mrow>"}, nil
+	case "displaystyle", "textstyle", "scriptstyle", "limits", "nolimits":
+		return nil, nil
+	}
+	return nil, fmt.Errorf("unsupported command \\%s", name)
+}
+
+// rawGroup returns the unconverted text of a {...} group, as used by \text
+func (p *texParser) rawGroup() (string, error) {
+	p.skipSpace()
+	if p.pos >= len(p.src) || p.src[p.pos] != '{' {
+		return "", fmt.Errorf("expected {")
+	}
+	depth := 0
+	for i := p.pos; i < len(p.src); i++ {
+		switch p.src[i] {
+		case '\\':
+			i++
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				text := p.src[p.pos+1 : i]
+				p.pos = i + 1
+				return strings.NewReplacer(`\{`, "{", `\}`, "}", `\_`, "_", `\&`, "&", `\%`, "%", `\$`, "$", `\#`, "#").Replace(text), nil
+			}
+		}
+	}
+	return "", fmt.Errorf("missing }")
+}
+
+// parseDelimiter reads the delimiter after \left, \right or \big
+func (p *texParser) parseDelimiter() (string, error) {
+	p.skipSpace()
+	if p.pos >= len(p.src) {
+		return "", fmt.Errorf("missing delimiter")
+	}
+	if p.src[p.pos] == '\\' {
+		name, err := p.readCommand()
+		if err != nil {
+			return "", err
+		}
+		if d, ok := texDelimiters[name]; ok {
+			return d, nil
+		}
+		return "", fmt.Errorf("invalid delimiter \\%s", name)
+	}
+	c := p.src[p.pos]
+	p.pos++
+	switch c {
+	case '.':
+		return "", nil
+	case '(', ')', '[', ']', '|', '/':
+		return string(c), nil
+	case '<':
+		return "⟨", nil
+	case '>':
+		return "⟩", nil
+	}
+	return "", fmt.Errorf("invalid delimiter %q", c)
+}
+
+// parseLeftRight converts \left( ... \middle| ... \right) to a fenced row
+func (p *texParser) parseLeftRight() (*atom, error) {
+	open, err := p.parseDelimiter()
+	if err != nil {
+		return nil, err
+	}
+	row := []string{fence(open)}
+	for {
+		inner, err := p.parseRow(stopRight)
+		if err != nil {
+			return nil, err
+		}
+		row = append(row, inner...)
+		cmd := p.peekCommand()
+		if cmd != "right" && cmd != "middle" {
+			return nil, fmt.Errorf("\\left without \\right")
+		}
+		p.pos += len(cmd) + 1
+		delim, err := p.parseDelimiter()
+		if err != nil {
+			return nil, err
+		}
+		row = append(row, fence(delim))
+		if cmd == "right" {
+			return &atom{xml: "<mrow>" + strings.Join(row, "") + "</mrow>"}, nil
+		}
+	}
+}
+
+func fence(delim string) string {
+	if delim == "" {
+		return ""
+	}
+	return `<mo fence="true" stretchy="true">` + html.EscapeString(delim) + "</mo>"
+}
+
+// environment delimiters and column alignment, see parseEnvironment
+var texEnvironments = map[string][3]string{
+	"matrix":      {"", "", ""},
+	"smallmatrix": {"", "", ""},
+	"pmatrix":     {"(", ")", ""},
+	"bmatrix":     {"[", "]", ""},
+	"Bmatrix":     {"{", "}", ""},
+	"vmatrix":     {"|", "|", ""},
+	"Vmatrix":     {"‖", "‖", ""},
+	"cases":       {"{", "", "left left"},
+	"aligned":     {"", "", "right left"},
+	"align":       {"", "", "right left"},
+	"align*":      {"", "", "right left"},
+	"split":       {"", "", "right left"},
+	"gathered":    {"", "", "center"},
+	"gather":      {"", "", "center"},
+	"gather*":     {"", "", "center"},
+	"array":       {"", "", ""},
+}
+
+// parseEnvironment converts \begin{env} ... \end{env} to a table
+func (p *texParser) parseEnvironment() (*atom, error) {
+	env, err := p.rawGroup()
+	if err != nil {
+		return nil, err
+	}
+	delims, ok := texEnvironments[env]
+	if !ok {
+		return nil, fmt.Errorf("unsupported environment %q", env)
+	}
+	align := delims[2]
+	if env == "array" {
+		spec, err := p.rawGroup()
+		if err != nil {
+			return nil, err
+		}
+		var cols []string
+		for _, c := range spec {
+			switch c {
+			case 'l':
+				cols = append(cols, "left")
+			case 'c':
+				cols = append(cols, "center")
+			case 'r':
+				cols = append(cols, "right")
+			}
+		}
+		align = strings.Join(cols, " ")
+	}
+
+	var rows []string
+	var cells []string
+	for {
+		cell, err := p.parseRow(stopCell | stopEnd)
+		if err != nil {
+			return nil, err
+		}
+		if p.pos >= len(p.src) {
+			return nil, fmt.Errorf("missing \\end{%s}", env)
+		}
+		cells = append(cells, "<mtd>"+mrow(cell)+"</mtd>")
+		switch {
+		case p.src[p.pos] == '&':
+			p.pos++
+			continue
+		case strings.HasPrefix(p.src[p.pos:], `\\`):
+			p.pos += 2
+			rows = append(rows, "<mtr>"+strings.Join(cells, "")+"</mtr>")
+			cells = nil
+			continue
+		}
+		// \end
+		p.pos += len(`\end`)
+		end, err := p.rawGroup()
+		if err != nil {
+			return nil, err
+		}
+		if end != env {
+			return nil, fmt.Errorf("\\begin{%s} ended by \\end{%s}", env, end)
+		}
+		if len(cells) > 1 || cells[0] != "<mtd><mrow></mrow></mtd>" {
+			rows = append(rows, "<mtr>"+strings.Join(cells, "")+"</mtr>")
+		}
+		break
+	}
+	table := "<mtable"
+	if align != "" {
+		table += ` columnalign="` + align + `"`
+	}
+	table += ">" + strings.Join(rows, "") + "</mtable>"
+	if delims[0] == "" && delims[1] == "" {
+		return &atom{xml: table}, nil
+	}
+	return &atom{xml: "<mrow>" + fence(delims[0]) + table + fence(delims[1]) + "</mrow>"}, nil
+}
+
+func (p *texParser) skipSpace() {
+	for p.pos < len(p.src) && (p.src[p.pos] == ' ' || p.src[p.pos] == '\t' || p.src[p.pos] == '\n' || p.src[p.pos] == '\r') {
+		p.pos++
+	}
+}
+
+// mrow wraps several elements in a row; single elements need no wrapper
+func mrow(items []string) string {
+	if len(items) == 1 {
+		return items[0]
+	}
+	return "<mrow>" + strings.Join(items, "") + "</mrow>"
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isASCIILetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
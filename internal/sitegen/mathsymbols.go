@@ -0,0 +1,173 @@
+// mathsymbols.go - TeX command tables used by the MathML converter
+package sitegen
+
+// texOperators maps operator characters to <mo> elements
+var texOperators = map[rune]string{
+	'+': "<mo>+</mo>",
+	'-': "<mo>−</mo>",
+	'*': "<mo>∗</mo>",
+	'/': "<mo>/</mo>",
+	'=': "<mo>=</mo>",
+	'<': "<mo>&lt;</mo>",
+	'>': "<mo>&gt;</mo>",
+	',': "<mo>,</mo>",
+	';': "<mo>;</mo>",
+	':': "<mo>:</mo>",
+	'!': "<mo>!</mo>",
+	'?': "<mo>?</mo>",
+	'.': "<mo>.</mo>",
+	'|': `<mo stretchy="false">|</mo>`,
+	'(': `<mo stretchy="false">(</mo>`,
+	')': `<mo stretchy="false">)</mo>`,
+	'[': `<mo stretchy="false">[</mo>`,
+	']': `<mo stretchy="false">]</mo>`,
+}
+
+// texGreek maps Greek letter commands to their characters
+var texGreek = map[string]rune{
+	"alpha": 'α', "beta": 'β', "gamma": 'γ', "delta": 'δ', "epsilon": 'ϵ',
+	"varepsilon": 'ε', "zeta": 'ζ', "eta": 'η', "theta": 'θ', "vartheta": 'ϑ',
+	"iota": 'ι', "kappa": 'κ', "lambda": 'λ', "mu": 'μ', "nu": 'ν', "xi": 'ξ',
+	"omicron": 'ο', "pi": 'π', "varpi": 'ϖ', "rho": 'ρ', "varrho": 'ϱ',
+	"sigma": 'σ', "varsigma": 'ς', "tau": 'τ', "upsilon": 'υ', "phi": 'ϕ',
+	"varphi": 'φ', "chi": 'χ', "psi": 'ψ', "omega": 'ω',
+	"Gamma": 'Γ', "Delta": 'Δ', "Theta": 'Θ', "Lambda": 'Λ', "Xi": 'Ξ',
+	"Pi": 'Π', "Sigma": 'Σ', "Upsilon": 'Υ', "Phi": 'Φ', "Psi": 'Ψ', "Omega": 'Ω',
+}
+
+// texSymbols maps symbol commands to MathML elements
+var texSymbols = map[string]string{
+	// binary operators
+	"pm": "<mo>±</mo>", "mp": "<mo>∓</mo>", "times": "<mo>×</mo>", "div": "<mo>÷</mo>",
+	"cdot": "<mo>⋅</mo>", "ast": "<mo>∗</mo>", "star": "<mo>⋆</mo>", "circ": "<mo>∘</mo>",
+	"bullet": "<mo>∙</mo>", "oplus": "<mo>⊕</mo>", "ominus": "<mo>⊖</mo>", "otimes": "<mo>⊗</mo>",
+	"odot": "<mo>⊙</mo>", "cup": "<mo>∪</mo>", "cap": "<mo>∩</mo>", "setminus": "<mo>∖</mo>",
+	"wedge": "<mo>∧</mo>", "land": "<mo>∧</mo>", "vee": "<mo>∨</mo>", "lor": "<mo>∨</mo>",
+	"mod": `<mo lspace="0.5em" rspace="0.5em">mod</mo>`, "bmod": `<mo lspace="0.25em" rspace="0.25em">mod</mo>`,
+	// relations
+	"le": "<mo>≤</mo>", "leq": "<mo>≤</mo>", "ge": "<mo>≥</mo>", "geq": "<mo>≥</mo>",
+	"ne": "<mo>≠</mo>", "neq": "<mo>≠</mo>", "approx": "<mo>≈</mo>", "sim": "<mo>∼</mo>",
+	"simeq": "<mo>≃</mo>", "cong": "<mo>≅</mo>", "equiv": "<mo>≡</mo>", "propto": "<mo>∝</mo>",
+	"ll": "<mo>≪</mo>", "gg": "<mo>≫</mo>", "prec": "<mo>≺</mo>", "succ": "<mo>≻</mo>",
+	"in": "<mo>∈</mo>", "notin": "<mo>∉</mo>", "ni": "<mo>∋</mo>", "subset": "<mo>⊂</mo>",
+	"supset": "<mo>⊃</mo>", "subseteq": "<mo>⊆</mo>", "supseteq": "<mo>⊇</mo>",
+	"mid": "<mo>∣</mo>", "parallel": "<mo>∥</mo>", "perp": "<mo>⊥</mo>", "vdash": "<mo>⊢</mo>",
+	"models": "<mo>⊨</mo>", "coloneqq": "<mo>≔</mo>",
+	// arrows
+	"to": "<mo>→</mo>", "rightarrow": "<mo>→</mo>", "leftarrow": "<mo>←</mo>", "gets": "<mo>←</mo>",
+	"leftrightarrow": "<mo>↔</mo>", "Rightarrow": "<mo>⇒</mo>", "Leftarrow": "<mo>⇐</mo>",
+	"Leftrightarrow": "<mo>⇔</mo>", "implies": "<mo>⟹</mo>", "impliedby": "<mo>⟸</mo>",
+	"iff": "<mo>⟺</mo>", "mapsto": "<mo>↦</mo>", "longrightarrow": "<mo>⟶</mo>",
+	"longleftarrow": "<mo>⟵</mo>", "uparrow": "<mo>↑</mo>", "downarrow": "<mo>↓</mo>",
+	"hookrightarrow": "<mo>↪</mo>",
+	// logic and sets
+	"forall": "<mo>∀</mo>", "exists": "<mo>∃</mo>", "nexists": "<mo>∄</mo>", "neg": "<mo>¬</mo>",
+	"lnot": "<mo>¬</mo>", "emptyset": "<mi>∅</mi>", "varnothing": "<mi>∅</mi>",
+	// letter-like symbols
+	"infty": "<mi>∞</mi>", "partial": "<mi>∂</mi>", "nabla": "<mi>∇</mi>", "ell": "<mi>ℓ</mi>",
+	"hbar": "<mi>ℏ</mi>", "Re": "<mi>ℜ</mi>", "Im": "<mi>ℑ</mi>", "aleph": "<mi>ℵ</mi>",
+	"prime": "<mo>′</mo>", "angle": "<mo>∠</mo>", "triangle": "<mo>△</mo>", "degree": "<mo>°</mo>",
+	// dots
+	"ldots": "<mo>…</mo>", "dots": "<mo>…</mo>", "cdots": "<mo>⋯</mo>", "vdots": "<mo>⋮</mo>",
+	"ddots": "<mo>⋱</mo>",
+	// delimiters used on their own
+	"langle": `<mo stretchy="false">⟨</mo>`, "rangle": `<mo stretchy="false">⟩</mo>`,
+	"lfloor": `<mo stretchy="false">⌊</mo>`, "rfloor": `<mo stretchy="false">⌋</mo>`,
+	"lceil": `<mo stretchy="false">⌈</mo>`, "rceil": `<mo stretchy="false">⌉</mo>`,
+	"lbrace": `<mo stretchy="false">{</mo>`, "rbrace": `<mo stretchy="false">}</mo>`,
+	"vert": `<mo stretchy="false">|</mo>`, "Vert": `<mo stretchy="false">‖</mo>`,
+	// control symbols
+	"{": `<mo stretchy="false">{</mo>`, "}": `<mo stretchy="false">}</mo>`,
+	"|": `<mo stretchy="false">‖</mo>`, "_": "<mi>_</mi>", "%": "<mi>%</mi>",
+	"$": "<mi>$</mi>", "#": "<mi>#</mi>", "&": "<mo>&amp;</mo>",
+}
+
+// texBigOperators maps large operators to their characters
+var texBigOperators = map[string]string{
+	"sum": "∑", "prod": "∏", "coprod": "∐", "int": "∫", "iint": "∬", "iiint": "∭",
+	"oint": "∮", "bigcup": "⋃", "bigcap": "⋂", "bigoplus": "⨁", "bigotimes": "⨂",
+	"bigvee": "⋁", "bigwedge": "⋀",
+}
+
+// texFunctions lists named functions and whether they take limits
+var texFunctions = map[string]bool{
+	"sin": false, "cos": false, "tan": false, "sec": false, "csc": false, "cot": false,
+	"arcsin": false, "arccos": false, "arctan": false, "sinh": false, "cosh": false,
+	"tanh": false, "log": false, "lg": false, "ln": false, "exp": false, "deg": false,
+	"dim": false, "ker": false, "arg": false, "hom": false,
+	"lim": true, "liminf": true, "limsup": true, "max": true, "min": true, "sup": true,
+	"inf": true, "det": true, "gcd": true, "Pr": true, "argmax": true, "argmin": true,
+}
+
+// texSpaces maps spacing commands to widths
+var texSpaces = map[string]string{
+	",": "0.167em", "thinspace": "0.167em", ":": "0.222em", ">": "0.222em",
+	"medspace": "0.222em", ";": "0.278em", "thickspace": "0.278em", " ": "0.333em",
+	"quad": "1em", "qquad": "2em", "!": "-0.167em", "negthinspace": "-0.167em",
+}
+
+// texAccents maps accent commands to the mark placed over (or under) their argument
+var texAccents = map[string]string{
+	"hat": "^", "widehat": "^", "bar": "¯", "overline": "‾", "vec": "→",
+	"overrightarrow": "→", "overleftarrow": "←", "dot": "˙", "ddot": "¨",
+	"tilde": "~", "widetilde": "~", "check": "ˇ", "breve": "˘", "acute": "´",
+	"grave": "`", "overbrace": "⏞", "underline": "_", "underbrace": "⏟",
+}
+
+// texFonts maps font commands to the letter style of their argument
+var texFonts = map[string]string{
+	"mathrm": "normal", "mathup": "normal", "mathit": "italic", "mathbf": "bold",
+	"boldsymbol": "bold-italic", "bm": "bold-italic", "mathbb": "double-struck",
+	"mathcal": "script", "mathscr": "script", "mathfrak": "fraktur",
+	"mathsf": "sans-serif", "mathtt": "monospace",
+}
+
+// texDelimiters maps delimiter commands accepted by \left, \right and \big
+var texDelimiters = map[string]string{
+	"{": "{", "}": "}", "|": "‖", "lbrace": "{", "rbrace": "}", "langle": "⟨",
+	"rangle": "⟩", "lfloor": "⌊", "rfloor": "⌋", "lceil": "⌈", "rceil": "⌉",
+	"vert": "|", "Vert": "‖", "lvert": "|", "rvert": "|", "lVert": "‖", "rVert": "‖",
+	"backslash": "\\", "uparrow": "↑", "downarrow": "↓",
+}
+
+// mathAlphabets gives the first capital letter, small letter and digit of
+// each styled alphabet in the Mathematical Alphanumeric Symbols block
+var mathAlphabets = map[string][3]rune{
+	"bold":          {0x1D400, 0x1D41A, 0x1D7CE},
+	"italic":        {0x1D434, 0x1D44E, 0},
+	"bold-italic":   {0x1D468, 0x1D482, 0x1D7CE},
+	"script":        {0x1D49C, 0x1D4B6, 0},
+	"fraktur":       {0x1D504, 0x1D51E, 0},
+	"double-struck": {0x1D538, 0x1D552, 0x1D7D8},
+	"sans-serif":    {0x1D5A0, 0x1D5BA, 0x1D7E2},
+	"monospace":     {0x1D670, 0x1D68A, 0x1D7F6},
+}
+
+// mathAlphabetHoles are letters that Unicode encodes outside the block
+var mathAlphabetHoles = map[string]map[rune]rune{
+	"italic": {'h': 'ℎ'},
+	"script": {'B': 'ℬ', 'E': 'ℰ', 'F': 'ℱ', 'H': 'ℋ', 'I': 'ℐ', 'L': 'ℒ', 'M': 'ℳ',
+		'R': 'ℛ', 'e': 'ℯ', 'g': 'ℊ', 'o': 'ℴ'},
+	"fraktur":       {'C': 'ℭ', 'H': 'ℌ', 'I': 'ℑ', 'R': 'ℜ', 'Z': 'ℨ'},
+	"double-struck": {'C': 'ℂ', 'H': 'ℍ', 'N': 'ℕ', 'P': 'ℙ', 'Q': 'ℚ', 'R': 'ℝ', 'Z': 'ℤ'},
+}
+
+// mathFontRune returns the styled form of a letter or digit, or r unchanged
+func mathFontRune(font string, r rune) rune {
+	if hole, ok := mathAlphabetHoles[font][r]; ok {
+		return hole
+	}
+	alphabet, ok := mathAlphabets[font]
+	if !ok {
+		return r
+	}
+	switch {
+	case r >= 'A' && r <= 'Z':
+		return alphabet[0] + r - 'A'
+	case r >= 'a' && r <= 'z':
+		return alphabet[1] + r - 'a'
+	case r >= '0' && r <= '9' && alphabet[2] != 0:
+		return alphabet[2] + r - '0'
+	}
+	return r
+}
@@ -0,0 +1,152 @@
+// menu.go - Navigation menus from the site config and page frontmatter
+package sitegen
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// MenuItem is a menu entry declared in colade.yaml
+type MenuItem struct {
+	Title  string `yaml:"title" json:"title"`
+	URL    string `yaml:"url" json:"url"`
+	Weight int    `yaml:"weight" json:"weight"` // lower weights come first
+}
+
+// MenuEntry is a menu entry as seen by templates
+type MenuEntry struct {
+	Title  string
+	URL    string
+	Weight int
+	Active bool  // the entry links to the page being rendered
+	Page   *Page // the page that added itself through frontmatter, nil for config entries
+}
+
+// buildMenus merges the config menus with the pages that add themselves
+// through frontmatter, sorted by weight and then title
+func buildMenus(cfg map[string][]MenuItem, pages []*Page) map[string][]MenuEntry {
+	menus := make(map[string][]MenuEntry)
+	for name, items := range cfg {
+		for _, item := range items {
+			menus[name] = append(menus[name], MenuEntry{Title: item.Title, URL: item.URL, Weight: item.Weight})
+		}
+	}
+	for _, p := range pages {
+		for _, ref := range pageMenus(p.Meta["menu"]) {
+			title := ref.Title
+			if title == "" {
+				title = p.Title
+			}
+			menus[ref.Name] = append(menus[ref.Name], MenuEntry{Title: title, URL: p.URL, Weight: ref.Weight, Page: p})
+		}
+	}
+	for _, entries := range menus {
+		sort.SliceStable(entries, func(i, j int) bool {
+			if entries[i].Weight != entries[j].Weight {
+				return entries[i].Weight < entries[j].Weight
+			}
+			return entries[i].Title < entries[j].Title
+		})
+	}
+	return menus
+}
+
+// menuRef is a page's entry in one menu
+type menuRef struct {
+	Name   string
+	Title  string
+	Weight int
+}
+
+// pageMenus reads the frontmatter menu key: a menu name, a list of names, or
+// a map from names to an optional title and weight
+func pageMenus(v interface{}) []menuRef {
+	var refs []menuRef
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		for _, name := range metaStrings(v) {
+			refs = append(refs, menuRef{Name: name})
+		}
+		return refs
+	}
+	for name, opts := range m {
+		ref := menuRef{Name: name}
+		if opts, ok := opts.(map[string]interface{}); ok {
+			if title, ok := opts["title"].(string); ok {
+				ref.Title = title
+			}
+			ref.Weight = metaInt(opts["weight"])
+		}
+		refs = append(refs, ref)
+	}
+	sort.Slice(refs, func(i, j int) bool { return refs[i].Name < refs[j].Name })
+	return refs
+}
+
+// metaInt reads a frontmatter number, which YAML, TOML and JSON decode differently
+func metaInt(v interface{}) int {
+	switch n := v.(type) {
+	case int:
+		return n
+	case int64:
+		return int(n)
+	case uint64:
+		return int(n)
+	case float64:
+		return int(n)
+	}
+	return 0
+}
+
+// menusFor returns the menus shown on a page: entries from pages in other
+// languages are left out, and entries linking to the page are marked active
+func menusFor(menus map[string][]MenuEntry, lang, url string) map[string][]MenuEntry {
+	out := make(map[string][]MenuEntry, len(menus))
+	for name, entries := range menus {
+		list := make([]MenuEntry, 0, len(entries))
+		for _, e := range entries {
+			if e.Page != nil && e.Page.langCode() != lang {
+				continue
+			}
+			e.Active = url != "" && sameURL(e.URL, url)
+			list = append(list, e)
+		}
+		out[name] = list
+	}
+	return out
+}
+
+// sameURL compares root-relative URLs, treating "/blog/", "/blog/index.html"
+// and "/blog/index" as the same page
+func sameURL(a, b string) bool {
+	return normalizeURL(a) == normalizeURL(b)
+}
+
+func normalizeURL(u string) string {
+	if i := strings.IndexAny(u, "?#"); i >= 0 {
+		u = u[:i]
+	}
+	u = strings.TrimSuffix(u, ".html")
+	if u == "index" || strings.HasSuffix(u, "/index") {
+		u = strings.TrimSuffix(u, "index")
+	}
+	return strings.TrimSuffix(u, "/")
+}
+
+// writeMenus writes the menu entries of a language for context keys
+func writeMenus(w io.Writer, menus map[string][]MenuEntry, lang string) {
+	names := make([]string, 0, len(menus))
+	for name := range menus {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, e := range menus[name] {
+			if e.Page != nil && e.Page.langCode() == lang {
+				fmt.Fprintf(w, "menu\x00%s\x00%s\x00%s\x00%d\n", name, e.Title, e.URL, e.Weight)
+			}
+		}
+	}
+}
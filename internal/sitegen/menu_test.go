@@ -0,0 +1,88 @@
+package sitegen
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPageMenus(t *testing.T) {
+	tests := []struct {
+		meta interface{}
+		want []menuRef
+	}{
+		{"main", []menuRef{{Name: "main"}}},
+		{[]interface{}{"main", "footer"}, []menuRef{{Name: "main"}, {Name: "footer"}}},
+		{
+			map[string]interface{}{"main": map[string]interface{}{"title": "Docs", "weight": 3}, "footer": nil},
+			[]menuRef{{Name: "footer"}, {Name: "main", Title: "Docs", Weight: 3}},
+		},
+		{nil, nil},
+	}
+	for _, test := range tests {
+		if got := pageMenus(test.meta); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("pageMenus(%v) = %v, want %v", test.meta, got, test.want)
+		}
+	}
+}
+
+func TestSameURL(t *testing.T) {
+	for _, pair := range [][2]string{{"/", "/index.html"}, {"/blog/", "/blog/index.html"}, {"/about", "/about.html"}, {"/about.html#team", "/about.html"}} {
+		if !sameURL(pair[0], pair[1]) {
+			t.Errorf("expected %q and %q to be the same page", pair[0], pair[1])
+		}
+	}
+	if sameURL("/reindex.html", "/re.html") || sameURL("/blog/", "/blog/post.html") {
+		t.Errorf("expected different pages not to match")
+	}
+}
+
+func TestBuild_Menus(t *testing.T) {
+	inputDir := t.TempDir()
+	outputDir := t.TempDir()
+	writeTestFile(t, filepath.Join(inputDir, "colade.yaml"), `menus:
+  main:
+    - title: Home
+      url: /
+      weight: 1
+    - title: Source
+      url: https://github.com/example/site
+      weight: 100
+  footer:
+    - title: Imprint
+      url: /imprint.html
+`)
+	writeTestFile(t, filepath.Join(inputDir, "index.md"), "# Home")
+	writeTestFile(t, filepath.Join(inputDir, "about.md"), "---\ntitle: About us\nmenu:\n  main:\n    title: About\n    weight: 2\n---\n")
+	writeTestFile(t, filepath.Join(inputDir, "blog.md"), "---\ntitle: Blog\nmenu: main\n---\n")
+
+	if err := Build(BuildOptions{InputDir: inputDir, OutputDir: outputDir}); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	about := readTestFile(t, filepath.Join(outputDir, "about.html"))
+	want := `<li><a href="/blog.html">Blog</a></li><li><a href="/">Home</a></li><li><a href="/about.html" class="active" aria-current="page">About</a></li><li><a href="https://github.com/example/site">Source</a></li>`
+	if !strings.Contains(about, want) {
+		t.Errorf("expected main menu entries by weight with about active, got:\n%s", about)
+	}
+	if !strings.Contains(about, `<nav class="menu menu-footer">`) || !strings.Contains(about, `<a href="/imprint.html">Imprint</a>`) {
+		t.Errorf("expected the footer menu, got:\n%s", about)
+	}
+	if home := readTestFile(t, filepath.Join(outputDir, "index.html")); !strings.Contains(home, `<a href="/" class="active" aria-current="page">Home</a>`) {
+		t.Errorf("expected the home entry to be active on the index page, got:\n%s", home)
+	}
+
+	// Renaming a menu page rebuilds the other pages in an incremental build
+	blogPath := filepath.Join(inputDir, "blog.md")
+	writeTestFile(t, blogPath, "---\ntitle: Journal\nmenu: main\n---\n")
+	later := time.Now().Add(2 * time.Second)
+	os.Chtimes(blogPath, later, later)
+	if err := Build(BuildOptions{InputDir: inputDir, OutputDir: outputDir}); err != nil {
+		t.Fatalf("incremental Build failed: %v", err)
+	}
+	if about := readTestFile(t, filepath.Join(outputDir, "about.html")); !strings.Contains(about, `<a href="/blog.html">Journal</a>`) {
+		t.Errorf("expected the renamed entry on an unchanged page, got:\n%s", about)
+	}
+}
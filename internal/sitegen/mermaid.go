@@ -0,0 +1,102 @@
+// mermaid.go - Mermaid diagrams rendered by a script loaded only on pages that have them
+package sitegen
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"html"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+	mermaid "go.abhg.dev/goldmark/mermaid"
+)
+
+// MermaidScriptName is the name of a self-hosted Mermaid script in the
+// input root, which pages load instead of the CDN copy
+const MermaidScriptName = "mermaid.min.js"
+
+// defaultMermaidScript is the Mermaid script pages with diagrams load by default
+const defaultMermaidScript = "https://cdn.jsdelivr.net/npm/mermaid@10.6.0/dist/mermaid.min.js"
+
+// MermaidConfig configures Mermaid diagrams
+type MermaidConfig struct {
+	Theme  string `yaml:"theme" json:"theme"`   // Mermaid theme, e.g. "default", "dark", "forest", "neutral"
+	Script string `yaml:"script" json:"script"` // URL or root-relative path of the Mermaid script, see mermaidScript
+}
+
+// hasDiagrams reports whether a parsed page contains a Mermaid diagram
+func hasDiagrams(root ast.Node) bool {
+	found := false
+	ast.Walk(root, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if _, ok := n.(*mermaid.Block); ok && entering {
+			found = true
+			return ast.WalkStop, nil
+		}
+		return ast.WalkContinue, nil
+	})
+	return found
+}
+
+// mermaidTheme returns the configured Mermaid theme, "dark" for the built-in
+// dark theme and Mermaid's default otherwise
+func (mp *MarkdownProcessor) mermaidTheme() string {
+	switch {
+	case mp.config.Mermaid.Theme != "":
+		return mp.config.Mermaid.Theme
+	case mp.theme != nil && mp.theme.Dir == "" && mp.theme.Name == "dark":
+		return "dark"
+	}
+	return "default"
+}
+
+// mermaidScripts returns the script tags added to pages with diagrams
+func (mp *MarkdownProcessor) mermaidScripts() string {
+	src := mp.mermaidSrc
+	if src == "" {
+		src = defaultMermaidScript
+	}
+	init, _ := json.Marshal(map[string]interface{}{"startOnLoad": true, "theme": mp.mermaidTheme()})
+	return fmt.Sprintf("<script src=\"%s\"></script>\n<script>mermaid.initialize(%s);</script>\n", html.EscapeString(src), init)
+}
+
+// SetMermaidScript sets the script pages with diagrams load and its
+// compressed size, which the size report adds to them (0 if not hosted by the site)
+func (mp *MarkdownProcessor) SetMermaidScript(src string, gzipSize int) {
+	mp.mermaidSrc = src
+	mp.mermaidSize = gzipSize
+}
+
+// mermaidScript returns the Mermaid script of a site and its compressed size
+// when the site hosts it: the configured script, else a mermaid.min.js in the
+// input root, else the CDN copy
+func mermaidScript(cfg MermaidConfig, inputDir string, fileSet *FileSet) (string, int) {
+	src := cfg.Script
+	if src == "" {
+		src = defaultMermaidScript
+		if slices.Contains(fileSet.AssetFiles, MermaidScriptName) {
+			src = "/" + MermaidScriptName
+		}
+	}
+	if !isRootRelative(src) {
+		return src, 0
+	}
+	target, _ := splitURL(src)
+	asset := filepath.FromSlash(strings.TrimPrefix(target, "/"))
+	if !slices.Contains(fileSet.AssetFiles, asset) {
+		return src, 0
+	}
+	data, err := os.ReadFile(filepath.Join(inputDir, asset))
+	if err != nil {
+		return src, 0
+	}
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Write(data)
+	gz.Close()
+	return src, buf.Len()
+}
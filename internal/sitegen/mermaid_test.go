@@ -52,3 +52,135 @@ func TestBuildSite_MermaidChart(t *testing.T) {
 		t.Errorf("expected mermaid.js script in output, got: %s", data)
 	}
 }
+
+func TestBuild_MermaidOnDemand(t *testing.T) {
+	inputDir := t.TempDir()
+	outputDir := t.TempDir()
+	writeTestFile(t, filepath.Join(inputDir, "colade.yaml"), "mermaid:\n  theme: forest\n")
+	writeTestFile(t, filepath.Join(inputDir, "diagram.md"), "# Diagram\n\n```mermaid\ngraph TD\n  A --> B\n```\n")
+	writeTestFile(t, filepath.Join(inputDir, "plain.md"), "# Plain")
+
+	if err := Build(BuildOptions{InputDir: inputDir, OutputDir: outputDir, NoIncremental: true}); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	diagram := readTestFile(t, filepath.Join(outputDir, "diagram.html"))
+	for _, want := range []string{`<script src="` + defaultMermaidScript + `"></script>`, `mermaid.initialize({"startOnLoad":true,"theme":"forest"});`} {
+		if !strings.Contains(diagram, want) {
+			t.Errorf("expected diagram.html to contain %q, got:\n%s", want, diagram)
+		}
+	}
+	if plain := readTestFile(t, filepath.Join(outputDir, "plain.html")); strings.Contains(plain, "mermaid") {
+		t.Errorf("expected no Mermaid script on a page without diagrams, got:\n%s", plain)
+	}
+	if fileExists(filepath.Join(outputDir, MermaidScriptName)) {
+		t.Errorf("expected no %s in the output without a self-hosted copy", MermaidScriptName)
+	}
+}
+
+func TestMermaidScript(t *testing.T) {
+	inputDir := t.TempDir()
+	writeTestFile(t, filepath.Join(inputDir, MermaidScriptName), strings.Repeat("mermaid();", 1000))
+	writeTestFile(t, filepath.Join(inputDir, "js", "diagrams.js"), "mermaid();")
+	fileSet := &FileSet{AssetFiles: []string{MermaidScriptName, filepath.Join("js", "diagrams.js")}}
+
+	tests := []struct {
+		cfg      MermaidConfig
+		fileSet  *FileSet
+		want     string
+		withSize bool
+	}{
+		{MermaidConfig{}, &FileSet{}, defaultMermaidScript, false},
+		{MermaidConfig{}, fileSet, "/mermaid.min.js", true},
+		{MermaidConfig{Script: "/js/diagrams.js"}, fileSet, "/js/diagrams.js", true},
+		{MermaidConfig{Script: "/js/missing.js"}, fileSet, "/js/missing.js", false},
+		{MermaidConfig{Script: "https://example.com/mermaid.js"}, fileSet, "https://example.com/mermaid.js", false},
+	}
+	for _, test := range tests {
+		src, size := mermaidScript(test.cfg, inputDir, test.fileSet)
+		if src != test.want || (size > 0) != test.withSize {
+			t.Errorf("mermaidScript(%+v) = %q, %d, want %q (size counted: %t)", test.cfg, src, size, test.want, test.withSize)
+		}
+	}
+}
+
+func TestBuild_MermaidSelfHosted(t *testing.T) {
+	inputDir := t.TempDir()
+	outputDir := t.TempDir()
+	writeTestFile(t, filepath.Join(inputDir, "diagram.md"), "# Diagram\n\n```mermaid\ngraph TD\n  A --> B\n```\n")
+	writeTestFile(t, filepath.Join(inputDir, MermaidScriptName), "mermaid();")
+
+	if err := Build(BuildOptions{InputDir: inputDir, OutputDir: outputDir}); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	diagram := readTestFile(t, filepath.Join(outputDir, "diagram.html"))
+	if !strings.Contains(diagram, `<script src="/mermaid.min.js"></script>`) || strings.Contains(diagram, "cdn.jsdelivr.net") {
+		t.Errorf("expected the self-hosted Mermaid script, got:\n%s", diagram)
+	}
+
+	// Removing the self-hosted copy removes it from the output and pages load the CDN copy again
+	if err := os.Remove(filepath.Join(inputDir, MermaidScriptName)); err != nil {
+		t.Fatal(err)
+	}
+	if err := Build(BuildOptions{InputDir: inputDir, OutputDir: outputDir}); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	if fileExists(filepath.Join(outputDir, MermaidScriptName)) {
+		t.Errorf("expected %s to be removed from the output", MermaidScriptName)
+	}
+	if diagram := readTestFile(t, filepath.Join(outputDir, "diagram.html")); !strings.Contains(diagram, defaultMermaidScript) {
+		t.Errorf("expected the page to fall back to the CDN script, got:\n%s", diagram)
+	}
+}
+
+func TestBuild_IncrementalRemovesStaleGenerated(t *testing.T) {
+	inputDir := t.TempDir()
+	outputDir := t.TempDir()
+	writeTestFile(t, filepath.Join(inputDir, "index.md"), "# Home")
+	if err := Build(BuildOptions{InputDir: inputDir, OutputDir: outputDir}); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	// A file generated by an earlier build, e.g. an old bundled script
+	cachePath := getCachePath(outputDir)
+	cache, err := loadCache(cachePath)
+	if err != nil {
+		t.Fatalf("loadCache failed: %v", err)
+	}
+	cache.Generated = append(cache.Generated, MermaidScriptName)
+	if err := NewCacheManager(inputDir, outputDir, nil, false).SaveCache(cache); err != nil {
+		t.Fatalf("SaveCache failed: %v", err)
+	}
+	writeTestFile(t, filepath.Join(outputDir, MermaidScriptName), "stale")
+
+	if err := Build(BuildOptions{InputDir: inputDir, OutputDir: outputDir}); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	if fileExists(filepath.Join(outputDir, MermaidScriptName)) {
+		t.Errorf("expected the stale generated %s to be removed", MermaidScriptName)
+	}
+	if !fileExists(filepath.Join(outputDir, "index.html")) {
+		t.Errorf("expected index.html to be kept")
+	}
+}
+
+func TestMermaidTheme(t *testing.T) {
+	mp := NewMarkdownProcessor("")
+	if got := mp.mermaidTheme(); got != "default" {
+		t.Errorf("mermaidTheme() = %q, want default", got)
+	}
+	mp.SetTheme(&Theme{Name: "dark"})
+	if got := mp.mermaidTheme(); got != "dark" {
+		t.Errorf("mermaidTheme() with the dark theme = %q, want dark", got)
+	}
+}
+
+func TestCheckGzipSizeWithScripts(t *testing.T) {
+	page := filepath.Join(t.TempDir(), "page.html")
+	writeTestFile(t, page, "<p>small</p>")
+	out := make(chan string, 1)
+	CheckGzipSizeWithScripts(page, 20*1024, 14*1024, out)
+	msg := <-out
+	if !strings.Contains(msg, "KB scripts)") || !strings.Contains(msg, "[WARN]") {
+		t.Errorf("expected the script weight to count towards the budget, got %q", msg)
+	}
+}
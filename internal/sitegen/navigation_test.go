@@ -0,0 +1,86 @@
+package sitegen
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func post(title, date, tags string) string {
+	return "---\ntitle: " + title + "\ndate: " + date + "\ntags: [" + tags + "]\n---\n\nBody of " + title + ".\n"
+}
+
+func TestBuild_PrevNextNavigation(t *testing.T) {
+	inputDir := t.TempDir()
+	outputDir := t.TempDir()
+	writeTestFile(t, filepath.Join(inputDir, "index.md"), "# Home")
+	writeTestFile(t, filepath.Join(inputDir, "blog", "index.md"), "# Blog")
+	writeTestFile(t, filepath.Join(inputDir, "blog", "first.md"), post("First", "2025-01-01", "go"))
+	writeTestFile(t, filepath.Join(inputDir, "blog", "second.md"), post("Second", "2025-02-01", "rust"))
+	writeTestFile(t, filepath.Join(inputDir, "blog", "third.md"), post("Third", "2025-03-01", "go"))
+	writeTestFile(t, filepath.Join(inputDir, "notes", "other.md"), post("Other", "2025-02-15", "misc"))
+
+	if err := Build(BuildOptions{InputDir: inputDir, OutputDir: outputDir}); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	second := readTestFile(t, filepath.Join(outputDir, "blog", "second.html"))
+	if !strings.Contains(second, `rel="prev" href="/blog/first.html">&larr; First</a>`) {
+		t.Errorf("expected link to previous post, got:\n%s", second)
+	}
+	if !strings.Contains(second, `rel="next" href="/blog/third.html">Third &rarr;</a>`) {
+		t.Errorf("expected link to next post, got:\n%s", second)
+	}
+	if strings.Contains(second, "Other") {
+		t.Errorf("pages from other sections should not be linked, got:\n%s", second)
+	}
+
+	first := readTestFile(t, filepath.Join(outputDir, "blog", "first.html"))
+	if strings.Contains(first, `rel="prev"`) {
+		t.Errorf("oldest post should have no previous link")
+	}
+	if !strings.Contains(first, `<aside class="related">`) || !strings.Contains(first, `<a href="/blog/third.html">Third</a>`) {
+		t.Errorf("expected Third as related post by tag, got:\n%s", first)
+	}
+	if home := readTestFile(t, filepath.Join(outputDir, "index.html")); strings.Contains(home, "page-nav") {
+		t.Errorf("undated pages should have no navigation, got:\n%s", home)
+	}
+
+	// Moving third before first changes second's neighbours, so second is
+	// rebuilt in an incremental build even though its source is unchanged
+	thirdPath := filepath.Join(inputDir, "blog", "third.md")
+	writeTestFile(t, thirdPath, post("Third", "2024-12-01", "go"))
+	later := time.Now().Add(2 * time.Second)
+	os.Chtimes(thirdPath, later, later)
+
+	if err := Build(BuildOptions{InputDir: inputDir, OutputDir: outputDir}); err != nil {
+		t.Fatalf("incremental Build failed: %v", err)
+	}
+	second = readTestFile(t, filepath.Join(outputDir, "blog", "second.html"))
+	if strings.Contains(second, `rel="next"`) {
+		t.Errorf("expected second to become the newest post, got:\n%s", second)
+	}
+	first = readTestFile(t, filepath.Join(outputDir, "blog", "first.html"))
+	if !strings.Contains(first, `rel="prev" href="/blog/third.html"`) {
+		t.Errorf("expected first to link back to the moved post, got:\n%s", first)
+	}
+}
+
+func TestSite_RelatedLimit(t *testing.T) {
+	inputDir := t.TempDir()
+	writeTestFile(t, filepath.Join(inputDir, "a.md"), post("A", "2025-01-01", "go, web, cli"))
+	writeTestFile(t, filepath.Join(inputDir, "b.md"), post("B", "2025-01-02", "go"))
+	writeTestFile(t, filepath.Join(inputDir, "c.md"), post("C", "2025-01-03", "go, web"))
+	writeTestFile(t, filepath.Join(inputDir, "d.md"), post("D", "2025-01-04", "python"))
+
+	site, err := LoadSite(NewMarkdownProcessor("default"), &Config{Related: 1}, inputDir, []string{"a.md", "b.md", "c.md", "d.md"})
+	if err != nil {
+		t.Fatalf("LoadSite failed: %v", err)
+	}
+	related := site.Page("a.md").related
+	if len(related) != 1 || related[0].Title != "C" {
+		t.Errorf("expected C as the single most related page, got %v", related)
+	}
+}
@@ -0,0 +1,53 @@
+// notfound.go - The site's 404 page, built from 404.md with its own layout
+package sitegen
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// NotFoundLayout is the layout used for 404.md, looked up as templates/404.html
+const NotFoundLayout = "404"
+
+// isNotFoundPage reports whether a markdown file is the 404 page of the
+// site or, on multilingual sites, of a language
+func isNotFoundPage(languages *Languages, relPath string) bool {
+	_, base, _ := languages.Split(relPath)
+	return strings.TrimSuffix(filepath.ToSlash(base), filepath.Ext(base)) == "404"
+}
+
+// rootRelativeLinks rewrites relative URLs in a page output to dir as
+// root-relative ones. Servers return the 404 page for any missing URL, so
+// links relative to its own location would break below the site root.
+func rootRelativeLinks(page []byte, dir string) []byte {
+	dir = path.Clean("/" + filepath.ToSlash(dir))
+	return rewriteURLs(page, func(u string) string {
+		if !isRelativeURL(u) {
+			return u
+		}
+		target, suffix := splitURL(u)
+		abs := path.Join(dir, target)
+		if strings.HasSuffix(target, "/") && abs != "/" {
+			abs += "/"
+		}
+		return abs + suffix
+	})
+}
+
+// nearestNotFound finds the 404.html closest to a missing URL, so that a
+// language prefix such as /ga/ gets its own translated 404 page
+func nearestNotFound(root, urlPath string) string {
+	dir := path.Dir(path.Clean("/" + urlPath))
+	for {
+		p := filepath.Join(root, filepath.FromSlash(dir), "404.html")
+		if info, err := os.Stat(p); err == nil && !info.IsDir() {
+			return p
+		}
+		if dir == "/" {
+			return ""
+		}
+		dir = path.Dir(dir)
+	}
+}
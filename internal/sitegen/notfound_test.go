@@ -0,0 +1,107 @@
+package sitegen
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRootRelativeLinks(t *testing.T) {
+	page := `<a href="docs/intro.html#setup">x</a><img src="img/logo.png"><a href="../about.html">y</a>` +
+		`<link href="/style.css"><a href="https://example.com/">z</a><a href="#top">t</a><a href="mailto:a@b.c">m</a>`
+	got := string(rootRelativeLinks([]byte(page), "."))
+	want := `<a href="/docs/intro.html#setup">x</a><img src="/img/logo.png"><a href="/about.html">y</a>` +
+		`<link href="/style.css"><a href="https://example.com/">z</a><a href="#top">t</a><a href="mailto:a@b.c">m</a>`
+	if got != want {
+		t.Errorf("rootRelativeLinks = %q, want %q", got, want)
+	}
+	if got := string(rootRelativeLinks([]byte(`<a href="guide/">g</a>`), "ga")); got != `<a href="/ga/guide/">g</a>` {
+		t.Errorf("rootRelativeLinks below a prefix = %q", got)
+	}
+}
+
+func TestBuild_NotFoundPage(t *testing.T) {
+	inputDir := t.TempDir()
+	outputDir := t.TempDir()
+	writeTestFile(t, filepath.Join(inputDir, "index.md"), "# Home")
+	writeTestFile(t, filepath.Join(inputDir, "header.md"), "[Docs](docs/intro.md)")
+	writeTestFile(t, filepath.Join(inputDir, "docs", "intro.md"), "# Intro")
+	writeTestFile(t, filepath.Join(inputDir, "404.md"), "---\ntitle: Lost\n---\nTry the [introduction](docs/intro.md).\n\n![map](img/map.png)")
+
+	if err := Build(BuildOptions{InputDir: inputDir, OutputDir: outputDir, RSSURL: "https://example.com"}); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	out := readTestFile(t, filepath.Join(outputDir, "404.html"))
+	for _, want := range []string{
+		`<body class="not-found">`,
+		`<meta name="robots" content="noindex">`,
+		`<link rel="stylesheet" href="/style.css">`,
+		`<title>Lost</title>`,
+		`<a href="/docs/intro.html">Docs</a>`,
+		`<a href="/docs/intro.html">introduction</a>`,
+		`<img src="/img/map.png" alt="map">`,
+		`<a href="/">Return to home</a>`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected 404.html to contain %q, got:\n%s", want, out)
+		}
+	}
+	if feed := readTestFile(t, filepath.Join(outputDir, "feed.xml")); strings.Contains(feed, "404.html") {
+		t.Errorf("expected the 404 page to be left out of the feed, got:\n%s", feed)
+	}
+	// The 404 page is only built with its own layout
+	if page := readTestFile(t, filepath.Join(outputDir, "docs", "intro.html")); strings.Contains(page, "not-found") {
+		t.Errorf("expected other pages to use the page layout")
+	}
+}
+
+func TestBuild_NotFoundLayoutOverride(t *testing.T) {
+	inputDir := t.TempDir()
+	outputDir := t.TempDir()
+	writeTestFile(t, filepath.Join(inputDir, "404.md"), "Nothing here.")
+	writeTestFile(t, filepath.Join(inputDir, "templates", "404.html"), `<main class="custom-404">{{ .Content }}</main>`)
+
+	if err := Build(BuildOptions{InputDir: inputDir, OutputDir: outputDir}); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	if out := readTestFile(t, filepath.Join(outputDir, "404.html")); !strings.Contains(out, `<main class="custom-404"><p>Nothing here.</p>`) {
+		t.Errorf("expected the site's 404 layout to be used, got:\n%s", out)
+	}
+}
+
+func TestCustomFileServer_NotFound(t *testing.T) {
+	root := t.TempDir()
+	writeTestFile(t, filepath.Join(root, "404.html"), "<p>site 404</p>")
+	writeTestFile(t, filepath.Join(root, "ga", "404.html"), "<p>ga 404</p>")
+	cfs := &customFileServer{root: http.Dir(root), dir: root}
+
+	tests := map[string]string{
+		"/missing.html":      "<p>site 404</p>",
+		"/docs/deep/page":    "<p>site 404</p>",
+		"/ga/missing.html":   "<p>ga 404</p>",
+		"/ga/docs/page.html": "<p>ga 404</p>",
+	}
+	for path, want := range tests {
+		rec := httptest.NewRecorder()
+		cfs.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("%s: status = %d, want 404", path, rec.Code)
+		}
+		if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+			t.Errorf("%s: Content-Type = %q", path, ct)
+		}
+		if rec.Body.String() != want {
+			t.Errorf("%s: body = %q, want %q", path, rec.Body.String(), want)
+		}
+	}
+
+	os.Remove(filepath.Join(root, "404.html"))
+	rec := httptest.NewRecorder()
+	cfs.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/missing.html", nil))
+	if rec.Code != http.StatusNotFound || !strings.Contains(rec.Body.String(), "404 - Page Not Found") {
+		t.Errorf("expected the built-in 404 page, got %d:\n%s", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,168 @@
+// partials.go - Header and footer partials with per-directory overrides
+package sitegen
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// Partials holds the rendered header and footer for each directory of a site.
+// A header or footer in a subdirectory overrides its parent's for every page
+// below it. On multilingual sites a translated header or footer (header.ga.md
+// or ga/header.md) overrides the untranslated one for pages in its language.
+type Partials struct {
+	inputDir   string
+	languages  *Languages
+	headerBase string
+	footerBase string
+	headers    map[string]partial // see partialKey
+	footers    map[string]partial
+}
+
+type partial struct {
+	html []byte
+	dep  string // cache key of the source file
+}
+
+// LoadPartials renders the header and footer files found in markdownFiles.
+// headerFile and footerFile replace the root header.md and footer.md, and
+// their base names are used for the subdirectory overrides.
+func LoadPartials(
+	mp *MarkdownProcessor,
+	inputDir string,
+	markdownFiles []string,
+	headerFile, footerFile string,
+	noHeader, noFooter bool,
+) (*Partials, error) {
+	p := &Partials{
+		inputDir:  inputDir,
+		languages: mp.languages,
+		headers:   make(map[string]partial),
+		footers:   make(map[string]partial),
+	}
+	if !noHeader {
+		p.headerBase = "header.md"
+		if headerFile != "" {
+			p.headerBase = filepath.Base(headerFile)
+		}
+	}
+	if !noFooter {
+		p.footerBase = "footer.md"
+		if footerFile != "" {
+			p.footerBase = filepath.Base(footerFile)
+		}
+	}
+
+	for _, f := range markdownFiles {
+		lang, base, explicit := p.languages.Split(f)
+		key := partialKey(path.Dir(filepath.ToSlash(base)), lang, explicit)
+		switch filepath.Base(base) {
+		case p.headerBase:
+			if err := p.load(mp, p.headers, key, filepath.Join(inputDir, f)); err != nil {
+				return nil, err
+			}
+		case p.footerBase:
+			if err := p.load(mp, p.footers, key, filepath.Join(inputDir, f)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	// Explicit header/footer files always apply at the root
+	if headerFile != "" && !noHeader {
+		if err := p.load(mp, p.headers, ".", headerFile); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+	if footerFile != "" && !noFooter {
+		if err := p.load(mp, p.footers, ".", footerFile); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// partialKey keys partials by slash-separated directory ("." for the input
+// root), followed by the language code for translated partials
+func partialKey(dir string, lang *LanguageConfig, explicit bool) string {
+	if lang == nil || !explicit {
+		return dir
+	}
+	return dir + "#" + lang.Code
+}
+
+func (p *Partials) load(mp *MarkdownProcessor, set map[string]partial, key, src string) error {
+	content, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	// Links are resolved from the partial's directory and made root-relative,
+	// since the partial is shown on pages at any depth
+	relPath := depKey(p.inputDir, src)
+	if filepath.IsAbs(relPath) {
+		relPath = filepath.Base(src)
+	}
+	html, _, err := mp.renderPageMarkdown(content, relPath)
+	if err != nil {
+		return fmt.Errorf("failed to render partial '%s': %w", src, err)
+	}
+	html = rootRelativeLinks(html, filepath.Dir(mp.baseOutputPath(relPath)))
+	set[key] = partial{html: html, dep: depKey(p.inputDir, src)}
+	return nil
+}
+
+// IsPartial reports whether a markdown file is a header, footer or include
+// file rather than a page
+func (p *Partials) IsPartial(relPath string) bool {
+	if isIncludeFile(relPath) {
+		return true
+	}
+	_, base, _ := p.languages.Split(relPath)
+	base = filepath.Base(base)
+	return (p.headerBase != "" && base == p.headerBase) || (p.footerBase != "" && base == p.footerBase)
+}
+
+// Pages filters the header, footer and include files out of markdownFiles
+func (p *Partials) Pages(markdownFiles []string) []string {
+	var pages []string
+	for _, f := range markdownFiles {
+		if !p.IsPartial(f) {
+			pages = append(pages, f)
+		}
+	}
+	return pages
+}
+
+// ForPage returns the header and footer that apply to a page, and the cache
+// keys of the files they were rendered from
+func (p *Partials) ForPage(relPath string) (headerHTML, footerHTML []byte, deps []string) {
+	lang, base, _ := p.languages.Split(relPath)
+	if h, ok := nearestPartial(p.headers, base, lang); ok {
+		headerHTML = h.html
+		deps = append(deps, h.dep)
+	}
+	if f, ok := nearestPartial(p.footers, base, lang); ok {
+		footerHTML = f.html
+		deps = append(deps, f.dep)
+	}
+	return headerHTML, footerHTML, deps
+}
+
+// nearestPartial walks up from the page's directory to the input root,
+// preferring a partial in the page's language in each directory
+func nearestPartial(set map[string]partial, relPath string, lang *LanguageConfig) (partial, bool) {
+	dir := path.Dir(filepath.ToSlash(relPath))
+	for {
+		if pt, ok := set[partialKey(dir, lang, true)]; ok {
+			return pt, true
+		}
+		if pt, ok := set[dir]; ok {
+			return pt, true
+		}
+		if dir == "." || dir == "/" {
+			return partial{}, false
+		}
+		dir = path.Dir(dir)
+	}
+}
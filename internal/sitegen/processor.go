@@ -4,14 +4,16 @@ package sitegen
 import (
 	"bytes"
 	"fmt"
+	"html/template"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
 	"time"
 
 	"github.com/yuin/goldmark"
-	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/ast"
 	"github.com/yuin/goldmark/parser"
-	"github.com/yuin/goldmark/renderer/html"
 	"github.com/yuin/goldmark/text"
 	"go.abhg.dev/goldmark/frontmatter"
 	mermaid "go.abhg.dev/goldmark/mermaid"
@@ -21,25 +23,170 @@ import (
 type MarkdownProcessor struct {
 	md          goldmark.Markdown
 	templateOpt string
+	config      *Config
+	theme       *Theme
+	site        *Site
+	shortcodes  *Shortcodes
+	languages   *Languages
+	images      *ImageSet
+	includes    map[string]string   // markdown files by includeKey, see SetIncludeFiles
+	sanitizer   *htmlSanitizer      // nil unless safe HTML mode is on
+	deps        map[string][]string // files read while rendering each page
+	listings    map[string]bool     // pages that read .Site.Pages, see contextKey
+	layout      *template.Template
+	layoutErr   error
+	notFound    *template.Template // layout of 404 pages, see notFoundLayout
+	mermaidSrc  string             // Mermaid script, see SetMermaidScript
+	mermaidSize int                // compressed size of a site-hosted Mermaid script
 }
 
-// NewMarkdownProcessor creates a new markdown processor
+// NewMarkdownProcessor creates a new markdown processor with the default site config
 func NewMarkdownProcessor(templateOpt string) *MarkdownProcessor {
+	return NewMarkdownProcessorWithConfig(templateOpt, &Config{})
+}
+
+// NewMarkdownProcessorWithConfig creates a markdown processor for a site config
+func NewMarkdownProcessorWithConfig(templateOpt string, cfg *Config) *MarkdownProcessor {
+	extensions := append(cfg.Markdown.extensions(),
+		&mermaid.Extender{RenderMode: mermaid.RenderModeClient, NoScript: true},
+		&highlighting{config: cfg.Highlight},
+		&mdLinks{},
+		&wikiLinks{},
+		&responsiveImages{},
+		&callouts{},
+		&safeHTML{},
+		&frontmatter.Extender{
+			Mode: frontmatter.SetMetadata,
+		},
+	)
+	if !cfg.Math.Disabled {
+		extensions = append(extensions, &mathematics{})
+	}
 	return &MarkdownProcessor{
 		md: goldmark.New(
-			goldmark.WithExtensions(
-				extension.GFM,
-				&mermaid.Extender{},
-				&frontmatter.Extender{
-					Mode: frontmatter.SetMetadata,
-				},
-			),
-			goldmark.WithRendererOptions(
-				html.WithUnsafe(),
-			),
+			goldmark.WithExtensions(extensions...),
+			goldmark.WithParserOptions(cfg.Markdown.parserOptions()...),
+			goldmark.WithRendererOptions(cfg.Markdown.rendererOptions()...),
 		),
 		templateOpt: templateOpt,
+		config:      cfg,
+		shortcodes:  bundledShortcodes,
+		sanitizer:   newHTMLSanitizer(cfg.SafeHTML),
+		deps:        make(map[string][]string),
+		listings:    make(map[string]bool),
+	}
+}
+
+// SetTheme sets the theme that layouts are resolved from (the built-in default theme if unset)
+func (mp *MarkdownProcessor) SetTheme(theme *Theme) {
+	mp.theme = theme
+	mp.layout, mp.layoutErr = nil, nil
+}
+
+// SetSite sets the page index used for links between pages
+func (mp *MarkdownProcessor) SetSite(site *Site) {
+	mp.site = site
+}
+
+// SetShortcodes sets the shortcode templates (the bundled ones if unset)
+func (mp *MarkdownProcessor) SetShortcodes(sc *Shortcodes) {
+	mp.shortcodes = sc
+}
+
+// SetLanguages sets the languages of a multilingual site
+func (mp *MarkdownProcessor) SetLanguages(languages *Languages) {
+	mp.languages = languages
+}
+
+// SetImages sets the processed images that markdown images get srcset and
+// dimensions from
+func (mp *MarkdownProcessor) SetImages(images *ImageSet) {
+	mp.images = images
+}
+
+// outputPath returns the output path of a markdown file relative to the output directory
+func (mp *MarkdownProcessor) outputPath(relPath string) string {
+	return pageOutputPath(mp.languages, relPath, mp.config.PrettyURLs)
+}
+
+// baseOutputPath returns the output path of a markdown file without pretty
+// URLs, which the relative URLs on the page are resolved against
+func (mp *MarkdownProcessor) baseOutputPath(relPath string) string {
+	return mp.languages.OutputPath(relPath)
+}
+
+// urlPath returns the root-relative URL of the page rendered from a markdown file
+func (mp *MarkdownProcessor) urlPath(relPath string) string {
+	u := "/" + filepath.ToSlash(mp.outputPath(relPath))
+	if mp.config.PrettyURLs {
+		u = prettyLink(u)
 	}
+	return u
+}
+
+// contentDeps lists the files, as cache keys, read by shortcodes or through
+// .Site.Data when a page was last rendered
+func (mp *MarkdownProcessor) contentDeps(relPath string) []string {
+	return mp.deps[relPath]
+}
+
+// applySite adds the site-derived fields for a page to its template data
+func (mp *MarkdownProcessor) applySite(data *PageData, relPath string) {
+	page := mp.site.Page(relPath)
+	if page == nil {
+		return
+	}
+	data.Page = page
+	data.Translations = page.translations
+	data.Breadcrumbs = page.breadcrumbs
+	if mp.config.Breadcrumbs.JSONLD {
+		base := mp.config.BaseURL
+		if base == "" {
+			base = mp.config.BasePath
+		}
+		data.BreadcrumbsJSONLD = breadcrumbsJSONLD(page.breadcrumbs, base)
+	}
+	data.Prev = page.prev
+	data.Next = page.next
+	data.Related = page.related
+	data.Backlinks = page.backlinks
+}
+
+// contextKey identifies the site-derived data of a page, see Site.ContextKey
+func (mp *MarkdownProcessor) contextKey(relPath string) string {
+	return mp.site.ContextKey(relPath, mp.listings[relPath])
+}
+
+// pageLayout parses the selected layout once and reuses it for every page
+func (mp *MarkdownProcessor) pageLayout() *template.Template {
+	if mp.layout == nil && mp.layoutErr == nil {
+		theme := mp.theme
+		if theme == nil {
+			theme = &Theme{Name: "default"}
+		}
+		mp.layout, mp.layoutErr = theme.Layout(mp.templateOpt)
+		if mp.layoutErr != nil {
+			fmt.Printf("[Template] Warning: could not load template %q: %v\n", mp.templateOpt, mp.layoutErr)
+		}
+	}
+	return mp.layout
+}
+
+// notFoundLayout parses the 404 layout once, falling back to the page layout
+func (mp *MarkdownProcessor) notFoundLayout() *template.Template {
+	if mp.notFound == nil {
+		theme := mp.theme
+		if theme == nil {
+			theme = &Theme{Name: "default"}
+		}
+		layout, err := theme.Layout(NotFoundLayout)
+		if err != nil {
+			fmt.Printf("[Template] Warning: could not load the 404 template: %v\n", err)
+			layout = mp.pageLayout()
+		}
+		mp.notFound = layout
+	}
+	return mp.notFound
 }
 
 // ProcessMarkdownFile converts a single markdown file to HTML
@@ -50,21 +197,108 @@ func (mp *MarkdownProcessor) ProcessMarkdownFile(
 	headerHTML, footerHTML []byte,
 ) error {
 	src := filepath.Join(inputDir, relPath)
-	dst := filepath.Join(outputDir, relPath)
-	dst = dst[:len(dst)-len(filepath.Ext(dst))] + ".html"
+	dst := filepath.Join(outputDir, mp.outputPath(relPath))
 
 	content, err := parseMarkdownFile(src)
 	if err != nil {
 		return fmt.Errorf("failed to read markdown file '%s': %w", relPath, err)
 	}
 
-	content = replaceMdLinks(content)
+	site := &SiteView{site: mp.site, url: mp.urlPath(relPath)}
+	if lang, _, _ := mp.languages.Split(relPath); lang != nil {
+		site.lang = lang.Code
+	}
+	content, shortcodes, err := mp.expandShortcodes(content, inputDir, relPath, site)
+	if err != nil {
+		return err
+	}
+
+	root, content, metaData := mp.parseMarkdown(content, relPath)
+	katex := mp.katexPage(metaData) && useKaTeX(root)
+	warnUnresolvedWikiLinks(root, relPath)
+	warnRemovedHTML(root, relPath)
+	toc := collectTOC(root, content)
+	summary := summarize(mp.md.Renderer(), root, content, metaData)
+	words := countWords(root, content)
+	if mp.config.HeadingAnchors {
+		addHeadingAnchors(root)
+	}
 	var buf bytes.Buffer
+	if err := mp.md.Renderer().Render(&buf, content, root); err != nil {
+		return fmt.Errorf("failed to render markdown '%s': %w", relPath, err)
+	}
 
+	body := shortcodes.restore(buf.Bytes())
+	diagrams := hasDiagrams(root)
+	if diagrams {
+		body = append(body, mp.mermaidScripts()...)
+	}
+	if katex {
+		body = append(body, mp.katexAssets()...)
+	}
+	data := newPageData(body, headerHTML, footerHTML, metaData)
+	data.Summary = template.HTML(shortcodes.restore([]byte(summary.HTML)))
+	data.WordCount = words
+	data.ReadingTime = readingTime(words)
+	if tocEnabled(metaData) {
+		minDepth, maxDepth := mp.config.TOC.depths()
+		data.TOC = template.HTML(renderTOC(toc, minDepth, maxDepth))
+	}
+	data.Site = site
+	if lang, _, _ := mp.languages.Split(relPath); lang != nil {
+		data.Language = lang
+		data.I18n = mp.languages.Strings(lang)
+	}
+	mp.applySite(&data, relPath)
+	var htmlOut []byte
+	if isNotFoundPage(mp.languages, relPath) {
+		htmlOut = rootRelativeLinks(renderHTMLPage(mp.notFoundLayout(), data), filepath.Dir(mp.outputPath(relPath)))
+		htmlOut = withBasePath(htmlOut, mp.config.BasePath)
+	} else {
+		data.SEO = mp.seoTags(relPath, &data, summary.Text)
+		htmlOut = renderHTMLPage(mp.pageLayout(), data)
+		if mp.outputPath(relPath) != mp.baseOutputPath(relPath) {
+			htmlOut = prettyRelativeURLs(htmlOut)
+		}
+		if mp.config.RelativeURLs {
+			htmlOut = relativeURLs(htmlOut, mp.outputPath(relPath))
+		} else {
+			htmlOut = withBasePath(htmlOut, mp.config.BasePath)
+		}
+	}
+	mp.deps[relPath] = slices.Concat(shortcodes.deps, site.dataDeps(), imageDeps(root))
+	mp.listings[relPath] = site.usedPages
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return fmt.Errorf("failed to create output dir for '%s': %w", relPath, err)
+	}
+
+	if err := os.WriteFile(dst, htmlOut, 0644); err != nil {
+		return fmt.Errorf("failed to write HTML file '%s': %w", relPath, err)
+	}
+
+	scriptSize := 0
+	if diagrams {
+		scriptSize = mp.mermaidSize
+	}
+	CheckGzipSizeWithScripts(dst, scriptSize, sizeThreshold, sizeOut)
+	return nil
+}
+
+// parseMarkdown parses the markdown source of the page at relPath ("" for
+// markdown that is not a page) and returns the document, the source it was
+// parsed from and its frontmatter
+func (mp *MarkdownProcessor) parseMarkdown(content []byte, relPath string) (ast.Node, []byte, map[string]interface{}) {
 	parserCtx := parser.NewContext()
-	md := mp.md
+	parserCtx.Set(mdLinkKey, func(dest string) string { return mp.mdLinkURL(relPath, dest) })
+	if sanitizer := mp.pageSanitizer(relPath); sanitizer != nil {
+		parserCtx.Set(safeHTMLKey, sanitizer)
+	}
+	parserCtx.Set(responsiveImageKey, func(dest string) *responsiveImage { return mp.responsiveImage(relPath, dest) })
+	if mp.site != nil && relPath != "" {
+		parserCtx.Set(wikiLinkKey, func(target string) string { return mp.wikiLinkURL(relPath, target) })
+	}
 	textReader := text.NewReader(content)
-	root := md.Parser().Parse(textReader, parser.WithContext(parserCtx))
+	root := mp.md.Parser().Parse(textReader, parser.WithContext(parserCtx))
 
 	// Extract meta from root.Meta()
 	var metaData map[string]interface{}
@@ -74,22 +308,23 @@ func (mp *MarkdownProcessor) ProcessMarkdownFile(
 	if metaData == nil {
 		metaData = map[string]interface{}{}
 	}
+	return root, content, metaData
+}
 
-	if err := md.Renderer().Render(&buf, content, root); err != nil {
-		return fmt.Errorf("failed to render markdown '%s': %w", relPath, err)
-	}
-
-	htmlOut := renderHTMLPage(buf.Bytes(), mp.templateOpt, headerHTML, footerHTML, metaData)
-	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
-		return fmt.Errorf("failed to create output dir for '%s': %w", relPath, err)
-	}
+// renderMarkdown converts markdown source to an HTML fragment and returns its frontmatter
+func (mp *MarkdownProcessor) renderMarkdown(content []byte) ([]byte, map[string]interface{}, error) {
+	return mp.renderPageMarkdown(content, "")
+}
 
-	if err := os.WriteFile(dst, htmlOut, 0644); err != nil {
-		return fmt.Errorf("failed to write HTML file '%s': %w", relPath, err)
+// renderPageMarkdown converts markdown that is part of the page at relPath,
+// resolving its links from there
+func (mp *MarkdownProcessor) renderPageMarkdown(content []byte, relPath string) ([]byte, map[string]interface{}, error) {
+	root, content, metaData := mp.parseMarkdown(content, relPath)
+	var buf bytes.Buffer
+	if err := mp.md.Renderer().Render(&buf, content, root); err != nil {
+		return nil, nil, err
 	}
-
-	CheckGzipSize(dst, sizeThreshold, sizeOut)
-	return nil
+	return buf.Bytes(), metaData, nil
 }
 
 // ProcessAssetFile copies a single asset file
@@ -108,40 +343,47 @@ type IncrementalBuilder struct {
 	cache         *cacheFile
 	newCache      *cacheFile
 	seen          map[string]bool
-	templateOpt   string
 }
 
 // NewIncrementalBuilder creates a new incremental builder
-func NewIncrementalBuilder(inputDir, outputDir string, sizeThreshold int, cache *cacheFile, templateOpt string) *IncrementalBuilder {
+func NewIncrementalBuilder(inputDir, outputDir string, sizeThreshold int, cache *cacheFile, processor *MarkdownProcessor) *IncrementalBuilder {
 	return &IncrementalBuilder{
-		processor:     NewMarkdownProcessor(templateOpt),
+		processor:     processor,
 		inputDir:      inputDir,
 		outputDir:     outputDir,
 		sizeThreshold: sizeThreshold,
 		cache:         cache,
 		newCache:      newCache(),
 		seen:          make(map[string]bool),
-		templateOpt:   templateOpt,
 	}
 }
 
-// ProcessMarkdownFiles processes all markdown files incrementally
+// ProcessMarkdownFiles processes all markdown files incrementally. A page is
+// rebuilt when it, the header/footer it uses, the files it reads through
+// shortcodes or .Site.Data, or the pages it links to as neighbours have changed.
 func (ib *IncrementalBuilder) ProcessMarkdownFilesWithHeaderFooter(
-	markdownFiles []string, sizeOut chan<- string, headerHTML, footerHTML []byte,
+	markdownFiles []string, sizeOut chan<- string, partials *Partials,
 ) error {
 	for _, relPath := range markdownFiles {
 		src := filepath.Join(ib.inputDir, relPath)
-		dst := filepath.Join(ib.outputDir, relPath)
-		dst = dst[:len(dst)-len(filepath.Ext(dst))] + ".html"
+		dst := filepath.Join(ib.outputDir, ib.processor.outputPath(relPath))
 		mtime := getMtime(src)
 		ib.seen[relPath] = true
+		headerHTML, footerHTML, deps := partials.ForPage(relPath)
 
+		// Files read by shortcodes or as data, and whether the page lists the
+		// site's pages, are only known from the last time it was rendered
 		prev, ok := ib.cache.Files[relPath]
-		if !ok || prev.Mtime != mtime {
+		ib.processor.listings[relPath] = prev.Listing
+		context := ib.processor.contextKey(relPath)
+		depTimes := depMtimes(ib.inputDir, append(deps, slices.Collect(maps.Keys(prev.Deps))...))
+		if !ok || prev.Mtime != mtime || depsChanged(prev, depTimes) || prev.Context != context {
 			fmt.Printf("[IncBuild] %s -> %s (changed/new)\n", relPath, dst)
 			if err := ib.processor.ProcessMarkdownFile(ib.inputDir, ib.outputDir, relPath, ib.sizeThreshold, sizeOut, headerHTML, footerHTML); err != nil {
 				return err
 			}
+			depTimes = depMtimes(ib.inputDir, append(deps, ib.processor.contentDeps(relPath)...))
+			context = ib.processor.contextKey(relPath)
 		} else {
 			fmt.Printf("[IncBuild] %s unchanged, skipping\n", relPath)
 			sizeOut <- ""
@@ -150,7 +392,7 @@ func (ib *IncrementalBuilder) ProcessMarkdownFilesWithHeaderFooter(
 		if err != nil {
 			return fmt.Errorf("failed to get relative path: %w", err)
 		}
-		ib.newCache.Files[relPath] = cacheFileEntry{Mtime: mtime, Output: outputPath}
+		ib.newCache.Files[relPath] = cacheFileEntry{Mtime: mtime, Output: outputPath, Deps: depTimes, Context: context, Listing: ib.processor.listings[relPath]}
 	}
 	return nil
 }
@@ -192,6 +434,25 @@ func (ib *IncrementalBuilder) CleanupRemovedFiles() {
 	}
 }
 
+// CleanupGenerated removes output files generated by the previous build, e.g.
+// theme assets, that this build no longer generates
+func (ib *IncrementalBuilder) CleanupGenerated(generated []string) {
+	keep := make(map[string]bool, len(generated))
+	for _, p := range generated {
+		keep[filepath.Clean(p)] = true
+	}
+	for _, entry := range ib.newCache.Files {
+		keep[filepath.Clean(entry.Output)] = true
+	}
+	for _, p := range ib.cache.Generated {
+		if !keep[filepath.Clean(p)] {
+			outPath := filepath.Join(ib.outputDir, p)
+			fmt.Printf("[IncRemove] %s (no longer generated)\n", outPath)
+			os.Remove(outPath)
+		}
+	}
+}
+
 // GetNewCache returns the updated cache
 func (ib *IncrementalBuilder) GetNewCache() *cacheFile {
 	return ib.newCache
@@ -203,17 +464,15 @@ type FullBuilder struct {
 	inputDir      string
 	outputDir     string
 	sizeThreshold int
-	templateOpt   string
 }
 
 // NewFullBuilder creates a new full builder
-func NewFullBuilder(inputDir, outputDir string, sizeThreshold int, templateOpt string) *FullBuilder {
+func NewFullBuilder(inputDir, outputDir string, sizeThreshold int, processor *MarkdownProcessor) *FullBuilder {
 	return &FullBuilder{
-		processor:     NewMarkdownProcessor(templateOpt),
+		processor:     processor,
 		inputDir:      inputDir,
 		outputDir:     outputDir,
 		sizeThreshold: sizeThreshold,
-		templateOpt:   templateOpt,
 	}
 }
 
@@ -232,11 +491,12 @@ func (fb *FullBuilder) ProcessAssetFiles(assetFiles []string) error {
 
 // ProcessMarkdownFiles processes all markdown files in full build mode
 func (fb *FullBuilder) ProcessMarkdownFilesWithHeaderFooter(
-	markdownFiles []string, sizeOut chan<- string, headerHTML, footerHTML []byte,
+	markdownFiles []string, sizeOut chan<- string, partials *Partials,
 ) error {
 	for _, relPath := range markdownFiles {
 		opStart := time.Now()
-		fmt.Printf("[Build]  %s -> %s\n", relPath, filepath.Join(fb.outputDir, relPath[:len(relPath)-len(filepath.Ext(relPath))]+".html"))
+		fmt.Printf("[Build]  %s -> %s\n", relPath, filepath.Join(fb.outputDir, fb.processor.outputPath(relPath)))
+		headerHTML, footerHTML, _ := partials.ForPage(relPath)
 
 		if err := fb.processor.ProcessMarkdownFile(fb.inputDir, fb.outputDir, relPath, fb.sizeThreshold, sizeOut, headerHTML, footerHTML); err != nil {
 			return err
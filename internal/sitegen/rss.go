@@ -15,8 +15,12 @@ import (
 )
 
 type RSSGenerator struct {
-	baseURL   string
-	outputDir string
+	baseURL    string
+	outputDir  string
+	processor  *MarkdownProcessor // parses pages for their summary
+	languages  *Languages
+	language   *LanguageConfig // nil for single-language sites
+	prettyURLs bool
 }
 
 type RSS struct {
@@ -47,10 +51,24 @@ func NewRSSGenerator(baseURL, outputDir string) *RSSGenerator {
 	return &RSSGenerator{
 		baseURL:   baseURL,
 		outputDir: outputDir,
+		processor: NewMarkdownProcessor(""),
 	}
 }
 
-// Generate creates an RSS feed from the provided markdown files
+// SetLanguage limits the feed to one language of a multilingual site. The
+// feed is still written to the generator's output directory, which should be
+// the language's output prefix.
+func (rg *RSSGenerator) SetLanguage(languages *Languages, lang *LanguageConfig) {
+	rg.languages = languages
+	rg.language = lang
+}
+
+// SetPrettyURLs makes the feed link to pages as foo/, see prettyOutputPath
+func (rg *RSSGenerator) SetPrettyURLs(pretty bool) {
+	rg.prettyURLs = pretty
+}
+
+// Generate creates an RSS feed from the provided pages
 func (rg *RSSGenerator) Generate(markdownFiles []string, inputDir string, maxItems int) error {
 	if rg.baseURL == "" {
 		return nil // No RSS generation if base URL is not set
@@ -58,6 +76,17 @@ func (rg *RSSGenerator) Generate(markdownFiles []string, inputDir string, maxIte
 
 	fmt.Printf("[RSS] Generating RSS feed...\n")
 
+	var files []string
+	for _, f := range markdownFiles {
+		if isNotFoundPage(rg.languages, f) {
+			continue
+		}
+		if lang, _, _ := rg.languages.Split(f); rg.language == nil || lang == rg.language {
+			files = append(files, f)
+		}
+	}
+	markdownFiles = files
+
 	items, err := rg.collectItems(markdownFiles, inputDir)
 	if err != nil {
 		return fmt.Errorf("failed to collect RSS items: %w", err)
@@ -80,14 +109,22 @@ func (rg *RSSGenerator) Generate(markdownFiles []string, inputDir string, maxIte
 		items = items[:maxItems]
 	}
 
+	link, language := strings.TrimSuffix(rg.baseURL, "/"), "en-gb"
+	if rg.language != nil {
+		if rg.language.Prefix != "" {
+			link += "/" + rg.language.Prefix
+		}
+		language = rg.language.Locale
+	}
+
 	// Create RSS structure
 	rss := RSS{
 		Version: "2.0",
 		Channel: Channel{
 			Title:         rg.inferSiteTitle(inputDir),
-			Link:          strings.TrimSuffix(rg.baseURL, "/"),
+			Link:          link,
 			Description:   rg.inferSiteDescription(inputDir),
-			Language:      "en-gb",
+			Language:      language,
 			LastBuildDate: time.Now().Format(time.RFC1123Z),
 			Items:         items,
 		},
@@ -117,10 +154,13 @@ func (rg *RSSGenerator) collectItems(markdownFiles []string, inputDir string) ([
 
 		title := rg.extractTitle(string(content), relPath)
 		description := rg.extractDescription(string(content), title)
-		htmlPath := strings.TrimSuffix(relPath, filepath.Ext(relPath)) + ".html"
+		htmlPath := strings.ReplaceAll(pageOutputPath(rg.languages, relPath, rg.prettyURLs), "\\", "/")
+		if rg.prettyURLs {
+			htmlPath = strings.TrimPrefix(prettyLink("/"+htmlPath), "/")
+		}
 
 		// Ensure proper URL formation
-		link := strings.TrimSuffix(rg.baseURL, "/") + "/" + strings.ReplaceAll(htmlPath, "\\", "/")
+		link := strings.TrimSuffix(rg.baseURL, "/") + "/" + htmlPath
 
 		items = append(items, Item{
 			Title:       title,
@@ -150,42 +190,22 @@ func (rg *RSSGenerator) extractTitle(content, fallback string) string {
 		}
 	}
 	// Fallback to filename without extension, make it more readable
-	filename := strings.TrimSuffix(filepath.Base(fallback), filepath.Ext(fallback))
-	// Convert kebab-case or snake_case to readable title
+	return titleFromFilename(fallback)
+}
+
+// titleFromFilename turns a kebab-case or snake_case file name into a readable title
+func titleFromFilename(path string) string {
+	filename := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
 	filename = strings.ReplaceAll(filename, "-", " ")
 	filename = strings.ReplaceAll(filename, "_", " ")
 	return cases.Title(language.Und).String(filename)
 }
 
-// extractDescription extracts a description from the content
+// extractDescription uses the page summary as the description, truncated
+// to 200 characters, falling back to the title
 func (rg *RSSGenerator) extractDescription(content, title string) string {
-	lines := strings.Split(content, "\n")
-	var description strings.Builder
-	foundTitle := false
-
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-
-		// Skip the title line
-		if strings.HasPrefix(line, "#") {
-			foundTitle = true
-			continue
-		}
-
-		// If we found the title, look for the first substantial paragraph
-		if foundTitle && line != "" && !strings.HasPrefix(line, "#") {
-			// Stop at next heading or after 200 characters
-			if description.Len() > 0 && description.Len() < 200 {
-				description.WriteString(" ")
-			}
-			description.WriteString(line)
-			if description.Len() >= 200 {
-				break
-			}
-		}
-	}
-
-	result := description.String()
+	root, src, meta := rg.processor.parseMarkdown([]byte(content), "")
+	result := summarize(nil, root, src, meta).Text
 	if len(result) > 200 {
 		// Truncate at word boundary
 		words := strings.Fields(result)
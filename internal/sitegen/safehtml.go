@@ -0,0 +1,249 @@
+// safehtml.go - Removing or sanitizing raw HTML and script URLs in untrusted markdown
+package sitegen
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+	nethtml "golang.org/x/net/html"
+)
+
+// safeHTMLKey holds the sanitizer of the markdown being parsed, nil if it
+// is trusted
+var safeHTMLKey = parser.NewContextKey()
+
+// removedHTMLAttr holds the description of what was removed from a node, see
+// warnRemovedHTML
+var removedHTMLAttr = []byte("colade-removed-html")
+
+// shortcodePlaceholderPattern matches the comments shortcode output is
+// restored into, which are kept in safe mode
+var shortcodePlaceholderPattern = regexp.MustCompile(`<!--colade-shortcode-\d+-->`)
+
+// Inline <script> and <style> tags, whose content is removed with them
+var (
+	scriptOpenPattern  = regexp.MustCompile(`(?i)^<(script|style)[\s>]`)
+	scriptClosePattern = regexp.MustCompile(`(?i)^</(script|style)\s*>$`)
+)
+
+// SafeHTMLConfig configures the safe HTML mode for sites with untrusted
+// content. Pages with trusted: true in their frontmatter are left alone.
+type SafeHTMLConfig struct {
+	Enabled bool                `yaml:"enabled"` // same as --safe-html
+	Allow   map[string][]string `yaml:"allow"`   // tags kept with their attributes, "*" for attributes of every tag; none to remove all raw HTML
+}
+
+// htmlSanitizer cleans the raw HTML of markdown, removing all of it unless
+// there is a policy
+type htmlSanitizer struct {
+	policy *bluemonday.Policy
+}
+
+// newHTMLSanitizer returns the sanitizer for a config, or nil if safe mode is off
+func newHTMLSanitizer(cfg SafeHTMLConfig) *htmlSanitizer {
+	if !cfg.Enabled {
+		return nil
+	}
+	if len(cfg.Allow) == 0 {
+		return &htmlSanitizer{}
+	}
+	policy := bluemonday.NewPolicy()
+	policy.AllowStandardURLs()
+	for tag, attrs := range cfg.Allow {
+		if tag == "*" {
+			if len(attrs) > 0 {
+				policy.AllowAttrs(attrs...).Globally()
+			}
+			continue
+		}
+		policy.AllowElements(tag)
+		if len(attrs) > 0 {
+			policy.AllowAttrs(attrs...).OnElements(tag)
+		}
+	}
+	return &htmlSanitizer{policy: policy}
+}
+
+// clean returns raw HTML without what the policy does not allow, keeping
+// shortcode placeholders and blank lines
+func (s *htmlSanitizer) clean(raw []byte) []byte {
+	var out []byte
+	last := 0
+	for _, m := range shortcodePlaceholderPattern.FindAllIndex(raw, -1) {
+		out = append(out, s.sanitize(raw[last:m[0]])...)
+		out = append(out, raw[m[0]:m[1]]...)
+		last = m[1]
+	}
+	return append(out, s.sanitize(raw[last:])...)
+}
+
+func (s *htmlSanitizer) sanitize(raw []byte) []byte {
+	if len(bytes.TrimSpace(raw)) == 0 {
+		return raw
+	}
+	if s.policy == nil {
+		if bytes.HasSuffix(raw, []byte("\n")) {
+			return []byte("\n")
+		}
+		return nil
+	}
+	return s.policy.SanitizeBytes(raw)
+}
+
+// describeRemoved lists the tags, attributes and comments of raw HTML that
+// are not in its cleaned version, in order of appearance, or "" if only end
+// tags or text were removed
+func describeRemoved(raw, cleaned []byte) string {
+	kept := make(map[string]int)
+	for _, item := range htmlItems(cleaned) {
+		kept[item]++
+	}
+	var removed []string
+	seen := make(map[string]bool)
+	for _, item := range htmlItems(raw) {
+		if kept[item] > 0 {
+			kept[item]--
+			continue
+		}
+		if !seen[item] {
+			seen[item] = true
+			removed = append(removed, item)
+		}
+	}
+	return strings.Join(removed, ", ")
+}
+
+// htmlItems lists the start tags, attributes and comments of an HTML fragment
+func htmlItems(fragment []byte) []string {
+	var items []string
+	z := nethtml.NewTokenizer(bytes.NewReader(fragment))
+	for {
+		switch z.Next() {
+		case nethtml.ErrorToken:
+			return items
+		case nethtml.StartTagToken, nethtml.SelfClosingTagToken:
+			tok := z.Token()
+			items = append(items, "<"+tok.Data+">")
+			for _, a := range tok.Attr {
+				items = append(items, fmt.Sprintf("%s on <%s>", a.Key, tok.Data))
+			}
+		case nethtml.CommentToken:
+			items = append(items, "comment")
+		}
+	}
+}
+
+// safeHTML is a goldmark extension applying the sanitizer of the markdown
+// being parsed. Links rewritten by mdLinks are sanitized after rewriting.
+type safeHTML struct{}
+
+func (e *safeHTML) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(parser.WithASTTransformers(util.Prioritized(&safeHTMLTransformer{}, 101)))
+}
+
+type safeHTMLTransformer struct{}
+
+func (t *safeHTMLTransformer) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	sanitizer, _ := pc.Get(safeHTMLKey).(*htmlSanitizer)
+	if sanitizer == nil {
+		return
+	}
+	source := reader.Source()
+	var autoLinks []*ast.AutoLink
+	var scripts []ast.Node
+	ast.Walk(doc, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch n := node.(type) {
+		case *ast.RawHTML, *ast.HTMLBlock:
+			raw := rawHTMLSource(n, source)
+			if rewritten, ok := n.Attribute(rewrittenHTMLAttr); ok {
+				raw = rewritten.([]byte)
+			}
+			cleaned := sanitizer.clean(raw)
+			if bytes.Equal(cleaned, raw) {
+				break
+			}
+			n.SetAttribute(rewrittenHTMLAttr, cleaned)
+			if removed := describeRemoved(raw, cleaned); removed != "" {
+				n.SetAttribute(removedHTMLAttr, removed)
+			}
+			if _, inline := n.(*ast.RawHTML); inline && scriptOpenPattern.Match(raw) {
+				scripts = append(scripts, n)
+			}
+		case *ast.Link:
+			if html.IsDangerousURL(n.Destination) {
+				n.SetAttribute(removedHTMLAttr, fmt.Sprintf("link to %q", n.Destination))
+				n.Destination = nil
+			}
+		case *ast.Image:
+			if html.IsDangerousURL(n.Destination) {
+				n.SetAttribute(removedHTMLAttr, fmt.Sprintf("image %q", n.Destination))
+				n.Destination = nil
+			}
+		case *ast.AutoLink:
+			if html.IsDangerousURL(n.URL(source)) {
+				autoLinks = append(autoLinks, n)
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+	for _, n := range scripts {
+		removeScriptContent(n, source)
+	}
+	// Dangerous autolinks are kept as text
+	for _, n := range autoLinks {
+		s := ast.NewString(n.Label(source))
+		s.SetAttribute(removedHTMLAttr, fmt.Sprintf("link to %q", n.URL(source)))
+		n.Parent().ReplaceChild(n.Parent(), n, s)
+	}
+}
+
+// removeScriptContent removes the text between a removed inline <script> or
+// <style> tag and its end tag
+func removeScriptContent(open ast.Node, source []byte) {
+	var content []ast.Node
+	for sib := open.NextSibling(); sib != nil; sib = sib.NextSibling() {
+		if raw, ok := sib.(*ast.RawHTML); ok && scriptClosePattern.Match(rawHTMLSource(raw, source)) {
+			for _, n := range content {
+				n.Parent().RemoveChild(n.Parent(), n)
+			}
+			return
+		}
+		content = append(content, sib)
+	}
+}
+
+// pageSanitizer returns the sanitizer for the page at relPath, nil if safe
+// mode is off or the page is trusted
+func (mp *MarkdownProcessor) pageSanitizer(relPath string) *htmlSanitizer {
+	if mp.sanitizer == nil {
+		return nil
+	}
+	if page := mp.site.Page(relPath); page != nil {
+		if trusted, _ := page.Meta["trusted"].(bool); trusted {
+			return nil
+		}
+	}
+	return mp.sanitizer
+}
+
+// warnRemovedHTML reports what safe mode removed from a rendered page
+func warnRemovedHTML(root ast.Node, relPath string) {
+	ast.Walk(root, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if removed, ok := n.Attribute(removedHTMLAttr); ok && entering {
+			fmt.Printf("[SafeHTML] %s: removed %s\n", relPath, removed)
+		}
+		return ast.WalkContinue, nil
+	})
+}
@@ -0,0 +1,91 @@
+package sitegen
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSafeHTML(t *testing.T) {
+	src := "Hi <b onclick=\"x()\">bold</b> <script>alert(1)</script>\n\n" +
+		"<div class=\"note\" onmouseover=\"y()\">\n<img src=\"cat.png\" onerror=\"alert(1)\">\n</div>\n\n" +
+		"[a](javascript:alert(1)) <javascript:alert(2)> [ok](https://example.com)\n"
+	for name, tc := range map[string]struct {
+		allow map[string][]string
+		want  string
+	}{
+		"strip": {
+			nil,
+			"<p>Hi bold </p>\n\n<p><a href=\"\">a</a> javascript:alert(2) <a href=\"https://example.com\">ok</a></p>\n",
+		},
+		"allowlist": {
+			map[string][]string{"b": nil, "div": {"class"}, "img": {"src", "alt"}},
+			"<p>Hi <b>bold</b> </p>\n<div class=\"note\">\n<img src=\"cat.png\">\n</div>\n<p><a href=\"\">a</a> javascript:alert(2) <a href=\"https://example.com\">ok</a></p>\n",
+		},
+	} {
+		mp := NewMarkdownProcessorWithConfig("", &Config{SafeHTML: SafeHTMLConfig{Enabled: true, Allow: tc.allow}})
+		out, _, err := mp.renderMarkdown([]byte(src))
+		if err != nil {
+			t.Fatalf("%s: renderMarkdown failed: %v", name, err)
+		}
+		if string(out) != tc.want {
+			t.Errorf("%s: renderMarkdown() = %q, want %q", name, out, tc.want)
+		}
+	}
+}
+
+func TestDescribeRemoved(t *testing.T) {
+	got := describeRemoved([]byte(`<div class="a" onclick="x"><!-- c --><img src="a" onerror="b"><img src="c"></div>`), []byte(`<div class="a"><img src="a"><img src="c"></div>`))
+	if want := "onclick on <div>, comment, onerror on <img>"; got != want {
+		t.Errorf("describeRemoved() = %q, want %q", got, want)
+	}
+	if got := describeRemoved([]byte("</b>"), nil); got != "" {
+		t.Errorf("describeRemoved() = %q, want nothing for end tags", got)
+	}
+}
+
+func TestBuild_SafeHTML(t *testing.T) {
+	inputDir := t.TempDir()
+	outputDir := t.TempDir()
+	writeTestFile(t, filepath.Join(inputDir, "index.md"), "# Home\n\n<iframe src=\"https://evil.example\"></iframe>\n\n{{< details summary=\"More\" >}}Inner <em onclick=\"x\">text</em>{{< /details >}}\n\n[Other](other.md)\n")
+	writeTestFile(t, filepath.Join(inputDir, "other.md"), "---\ntrusted: true\n---\n# Other\n\n<div id=\"widget\"></div>\n<script src=\"widget.js\"></script>\n\n{{< details summary=\"Raw\" >}}<em onclick=\"x\">kept</em>{{< /details >}}\n")
+
+	if err := Build(BuildOptions{InputDir: inputDir, OutputDir: outputDir, SafeHTML: true}); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	index := readTestFile(t, filepath.Join(outputDir, "index.html"))
+	for _, unwanted := range []string{"<iframe", "onclick"} {
+		if strings.Contains(index, unwanted) {
+			t.Errorf("expected %q to be removed, got:\n%s", unwanted, index)
+		}
+	}
+	for _, want := range []string{"<summary>More</summary>", "Inner text", `<a href="other.html">Other</a>`} {
+		if !strings.Contains(index, want) {
+			t.Errorf("expected index.html to contain %q, got:\n%s", want, index)
+		}
+	}
+	other := readTestFile(t, filepath.Join(outputDir, "other.html"))
+	for _, want := range []string{`<script src="widget.js"></script>`, `<em onclick="x">kept</em>`} {
+		if !strings.Contains(other, want) {
+			t.Errorf("expected the trusted page to keep %q, got:\n%s", want, other)
+		}
+	}
+}
+
+func TestBuild_SafeHTMLFilesOutsideInput(t *testing.T) {
+	root := t.TempDir()
+	inputDir := filepath.Join(root, "site")
+	writeTestFile(t, filepath.Join(root, "secret.txt"), "SECRET-OUTSIDE")
+	for _, page := range []string{`{{< code file="../secret.txt" >}}`, `{{< include "/../secret.txt" >}}`} {
+		writeTestFile(t, filepath.Join(inputDir, "index.md"), page+"\n")
+		outputDir := t.TempDir()
+		err := Build(BuildOptions{InputDir: inputDir, OutputDir: outputDir, SafeHTML: true, NoIncremental: true})
+		if err == nil || !strings.Contains(err.Error(), "outside the input directory") {
+			t.Errorf("%s: expected an outside the input directory error, got %v", page, err)
+		}
+		if data, _ := os.ReadFile(filepath.Join(outputDir, "index.html")); strings.Contains(string(data), "SECRET") {
+			t.Errorf("%s: file outside the input directory was published", page)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+// seo.go - Description, canonical, Open Graph, Twitter card and Article JSON-LD tags
+package sitegen
+
+import (
+	"bytes"
+	"encoding/json"
+	"html/template"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// SEOConfig sets site-wide defaults for the social preview tags
+type SEOConfig struct {
+	Image   string `yaml:"image" json:"image"`     // og:image for pages without an image in their frontmatter
+	Twitter string `yaml:"twitter" json:"twitter"` // twitter:site handle, e.g. "@colade"
+	Author  string `yaml:"author" json:"author"`   // Article author for pages without one
+}
+
+// maxDescriptionLength is the length search engines and chat previews show
+const maxDescriptionLength = 160
+
+// seoPage is what the SEO tags are rendered from
+type seoPage struct {
+	Title       string
+	Description string
+	Type        string // "article" for dated pages, else "website"
+	URL         string // absolute, empty without a base URL
+	Image       string // absolute, or empty
+	SiteName    string
+	Locale      string // og:locale, e.g. "en_GB"
+	Published   string // RFC 3339, dated pages only
+	Tags        []string
+	Author      string
+	Twitter     string
+	Card        string
+}
+
+var seoTemplate = template.Must(template.New("seo").Parse(
+	`{{ with .Description }}<meta name="description" content="{{ . }}">
+{{ end }}{{ with .URL }}<link rel="canonical" href="{{ . }}">
+{{ end }}<meta property="og:type" content="{{ .Type }}">
+<meta property="og:title" content="{{ .Title }}">
+{{ with .Description }}<meta property="og:description" content="{{ . }}">
+{{ end }}{{ with .URL }}<meta property="og:url" content="{{ . }}">
+{{ end }}{{ with .Image }}<meta property="og:image" content="{{ . }}">
+{{ end }}{{ with .SiteName }}<meta property="og:site_name" content="{{ . }}">
+{{ end }}{{ with .Locale }}<meta property="og:locale" content="{{ . }}">
+{{ end }}{{ with .Published }}<meta property="article:published_time" content="{{ . }}">
+{{ end }}{{ range .Tags }}<meta property="article:tag" content="{{ . }}">
+{{ end }}<meta name="twitter:card" content="{{ .Card }}">
+{{ with .Twitter }}<meta name="twitter:site" content="{{ . }}">
+{{ end }}`))
+
+// seoTags renders the SEO tags of a page. Frontmatter description, image,
+// author and canonical take precedence over the summary and site defaults.
+// URLs are only absolute, and canonical and og:url only present, when the
+// site has a base URL.
+func (mp *MarkdownProcessor) seoTags(relPath string, data *PageData, summary string) template.HTML {
+	cfg := mp.config
+	meta := data.Meta
+	p := seoPage{Title: data.Title, Type: "website", SiteName: cfg.Title, Author: cfg.SEO.Author, Twitter: cfg.SEO.Twitter, Card: "summary"}
+	if data.Page != nil && p.Title == "" {
+		p.Title = data.Page.Title
+	}
+	p.Description = truncateText(summary, maxDescriptionLength)
+	if v, ok := meta["description"].(string); ok && v != "" {
+		p.Description = v
+	}
+
+	p.URL = absURL(cfg.BaseURL, mp.urlPath(relPath))
+	if v, ok := meta["canonical"].(string); ok && v != "" {
+		p.URL = absURL(cfg.BaseURL, v)
+	}
+	if !isAbsURL(p.URL) {
+		p.URL = ""
+	}
+
+	image := cfg.SEO.Image
+	if v, ok := meta["image"].(string); ok && v != "" {
+		image = v
+		if !strings.HasPrefix(v, "/") && !isAbsURL(v) {
+			image = path.Join(path.Dir("/"+filepath.ToSlash(mp.baseOutputPath(relPath))), v)
+		}
+	}
+	if image != "" {
+		if image = absURL(cfg.BaseURL, image); isAbsURL(image) {
+			p.Image = image
+			p.Card = "summary_large_image"
+		}
+	}
+	if data.Language != nil {
+		p.Locale = strings.ReplaceAll(data.Language.Locale, "-", "_")
+	}
+	if v, ok := meta["author"].(string); ok && v != "" {
+		p.Author = v
+	}
+
+	var date time.Time
+	if data.Page != nil {
+		date = data.Page.Date
+		p.Tags = data.Page.Tags
+	}
+	if !date.IsZero() {
+		p.Type = "article"
+		p.Published = date.Format(time.RFC3339)
+	}
+
+	var buf bytes.Buffer
+	if err := seoTemplate.Execute(&buf, p); err != nil {
+		return ""
+	}
+	if p.Type == "article" {
+		buf.WriteString(articleJSONLD(p))
+	}
+	return template.HTML(buf.String())
+}
+
+// articleJSONLD renders a schema.org Article script for a dated page
+func articleJSONLD(p seoPage) string {
+	type person struct {
+		Type string `json:"@type"`
+		Name string `json:"name"`
+	}
+	article := struct {
+		Context     string   `json:"@context"`
+		Type        string   `json:"@type"`
+		Headline    string   `json:"headline"`
+		Description string   `json:"description,omitempty"`
+		Published   string   `json:"datePublished"`
+		URL         string   `json:"url,omitempty"`
+		MainEntity  string   `json:"mainEntityOfPage,omitempty"`
+		Image       string   `json:"image,omitempty"`
+		Author      *person  `json:"author,omitempty"`
+		Keywords    []string `json:"keywords,omitempty"`
+	}{Context: "https://schema.org", Type: "Article", Headline: p.Title, Description: p.Description,
+		Published: p.Published, URL: p.URL, MainEntity: p.URL, Image: p.Image, Keywords: p.Tags}
+	if p.Author != "" {
+		article.Author = &person{Type: "Person", Name: p.Author}
+	}
+	data, err := json.Marshal(article)
+	if err != nil {
+		return ""
+	}
+	return `<script type="application/ld+json">` + string(data) + "</script>\n"
+}
+
+// absURL resolves a root-relative URL against the site's base URL (or base
+// path), leaving it unchanged without a base or when it is already absolute
+func absURL(base, u string) string {
+	if base == "" || isAbsURL(u) {
+		return u
+	}
+	return strings.TrimSuffix(base, "/") + "/" + strings.TrimPrefix(u, "/")
+}
+
+func isAbsURL(u string) bool {
+	return strings.HasPrefix(u, "http://") || strings.HasPrefix(u, "https://") || strings.HasPrefix(u, "//")
+}
+
+// truncateText shortens text to at most n bytes at a word boundary, including
+// the ellipsis it adds
+func truncateText(s string, n int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if len(s) <= n {
+		return s
+	}
+	limit := max(n-len("…"), 0)
+	cut := strings.LastIndex(s[:limit], " ")
+	if cut <= 0 {
+		for cut = limit; cut > 0 && !utf8.RuneStart(s[cut]); cut-- {
+		}
+	}
+	return strings.TrimRight(s[:cut], ",.;:") + "…"
+}
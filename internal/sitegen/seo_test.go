@@ -0,0 +1,106 @@
+package sitegen
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTruncateText(t *testing.T) {
+	tests := []struct {
+		in   string
+		n    int
+		want string
+	}{
+		{"short text", 20, "short text"},
+		{"a  spaced\n text", 20, "a spaced text"},
+		{"one two three four", 12, "one two…"},
+		{"first, second", 8, "first…"},
+		{"ééééé", 5, "é…"},
+		{"one two three", 9, "one…"},
+	}
+	for _, tt := range tests {
+		got := truncateText(tt.in, tt.n)
+		if got != tt.want {
+			t.Errorf("truncateText(%q, %d) = %q, want %q", tt.in, tt.n, got, tt.want)
+		}
+		if len(got) > tt.n {
+			t.Errorf("truncateText(%q, %d) = %q is longer than %d bytes", tt.in, tt.n, got, tt.n)
+		}
+	}
+}
+
+func TestBuild_SEOTags(t *testing.T) {
+	inputDir := t.TempDir()
+	outputDir := t.TempDir()
+	writeTestFile(t, filepath.Join(inputDir, "colade.yaml"), "title: My Blog\nbase_url: https://example.com/\nseo:\n  twitter: \"@myblog\"\n  author: Sam\n  image: /img/default.png\n")
+	writeTestFile(t, filepath.Join(inputDir, "about.md"), "# About\n\nWho we are & what we do.")
+	writeTestFile(t, filepath.Join(inputDir, "blog", "post.md"), "---\ntitle: \"Hello <World>\"\ndate: 2024-03-01\ntags: [go]\nimage: cover.png\ndescription: A custom description\n---\nBody text.")
+
+	if err := Build(BuildOptions{InputDir: inputDir, OutputDir: outputDir}); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	about := readTestFile(t, filepath.Join(outputDir, "about.html"))
+	for _, want := range []string{
+		`<meta name="description" content="Who we are &amp; what we do.">`,
+		`<link rel="canonical" href="https://example.com/about.html">`,
+		`<meta property="og:type" content="website">`,
+		`<meta property="og:title" content="About">`,
+		`<meta property="og:url" content="https://example.com/about.html">`,
+		`<meta property="og:image" content="https://example.com/img/default.png">`,
+		`<meta property="og:site_name" content="My Blog">`,
+		`<meta name="twitter:card" content="summary_large_image">`,
+		`<meta name="twitter:site" content="@myblog">`,
+	} {
+		if !strings.Contains(about, want) {
+			t.Errorf("expected about.html to contain %q, got:\n%s", want, about)
+		}
+	}
+	if strings.Contains(about, "application/ld+json") {
+		t.Errorf("expected no Article JSON-LD on an undated page")
+	}
+
+	post := readTestFile(t, filepath.Join(outputDir, "blog", "post.html"))
+	for _, want := range []string{
+		`<meta name="description" content="A custom description">`,
+		`<meta property="og:type" content="article">`,
+		`<meta property="og:title" content="Hello &lt;World&gt;">`,
+		`<meta property="og:image" content="https://example.com/blog/cover.png">`,
+		`<meta property="article:published_time" content="2024-03-01T00:00:00Z">`,
+		`<meta property="article:tag" content="go">`,
+		`"@type":"Article","headline":"Hello \u003cWorld\u003e"`,
+		`"author":{"@type":"Person","name":"Sam"}`,
+		`"mainEntityOfPage":"https://example.com/blog/post.html"`,
+	} {
+		if !strings.Contains(post, want) {
+			t.Errorf("expected post.html to contain %q, got:\n%s", want, post)
+		}
+	}
+}
+
+func TestBuild_SEOTagsWithoutBaseURL(t *testing.T) {
+	inputDir := t.TempDir()
+	outputDir := t.TempDir()
+	writeTestFile(t, filepath.Join(inputDir, "page.md"), "---\nimage: cover.png\n---\n# Page\n\nText.")
+
+	if err := Build(BuildOptions{InputDir: inputDir, OutputDir: outputDir}); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	out := readTestFile(t, filepath.Join(outputDir, "page.html"))
+	if !strings.Contains(out, `<meta property="og:title" content="Page">`) || !strings.Contains(out, `<meta name="twitter:card" content="summary">`) {
+		t.Errorf("expected title and card tags, got:\n%s", out)
+	}
+	for _, unwanted := range []string{"canonical", "og:url", "og:image"} {
+		if strings.Contains(out, unwanted) {
+			t.Errorf("expected no %s without a base URL, got:\n%s", unwanted, out)
+		}
+	}
+
+	// --rss doubles as the base URL
+	if err := Build(BuildOptions{InputDir: inputDir, OutputDir: outputDir, RSSURL: "https://feeds.example.org"}); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	if out := readTestFile(t, filepath.Join(outputDir, "page.html")); !strings.Contains(out, `<link rel="canonical" href="https://feeds.example.org/page.html">`) {
+		t.Errorf("expected the RSS URL to be used as the base URL, got:\n%s", out)
+	}
+}
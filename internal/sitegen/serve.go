@@ -41,8 +41,9 @@ func (rw *responseWrapper) WriteHeader(code int) {
 
 // customFileServer handles custom 404 and index.html serving
 type customFileServer struct {
-	root http.Dir
-	dir  string
+	root     http.Dir
+	dir      string
+	basePath string // URL prefix the site is served below, "" for the root
 }
 
 func (cfs *customFileServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -52,6 +53,20 @@ func (cfs *customFileServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		path = "/" + path
 	}
 
+	// Serve the site below its base path, as it is deployed
+	if cfs.basePath != "" {
+		if path == "/" || path == cfs.basePath {
+			http.Redirect(w, r, cfs.basePath+"/", http.StatusFound)
+			return
+		}
+		rest, ok := strings.CutPrefix(path, cfs.basePath+"/")
+		if !ok {
+			cfs.notFound(w, "/")
+			return
+		}
+		path = "/" + rest
+	}
+
 	// Handle root path - serve index.html if it exists
 	if path == "/" {
 		indexPath := filepath.Join(string(cfs.root), "index.html")
@@ -64,18 +79,30 @@ func (cfs *customFileServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// Try to serve the requested file
 	fullPath := filepath.Join(string(cfs.root), path)
 	if _, err := os.Stat(fullPath); os.IsNotExist(err) {
-		// File doesn't exist, try to serve custom 404.html
-		custom404Path := filepath.Join(string(cfs.root), "404.html")
-		if _, err := os.Stat(custom404Path); err == nil {
+		cfs.notFound(w, path)
+		return
+	}
+
+	// Serve the file normally
+	http.ServeFile(w, r, fullPath)
+}
+
+// notFound writes the 404.html nearest to path, or a built-in 404 page
+func (cfs *customFileServer) notFound(w http.ResponseWriter, path string) {
+	// Try to serve the nearest custom 404.html
+	if custom404Path := nearestNotFound(string(cfs.root), path); custom404Path != "" {
+		if page, err := os.ReadFile(custom404Path); err == nil {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
 			w.WriteHeader(http.StatusNotFound)
-			http.ServeFile(w, r, custom404Path)
+			w.Write(page)
 			return
 		}
+	}
 
-		// Serve hardcoded 404 page
-		w.WriteHeader(http.StatusNotFound)
-		w.Header().Set("Content-Type", "text/html; charset=utf-8")
-		fmt.Fprintf(w, `<!DOCTYPE html>
+	// Serve hardcoded 404 page
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusNotFound)
+	fmt.Fprintf(w, `<!DOCTYPE html>
 <html>
 <head>
     <title>404 - Page Not Found</title>
@@ -88,14 +115,9 @@ func (cfs *customFileServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 <body>
     <h1>404 - Page Not Found</h1>
     <p>The requested page could not be found.</p>
-    <p><a href="/">Return to home</a></p>
+    <p><a href="%s/">Return to home</a></p>
 </body>
-</html>`)
-		return
-	}
-
-	// Serve the file normally
-	http.ServeFile(w, r, fullPath)
+</html>`, cfs.basePath)
 }
 
 // checkPortAvailable checks if a port is available
@@ -109,7 +131,15 @@ func checkPortAvailable(port int) bool {
 	return true
 }
 
+// ServeDir serves a directory at the root of a local web server
 func ServeDir(dir string, port int) error {
+	return ServeDirAt(dir, port, "")
+}
+
+// ServeDirAt serves a directory below a base path such as "/blog", for sites
+// built with a base path
+func ServeDirAt(dir string, port int, basePath string) error {
+	basePath = normalizeBasePath(basePath)
 	// Check if port is available
 	if !checkPortAvailable(port) {
 		fmt.Printf("Port %d is already in use. Try a different port.\n", port)
@@ -126,14 +156,15 @@ func ServeDir(dir string, port int) error {
 
 	// Create custom file server
 	customHandler := &customFileServer{
-		root: http.Dir(dir),
-		dir:  dir,
+		root:     http.Dir(dir),
+		dir:      dir,
+		basePath: basePath,
 	}
 
 	// Wrap with logging
 	loggingWrapper := &loggingHandler{handler: customHandler}
 
-	fmt.Printf("Serving '%s' at http://localhost:%d\n", dir, port)
+	fmt.Printf("Serving '%s' at http://localhost:%d%s/\n", dir, port, basePath)
 	fmt.Println("Press Ctrl+C to stop.")
 
 	addr := fmt.Sprintf(":%d", port)
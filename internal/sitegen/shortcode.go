@@ -0,0 +1,436 @@
+// shortcode.go - Shortcodes expanded in markdown before it is parsed
+package sitegen
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Shortcode delimiters. {{</* name */>}} is written out literally as {{< name >}}.
+const (
+	shortcodeOpen        = "{{<"
+	shortcodeClose       = ">}}"
+	shortcodeEscapeOpen  = "{{</*"
+	shortcodeEscapeClose = "*/>}}"
+)
+
+// Shortcodes holds the shortcode templates of a site, keyed by name
+type Shortcodes struct {
+	templates map[string]*template.Template
+	sources   map[string][]byte
+}
+
+// LoadShortcodes parses the shortcode templates from the site's shortcodes/
+// directory, the theme's and the bundled ones, in that order of preference
+func LoadShortcodes(theme *Theme) (*Shortcodes, error) {
+	names := make(map[string]bool)
+	for _, dir := range []string{theme.siteDir, theme.Dir} {
+		if dir == "" {
+			continue
+		}
+		entries, _ := os.ReadDir(filepath.Join(dir, "shortcodes"))
+		for _, e := range entries {
+			if !e.IsDir() && filepath.Ext(e.Name()) == ".html" {
+				names[strings.TrimSuffix(e.Name(), ".html")] = true
+			}
+		}
+	}
+	entries, _ := fs.ReadDir(EmbeddedFiles, "templates/shortcodes")
+	for _, e := range entries {
+		names[strings.TrimSuffix(e.Name(), ".html")] = true
+	}
+
+	sc := &Shortcodes{templates: make(map[string]*template.Template), sources: make(map[string][]byte)}
+	for name := range names {
+		src, err := theme.ReadFile("shortcodes/" + name + ".html")
+		if err != nil {
+			return nil, fmt.Errorf("error reading shortcode %q: %w", name, err)
+		}
+		tmpl, err := template.New(name).Parse(string(src))
+		if err != nil {
+			return nil, fmt.Errorf("error parsing shortcode %q: %w", name, err)
+		}
+		sc.templates[name] = tmpl
+		sc.sources[name] = src
+	}
+	return sc, nil
+}
+
+// bundledShortcodes are the bundled shortcodes, used when no site is loaded
+var bundledShortcodes = loadBundledShortcodes()
+
+func loadBundledShortcodes() *Shortcodes {
+	sc := &Shortcodes{templates: make(map[string]*template.Template), sources: make(map[string][]byte)}
+	entries, _ := fs.ReadDir(EmbeddedFiles, "templates/shortcodes")
+	for _, e := range entries {
+		name := strings.TrimSuffix(e.Name(), ".html")
+		src, _ := fs.ReadFile(EmbeddedFiles, "templates/shortcodes/"+e.Name())
+		sc.templates[name] = template.Must(template.New(name).Parse(string(src)))
+		sc.sources[name] = src
+	}
+	return sc
+}
+
+// writeSources writes every template source in name order, for build fingerprints
+func (sc *Shortcodes) writeSources(w io.Writer) {
+	names := make([]string, 0, len(sc.sources))
+	for name := range sc.sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "%s\x00%s\x00", name, sc.sources[name])
+	}
+}
+
+// ShortcodeData is the data a shortcode template is executed with
+type ShortcodeData struct {
+	Name   string
+	Params map[string]string // named params, positional params keyed "0", "1", ...
+	Inner  template.HTML     // rendered markdown between paired tags
+	Page   *Page             // the page using the shortcode, nil while the site is loading
+	Site   *SiteView
+
+	exp *shortcodeExpansion
+}
+
+// Get returns a named or positional param, or "" if it is not set
+func (d ShortcodeData) Get(key interface{}) string {
+	return d.Params[fmt.Sprint(key)]
+}
+
+// ReadFile returns a file relative to the page's directory, or to the input
+// directory if name starts with "/". The page is rebuilt when the file changes.
+func (d ShortcodeData) ReadFile(name string) (string, error) {
+	if name == "" {
+		return "", fmt.Errorf("no file given")
+	}
+	var p string
+	if strings.HasPrefix(name, "/") {
+		p = filepath.Join(d.exp.inputDir, filepath.FromSlash(name))
+	} else {
+		p = filepath.Join(d.exp.inputDir, filepath.Dir(d.exp.relPath), filepath.FromSlash(name))
+	}
+	if !inDir(d.exp.inputDir, p) {
+		return "", fmt.Errorf("file %q is outside the input directory", name)
+	}
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return "", err
+	}
+	d.exp.deps = append(d.exp.deps, depKey(d.exp.inputDir, p))
+	return string(data), nil
+}
+
+// inDir reports whether path p is dir or inside it, so that files named in
+// content cannot be read from elsewhere
+func inDir(dir, p string) bool {
+	rel, err := filepath.Rel(dir, filepath.Clean(p))
+	if err != nil || filepath.IsAbs(rel) {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// shortcodeExpansion collects the output of the shortcodes in one page. The
+// markdown gets an HTML comment placeholder for each shortcode, which is
+// replaced with its output after rendering so that goldmark never sees it.
+type shortcodeExpansion struct {
+	mp        *MarkdownProcessor
+	inputDir  string
+	relPath   string
+	site      *SiteView
+	outputs   []string
+	deps      []string // files read by shortcodes, as cache keys
+	including []string // files being included, innermost last, see includeFile
+}
+
+// expandShortcodes replaces the shortcodes in a page with placeholders
+func (mp *MarkdownProcessor) expandShortcodes(content []byte, inputDir, relPath string, site *SiteView) ([]byte, *shortcodeExpansion, error) {
+	exp := &shortcodeExpansion{mp: mp, inputDir: inputDir, relPath: relPath, site: site}
+	if !bytes.Contains(content, []byte(shortcodeOpen)) && !bytes.Contains(content, []byte("![[")) {
+		return content, exp, nil
+	}
+	out, err := exp.expandAll(content)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", relPath, err)
+	}
+	return out, exp, nil
+}
+
+func (e *shortcodeExpansion) expand(src []byte) ([]byte, error) {
+	var out bytes.Buffer
+	code := codeRanges(src)
+	pos := 0
+	for {
+		i := bytes.Index(src[pos:], []byte(shortcodeOpen))
+		if i < 0 {
+			break
+		}
+		i += pos
+		out.Write(src[pos:i])
+
+		if bytes.HasPrefix(src[i:], []byte(shortcodeEscapeOpen)) {
+			j := bytes.Index(src[i:], []byte(shortcodeEscapeClose))
+			if j < 0 {
+				out.WriteString(shortcodeOpen)
+				pos = i + len(shortcodeOpen)
+				continue
+			}
+			out.WriteString(shortcodeOpen)
+			out.Write(src[i+len(shortcodeEscapeOpen) : i+j])
+			out.WriteString(shortcodeClose)
+			pos = i + j + len(shortcodeEscapeClose)
+			continue
+		}
+
+		tag, ok := parseShortcodeTag(src, i)
+		if !ok || inRanges(code, i) {
+			out.WriteString(shortcodeOpen)
+			pos = i + len(shortcodeOpen)
+			continue
+		}
+		if tag.closing {
+			return nil, fmt.Errorf("closing shortcode %q without an opening one", tag.name)
+		}
+		pos = tag.end
+		if tag.name == includeShortcode {
+			md, err := e.include(tag)
+			if err != nil {
+				return nil, err
+			}
+			out.Write(md)
+			continue
+		}
+		var inner []byte
+		if !tag.selfClosing {
+			if closeStart, closeEnd, found := findClosingShortcode(src, tag.end, tag.name); found {
+				inner = src[tag.end:closeStart]
+				pos = closeEnd
+			}
+		}
+		html, err := e.execute(tag, inner)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(&out, "<!--colade-shortcode-%d-->", len(e.outputs))
+		e.outputs = append(e.outputs, html)
+	}
+	out.Write(src[pos:])
+	return out.Bytes(), nil
+}
+
+// execute renders one shortcode, rendering its inner markdown first
+func (e *shortcodeExpansion) execute(tag shortcodeTag, inner []byte) (string, error) {
+	tmpl := e.mp.shortcodes.templates[tag.name]
+	if tmpl == nil {
+		return "", fmt.Errorf("unknown shortcode %q", tag.name)
+	}
+	data := ShortcodeData{Name: tag.name, Params: tag.params, Page: e.mp.site.Page(e.relPath), Site: e.site, exp: e}
+	if inner != nil {
+		expanded, err := e.expandAll(inner)
+		if err != nil {
+			return "", err
+		}
+		html, _, err := e.mp.renderPageMarkdown(expanded, e.relPath)
+		if err != nil {
+			return "", fmt.Errorf("failed to render shortcode %q: %w", tag.name, err)
+		}
+		data.Inner = template.HTML(e.restore(html))
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to execute shortcode %q: %w", tag.name, err)
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// restore replaces the placeholders in rendered HTML with the shortcode output
+func (e *shortcodeExpansion) restore(html []byte) []byte {
+	for i := len(e.outputs) - 1; i >= 0; i-- {
+		placeholder := fmt.Sprintf("<!--colade-shortcode-%d-->", i)
+		html = bytes.ReplaceAll(html, []byte(placeholder), []byte(e.outputs[i]))
+	}
+	return html
+}
+
+// codeRanges returns the offsets of the fenced code blocks and code spans of
+// markdown, where shortcodes are shown as written instead of expanded
+func codeRanges(src []byte) [][2]int {
+	var ranges [][2]int
+	fence, fenceStart := "", 0
+	offset := 0
+	for _, line := range bytes.SplitAfter(src, []byte("\n")) {
+		trimmed := strings.TrimLeft(string(line), " ")
+		switch {
+		case fence != "":
+			if strings.HasPrefix(trimmed, fence) {
+				ranges = append(ranges, [2]int{fenceStart, offset + len(line)})
+				fence = ""
+			}
+		case strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~"):
+			fence, fenceStart = trimmed[:3], offset
+		default:
+			for i := 0; i < len(line); {
+				if line[i] != '`' {
+					i++
+					continue
+				}
+				n := 0
+				for i+n < len(line) && line[i+n] == '`' {
+					n++
+				}
+				end := closingBackticks(line, i+n, n)
+				if end < 0 {
+					i += n
+					continue
+				}
+				ranges = append(ranges, [2]int{offset + i, offset + end})
+				i = end
+			}
+		}
+		offset += len(line)
+	}
+	if fence != "" {
+		ranges = append(ranges, [2]int{fenceStart, len(src)})
+	}
+	return ranges
+}
+
+// inRanges reports whether offset i is in one of the ranges
+func inRanges(ranges [][2]int, i int) bool {
+	for _, r := range ranges {
+		if i >= r[0] && i < r[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// shortcodeTag is a parsed {{< name params >}} or {{< /name >}} tag
+type shortcodeTag struct {
+	name        string
+	params      map[string]string
+	closing     bool
+	selfClosing bool // {{< name />}}
+	end         int  // offset just past the tag
+}
+
+// parseShortcodeTag parses the tag starting at offset start
+func parseShortcodeTag(src []byte, start int) (shortcodeTag, bool) {
+	bodyStart := start + len(shortcodeOpen)
+	j := bytes.Index(src[bodyStart:], []byte(shortcodeClose))
+	if j < 0 {
+		return shortcodeTag{}, false
+	}
+	tag := shortcodeTag{end: bodyStart + j + len(shortcodeClose)}
+	body := strings.TrimSpace(string(src[bodyStart : bodyStart+j]))
+	if strings.HasPrefix(body, "/") {
+		tag.closing = true
+		body = strings.TrimSpace(body[1:])
+	} else if strings.HasSuffix(body, "/") {
+		tag.selfClosing = true
+		body = strings.TrimSpace(body[:len(body)-1])
+	}
+	args := splitShortcodeArgs(body)
+	if len(args) == 0 || strings.Contains(args[0], "=") {
+		return shortcodeTag{}, false
+	}
+	tag.name = args[0]
+	tag.params = make(map[string]string)
+	positional := 0
+	for _, arg := range args[1:] {
+		if key, value, ok := strings.Cut(arg, "="); ok && !strings.ContainsRune(quoteChars, rune(arg[0])) {
+			tag.params[key] = unquote(value)
+		} else {
+			tag.params[fmt.Sprint(positional)] = unquote(arg)
+			positional++
+		}
+	}
+	return tag, true
+}
+
+// findClosingShortcode finds the {{< /name >}} tag matching an opening tag,
+// skipping nested pairs of the same shortcode
+func findClosingShortcode(src []byte, from int, name string) (int, int, bool) {
+	depth := 0
+	pos := from
+	for {
+		i := bytes.Index(src[pos:], []byte(shortcodeOpen))
+		if i < 0 {
+			return 0, 0, false
+		}
+		i += pos
+		if bytes.HasPrefix(src[i:], []byte(shortcodeEscapeOpen)) {
+			pos = i + len(shortcodeEscapeOpen)
+			continue
+		}
+		tag, ok := parseShortcodeTag(src, i)
+		if !ok {
+			pos = i + len(shortcodeOpen)
+			continue
+		}
+		pos = tag.end
+		if tag.name != name || tag.selfClosing {
+			continue
+		}
+		if !tag.closing {
+			depth++
+		} else if depth > 0 {
+			depth--
+		} else {
+			return i, tag.end, true
+		}
+	}
+}
+
+// splitShortcodeArgs splits a tag body on spaces outside quotes
+func splitShortcodeArgs(s string) []string {
+	var args []string
+	var cur strings.Builder
+	var quote rune
+	for _, r := range s {
+		switch {
+		case quote != 0:
+			cur.WriteRune(r)
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'' || r == '`':
+			quote = r
+			cur.WriteRune(r)
+		case r == ' ' || r == '\t' || r == '\n' || r == '\r':
+			if cur.Len() > 0 {
+				args = append(args, cur.String())
+				cur.Reset()
+			}
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	if cur.Len() > 0 {
+		args = append(args, cur.String())
+	}
+	return args
+}
+
+// quoteChars are the characters a shortcode param value may be quoted with
+const quoteChars = "\"'`"
+
+func isQuoted(s string) bool {
+	return len(s) >= 2 && strings.ContainsRune(quoteChars, rune(s[0])) && s[len(s)-1] == s[0]
+}
+
+func unquote(s string) string {
+	if isQuoted(s) {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
@@ -0,0 +1,148 @@
+package sitegen
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseShortcodeTag(t *testing.T) {
+	tests := []struct {
+		src  string
+		want shortcodeTag
+	}{
+		{
+			`{{< figure src="a b.png" caption='A "quoted" caption' width=300 >}}`,
+			shortcodeTag{name: "figure", params: map[string]string{"src": "a b.png", "caption": `A "quoted" caption`, "width": "300"}},
+		},
+		{
+			`{{< youtube "abc=def" 42 />}}`,
+			shortcodeTag{name: "youtube", params: map[string]string{"0": "abc=def", "1": "42"}, selfClosing: true},
+		},
+		{
+			`{{< /note >}}`,
+			shortcodeTag{name: "note", params: map[string]string{}, closing: true},
+		},
+	}
+	for _, test := range tests {
+		got, ok := parseShortcodeTag([]byte(test.src), 0)
+		test.want.end = len(test.src)
+		if !ok || !reflect.DeepEqual(got, test.want) {
+			t.Errorf("parseShortcodeTag(%q) = %+v, %t, want %+v", test.src, got, ok, test.want)
+		}
+	}
+	if _, ok := parseShortcodeTag([]byte("{{< no end"), 0); ok {
+		t.Errorf("expected an unterminated tag not to parse")
+	}
+}
+
+func TestBuild_Shortcodes(t *testing.T) {
+	inputDir := t.TempDir()
+	outputDir := t.TempDir()
+	writeTestFile(t, filepath.Join(inputDir, "shortcodes", "note.html"),
+		`<div class="note">{{ with .Get "title" }}<strong>{{ . }}</strong>{{ end }}{{ .Inner }}</div>`)
+	writeTestFile(t, filepath.Join(inputDir, "docs", "hello.go"), "package main\n\nfunc main() {}\n")
+	writeTestFile(t, filepath.Join(inputDir, "docs", "page.md"), `# Shortcodes
+
+Before {{< figure src="cat.png" caption="A cat" >}} after.
+
+{{< note title="Heads up" >}}
+Some *markdown*.
+
+{{< details summary="More" >}}
+Nested content.
+{{< /details >}}
+{{< /note >}}
+
+{{< code file="hello.go" lang="go" >}}
+
+Literal {{</* figure src="x.png" */>}} syntax.
+`)
+
+	if err := Build(BuildOptions{InputDir: inputDir, OutputDir: outputDir}); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	out := readTestFile(t, filepath.Join(outputDir, "docs", "page.html"))
+	for _, want := range []string{
+		`<figure>` + "\n" + `  <img src="cat.png" alt="A cat">` + "\n" + `  <figcaption>A cat</figcaption>` + "\n" + `</figure>`,
+		`<div class="note"><strong>Heads up</strong><p>Some <em>markdown</em>.</p>`,
+		"<details>\n<summary>More</summary>\n<p>Nested content.</p>\n\n</details>",
+		"<pre><code class=\"language-go\">package main\n\nfunc main() {}\n</code></pre>",
+		`Literal {{&lt; figure src=&quot;x.png&quot; &gt;}} syntax.`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, out)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "shortcodes")); !os.IsNotExist(err) {
+		t.Errorf("shortcode templates should not be published")
+	}
+
+	// Changing an included file rebuilds the page in an incremental build
+	snippet := filepath.Join(inputDir, "docs", "hello.go")
+	writeTestFile(t, snippet, "package changed\n")
+	later := time.Now().Add(2 * time.Second)
+	os.Chtimes(snippet, later, later)
+	if err := Build(BuildOptions{InputDir: inputDir, OutputDir: outputDir}); err != nil {
+		t.Fatalf("incremental Build failed: %v", err)
+	}
+	if out := readTestFile(t, filepath.Join(outputDir, "docs", "page.html")); !strings.Contains(out, "package changed") {
+		t.Errorf("expected the page to be rebuilt with the changed include, got:\n%s", out)
+	}
+}
+
+func TestBuild_UnknownShortcode(t *testing.T) {
+	inputDir := t.TempDir()
+	writeTestFile(t, filepath.Join(inputDir, "index.md"), "{{< missing >}}")
+
+	err := Build(BuildOptions{InputDir: inputDir, OutputDir: t.TempDir()})
+	if err == nil || !strings.Contains(err.Error(), `unknown shortcode "missing"`) {
+		t.Errorf("expected an unknown shortcode error, got %v", err)
+	}
+}
+
+func TestBuild_ShortcodeFileOutsideInput(t *testing.T) {
+	root := t.TempDir()
+	inputDir := filepath.Join(root, "site")
+	writeTestFile(t, filepath.Join(root, "secret.txt"), "SECRET-OUTSIDE")
+	for _, name := range []string{"../secret.txt", "/../secret.txt", "docs/../../secret.txt"} {
+		writeTestFile(t, filepath.Join(inputDir, "index.md"), `{{< code file="`+name+`" >}}`)
+		outputDir := t.TempDir()
+		err := Build(BuildOptions{InputDir: inputDir, OutputDir: outputDir, NoIncremental: true})
+		if err == nil || !strings.Contains(err.Error(), "outside the input directory") {
+			t.Errorf("%s: expected an outside the input directory error, got %v", name, err)
+		}
+		if data, _ := os.ReadFile(filepath.Join(outputDir, "index.html")); strings.Contains(string(data), "SECRET") {
+			t.Errorf("%s: file outside the input directory was published", name)
+		}
+	}
+}
+
+func TestBuild_ShortcodesInCode(t *testing.T) {
+	tests := []struct {
+		name, src, want string
+	}{
+		{"code span", "Use `{{< figure src=cat.png >}}` for images.\n", "<code>{{&lt; figure src=cat.png &gt;}}</code>"},
+		{"fence", "```\n{{< figure src=cat.png >}}\n```\n", "<pre><code>{{&lt; figure src=cat.png &gt;}}\n</code></pre>"},
+		{"escaped in code span", "`{{</* figure */>}}`\n", "<code>{{&lt; figure &gt;}}</code>"},
+	}
+	for _, test := range tests {
+		inputDir := t.TempDir()
+		outputDir := t.TempDir()
+		writeTestFile(t, filepath.Join(inputDir, "index.md"), test.src+"\n{{< figure src=dog.png >}}\n")
+
+		if err := Build(BuildOptions{InputDir: inputDir, OutputDir: outputDir, NoIncremental: true}); err != nil {
+			t.Fatalf("%s: Build failed: %v", test.name, err)
+		}
+		out := readTestFile(t, filepath.Join(outputDir, "index.html"))
+		if !strings.Contains(out, test.want) || strings.Contains(out, "colade-shortcode") {
+			t.Errorf("%s: expected the shortcode to be shown as written, got:\n%s", test.name, out)
+		}
+		if !strings.Contains(out, `src="dog.png"`) {
+			t.Errorf("%s: expected the shortcode outside code to be expanded, got:\n%s", test.name, out)
+		}
+	}
+}
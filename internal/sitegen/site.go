@@ -0,0 +1,393 @@
+// site.go - Site-wide page index for links between pages
+package sitegen
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/yuin/goldmark/ast"
+)
+
+// defaultRelatedLimit is the number of related pages shown when the config does not say
+const defaultRelatedLimit = 5
+
+// Page is the metadata of a page, gathered for every page before rendering
+type Page struct {
+	RelPath string    // source path relative to the input directory
+	URL     string    // root-relative URL of the rendered page, e.g. "/blog/post.html"
+	Title   string    // frontmatter title, first heading or file name
+	Date    time.Time // zero if the page has no date
+	Tags    []string
+	Section string // top-level directory, "" for pages at the root
+	Summary string // plain text summary, see summarize
+	Meta    map[string]interface{}
+
+	Language *LanguageConfig // nil unless the site has languages configured
+
+	key          string // source path without language marker, shared by translations
+	translations []*Page
+	breadcrumbs  []Breadcrumb
+	prev         *Page
+	next         *Page
+	related      []*Page
+	wikiLinks    []string // targets of the page's [[wiki links]]
+	backlinks    []*Page  // pages with wiki links to this page, see linkBacklinks
+}
+
+// DisplayDate formats the page date like .Date in templates
+func (p *Page) DisplayDate() string {
+	if p.Date.IsZero() {
+		return ""
+	}
+	return p.Date.Format("02 Jan 2006")
+}
+
+// Site is the index of every page in a build
+type Site struct {
+	Pages     []*Page
+	byPath    map[string]*Page
+	data      map[string]interface{} // see LoadData
+	dataFiles []string
+	menus     map[string][]MenuEntry
+	wikiNames map[string][]*Page // pages by normalized title and file name, see indexWikiNames
+	pagesKey  string             // see pagesHash
+}
+
+// LoadSite reads the data files and the frontmatter and title of every page
+// so that pages can link to each other when rendered
+func LoadSite(mp *MarkdownProcessor, cfg *Config, inputDir string, pages []string) (*Site, error) {
+	data, dataFiles, err := LoadData(inputDir)
+	if err != nil {
+		return nil, err
+	}
+	site := &Site{byPath: make(map[string]*Page, len(pages)), data: data, dataFiles: dataFiles}
+	for _, relPath := range pages {
+		content, err := os.ReadFile(filepath.Join(inputDir, relPath))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read markdown file '%s': %w", relPath, err)
+		}
+		content, _, err = mp.expandShortcodes(content, inputDir, relPath, &SiteView{site: site})
+		if err != nil {
+			return nil, err
+		}
+		root, src, meta := mp.parseMarkdown(content, relPath)
+		page := newPage(relPath, root, src, meta)
+		page.wikiLinks = wikiTargets(root)
+		setPageLanguage(page, mp.languages)
+		page.URL = mp.urlPath(relPath)
+		site.Pages = append(site.Pages, page)
+		site.byPath[relPath] = page
+	}
+
+	limit := defaultRelatedLimit
+	if cfg != nil && cfg.Related != 0 {
+		limit = cfg.Related
+	}
+	var menuConfig map[string][]MenuItem
+	if cfg != nil {
+		menuConfig = cfg.Menus
+	}
+	site.menus = buildMenus(menuConfig, site.Pages)
+	site.linkTranslations(mp.languages)
+	site.linkBreadcrumbs()
+	site.linkNeighbours()
+	site.linkRelated(limit)
+	site.indexWikiNames()
+	site.linkBacklinks()
+	return site, nil
+}
+
+func newPage(relPath string, root ast.Node, src []byte, meta map[string]interface{}) *Page {
+	page := &Page{
+		RelPath: relPath,
+		Section: pageSection(relPath),
+		Meta:    meta,
+		Tags:    metaStrings(meta["tags"]),
+		key:     relPath,
+	}
+	if date, ok := parseDate(meta["date"]); ok {
+		page.Date = date
+	}
+	page.Summary = summarize(nil, root, src, meta).Text
+	if title, ok := meta["title"].(string); ok && title != "" {
+		page.Title = title
+	} else if heading := firstHeading(root, src); heading != "" {
+		page.Title = heading
+	} else {
+		page.Title = titleFromFilename(relPath)
+	}
+	return page
+}
+
+// setPageLanguage sets the language of a page, and takes its section and
+// translation key from its path without language marker
+func setPageLanguage(page *Page, languages *Languages) {
+	lang, base, _ := languages.Split(page.RelPath)
+	if lang == nil {
+		return
+	}
+	page.Language = lang
+	page.key = base
+	page.Section = pageSection(base)
+}
+
+// pageSection returns the top-level directory of a path, "" at the root
+func pageSection(relPath string) string {
+	if dir, _, ok := strings.Cut(filepath.ToSlash(relPath), "/"); ok {
+		return dir
+	}
+	return ""
+}
+
+// langCode returns the page's language code, "" for single-language sites
+func (p *Page) langCode() string {
+	if p.Language == nil {
+		return ""
+	}
+	return p.Language.Code
+}
+
+// firstHeading returns the text of the first heading in a document
+func firstHeading(root ast.Node, src []byte) string {
+	var title string
+	ast.Walk(root, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if h, ok := n.(*ast.Heading); ok && entering {
+			title = nodeText(h, src)
+			return ast.WalkStop, nil
+		}
+		return ast.WalkContinue, nil
+	})
+	return title
+}
+
+// nodeText concatenates the text segments below a node
+func nodeText(n ast.Node, src []byte) string {
+	var b strings.Builder
+	ast.Walk(n, func(c ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			// Keep words in separate blocks apart
+			if c.Type() == ast.TypeBlock {
+				b.WriteByte(' ')
+			}
+			return ast.WalkContinue, nil
+		}
+		switch t := c.(type) {
+		case *ast.Text:
+			b.Write(t.Segment.Value(src))
+			if t.SoftLineBreak() {
+				b.WriteByte(' ')
+			}
+		case *ast.String:
+			b.Write(t.Value)
+		case *ast.CodeSpan:
+			for child := t.FirstChild(); child != nil; child = child.NextSibling() {
+				if txt, ok := child.(*ast.Text); ok {
+					b.Write(txt.Segment.Value(src))
+				}
+			}
+			return ast.WalkSkipChildren, nil
+		}
+		return ast.WalkContinue, nil
+	})
+	return strings.TrimSpace(b.String())
+}
+
+// metaStrings reads a frontmatter list such as tags, accepting a YAML/TOML
+// list or a comma-separated string
+func metaStrings(v interface{}) []string {
+	var out []string
+	switch v := v.(type) {
+	case []interface{}:
+		for _, item := range v {
+			if s := strings.TrimSpace(fmt.Sprint(item)); s != "" {
+				out = append(out, s)
+			}
+		}
+	case []string:
+		out = append(out, v...)
+	case string:
+		for _, s := range strings.Split(v, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				out = append(out, s)
+			}
+		}
+	}
+	return out
+}
+
+// Page returns the page for a source path, or nil if unknown
+func (s *Site) Page(relPath string) *Page {
+	if s == nil {
+		return nil
+	}
+	return s.byPath[relPath]
+}
+
+// isIndexPage reports whether a page is a section index rather than an entry
+func isIndexPage(relPath string) bool {
+	base := strings.TrimSuffix(filepath.Base(relPath), filepath.Ext(relPath))
+	return base == "index"
+}
+
+// linkTranslations links each page to the pages in other languages sharing
+// its key, in the order the languages are configured
+func (s *Site) linkTranslations(languages *Languages) {
+	order := make(map[*LanguageConfig]int)
+	for i, lang := range languages.List() {
+		order[lang] = i
+	}
+	byKey := make(map[string][]*Page)
+	for _, p := range s.Pages {
+		if p.Language != nil {
+			byKey[p.key] = append(byKey[p.key], p)
+		}
+	}
+	for _, pages := range byKey {
+		sort.SliceStable(pages, func(i, j int) bool {
+			return order[pages[i].Language] < order[pages[j].Language]
+		})
+		for _, p := range pages {
+			for _, other := range pages {
+				if other != p {
+					p.translations = append(p.translations, other)
+				}
+			}
+		}
+	}
+}
+
+// linkNeighbours orders the dated pages of each section and language by
+// date and links each page to the one before and after it
+func (s *Site) linkNeighbours() {
+	sections := make(map[string][]*Page)
+	for _, p := range s.Pages {
+		if p.Date.IsZero() || isIndexPage(p.RelPath) || isNotFoundPage(nil, p.key) {
+			continue
+		}
+		key := p.langCode() + "\x00" + p.Section
+		sections[key] = append(sections[key], p)
+	}
+	for _, pages := range sections {
+		sort.SliceStable(pages, func(i, j int) bool {
+			if !pages[i].Date.Equal(pages[j].Date) {
+				return pages[i].Date.Before(pages[j].Date)
+			}
+			return pages[i].RelPath < pages[j].RelPath
+		})
+		for i, p := range pages {
+			if i > 0 {
+				p.prev = pages[i-1]
+			}
+			if i < len(pages)-1 {
+				p.next = pages[i+1]
+			}
+		}
+	}
+}
+
+// linkRelated ranks other pages in the same language by the number of tags
+// they share with each page, newest first on ties, keeping at most limit pages
+func (s *Site) linkRelated(limit int) {
+	if limit <= 0 {
+		return
+	}
+	for _, p := range s.Pages {
+		if len(p.Tags) == 0 {
+			continue
+		}
+		tags := make(map[string]bool, len(p.Tags))
+		for _, t := range p.Tags {
+			tags[strings.ToLower(t)] = true
+		}
+		type scored struct {
+			page  *Page
+			score int
+		}
+		var candidates []scored
+		for _, other := range s.Pages {
+			if other == p || other.langCode() != p.langCode() {
+				continue
+			}
+			score := 0
+			for _, t := range other.Tags {
+				if tags[strings.ToLower(t)] {
+					score++
+				}
+			}
+			if score > 0 {
+				candidates = append(candidates, scored{other, score})
+			}
+		}
+		sort.SliceStable(candidates, func(i, j int) bool {
+			a, b := candidates[i], candidates[j]
+			if a.score != b.score {
+				return a.score > b.score
+			}
+			if !a.page.Date.Equal(b.page.Date) {
+				return a.page.Date.After(b.page.Date)
+			}
+			return a.page.RelPath < b.page.RelPath
+		})
+		for i := 0; i < len(candidates) && i < limit; i++ {
+			p.related = append(p.related, candidates[i].page)
+		}
+	}
+}
+
+// ContextKey summarises the data a page takes from other pages. Incremental
+// builds rebuild a page when its key changes, e.g. because a neighbour's date
+// or tags changed. Pages listing the site's pages depend on all of them.
+func (s *Site) ContextKey(relPath string, listing bool) string {
+	page := s.Page(relPath)
+	if page == nil {
+		return ""
+	}
+	h := sha256.New()
+	writeLink := func(label string, p *Page) {
+		if p != nil {
+			fmt.Fprintf(h, "%s\x00%s\x00%s\x00%s\n", label, p.URL, p.Title, p.DisplayDate())
+		}
+	}
+	writeLink("prev", page.prev)
+	writeLink("next", page.next)
+	for _, t := range page.translations {
+		writeLink("translation", t)
+	}
+	for _, crumb := range page.breadcrumbs {
+		fmt.Fprintf(h, "breadcrumb\x00%s\x00%s\n", crumb.URL, crumb.Title)
+	}
+	writeMenus(h, s.menus, page.langCode())
+	for _, r := range page.related {
+		writeLink("related", r)
+	}
+	for _, b := range page.backlinks {
+		writeLink("backlink", b)
+	}
+	for _, target := range page.wikiLinks {
+		name, _ := splitWikiTarget(target)
+		fmt.Fprintf(h, "wikilink\x00%s\n", target)
+		writeLink("wikilink", s.resolveWikiLink(page, name))
+	}
+	if listing {
+		fmt.Fprintf(h, "pages\x00%s\n", s.pagesHash())
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// pagesHash summarises the URL, title and date of every page
+func (s *Site) pagesHash() string {
+	if s.pagesKey == "" {
+		h := sha256.New()
+		for _, p := range s.Pages {
+			fmt.Fprintf(h, "%s\x00%s\x00%s\n", p.URL, p.Title, p.DisplayDate())
+		}
+		s.pagesKey = hex.EncodeToString(h.Sum(nil))
+	}
+	return s.pagesKey
+}
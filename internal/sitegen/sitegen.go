@@ -2,13 +2,40 @@
 package sitegen
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
-	"io"
 	"os"
 	"path/filepath"
 	"time"
 )
 
+// BuildOptions holds the settings for a site build. Zero values fall back to
+// the site config file and then to the built-in defaults.
+type BuildOptions struct {
+	InputDir      string
+	OutputDir     string
+	SizeThreshold int
+	NoIncremental bool
+	RSSURL        string
+	RSSMaxItems   int
+	KeepOrphaned  bool
+	Template      string
+	HeaderFile    string
+	FooterFile    string
+	NoHeader      bool
+	NoFooter      bool
+	CSSFile       string
+	Theme         string
+	BasePath      string // path the site is served from, overrides base_path in the config
+	RelativeURLs  bool   // make internal links relative, see relativeURLs
+	PrettyURLs    bool   // write pages as foo/index.html, see prettyOutputPath
+	Check         bool   // check the links of the built site, see CheckLinks
+	SafeHTML      bool   // remove or sanitize raw HTML, see SafeHTMLConfig
+}
+
+// BuildSite builds a site with the given positional options, see Build.
 func BuildSite(
 	inputDir, outputDir string,
 	sizeThreshold int,
@@ -23,64 +50,185 @@ func BuildSite(
 	noFooter bool,
 	cssFile string,
 ) error {
-	// Copy CSS file to output directory
-	cssDst := filepath.Join(outputDir, "style.css")
-	if cssFile != "" {
-		// Use user-supplied CSS file
-		cssIn, err := os.Open(cssFile)
-		if err == nil {
-			defer cssIn.Close()
-			cssOut, err := os.Create(cssDst)
-			if err == nil {
-				defer cssOut.Close()
-				io.Copy(cssOut, cssIn)
-			}
-		}
-	} else {
-		// Use embedded style.css
-		cssIn, err := EmbeddedFiles.Open("style.css")
-		if err == nil {
-			defer cssIn.Close()
-			cssOut, err := os.Create(cssDst)
-			if err == nil {
-				defer cssOut.Close()
-				io.Copy(cssOut, cssIn)
-			}
-		}
-	}
+	return Build(BuildOptions{
+		InputDir:      inputDir,
+		OutputDir:     outputDir,
+		SizeThreshold: sizeThreshold,
+		NoIncremental: noIncremental,
+		RSSURL:        rssURL,
+		RSSMaxItems:   rssMaxItems,
+		KeepOrphaned:  keepOrphaned,
+		Template:      templateOpt,
+		HeaderFile:    headerFile,
+		FooterFile:    footerFile,
+		NoHeader:      noHeader,
+		NoFooter:      noFooter,
+		CSSFile:       cssFile,
+	})
+}
+
+// Build generates a static site from opts.InputDir into opts.OutputDir
+func Build(opts BuildOptions) error {
 	// Validate inputs and create output directory
-	if err := validateInputsAndCreateOutput(inputDir, outputDir); err != nil {
+	if err := validateInputsAndCreateOutput(opts.InputDir, opts.OutputDir); err != nil {
+		return err
+	}
+
+	cfg, err := LoadConfig(opts.InputDir)
+	if err != nil {
+		return err
+	}
+	if opts.Theme == "" {
+		opts.Theme = cfg.Theme
+	}
+	if opts.BasePath != "" {
+		cfg.BasePath = opts.BasePath
+	}
+	cfg.RelativeURLs = cfg.RelativeURLs || opts.RelativeURLs
+	cfg.PrettyURLs = cfg.PrettyURLs || opts.PrettyURLs
+	cfg.SafeHTML.Enabled = cfg.SafeHTML.Enabled || opts.SafeHTML
+	if err := cfg.resolveURLs(opts.RSSURL); err != nil {
+		return err
+	}
+	theme, err := ResolveTheme(opts.Theme, opts.InputDir)
+	if err != nil {
+		return err
+	}
+	languages, err := NewLanguages(opts.InputDir, cfg.Languages)
+	if err != nil {
 		return err
 	}
 
 	startTime := time.Now()
-	fmt.Printf("[Build] Starting site build from '%s' to '%s'...\n", inputDir, outputDir)
+	fmt.Printf("[Build] Starting site build from '%s' to '%s' with theme '%s'...\n", opts.InputDir, opts.OutputDir, theme.Name)
 
 	// Discover files
-	fileSet, err := DiscoverFiles(inputDir)
+	fileSet, err := DiscoverFiles(opts.InputDir)
 	if err != nil {
 		return fmt.Errorf("error discovering files: %w", err)
 	}
 
 	logDiscoveredFiles(fileSet)
 
-	// Try incremental build first
-	if !noIncremental {
-		if completed, err := tryIncrementalBuild(
-			inputDir, outputDir, sizeThreshold, rssURL, rssMaxItems, fileSet, startTime, keepOrphaned, templateOpt,
-			headerFile, footerFile, noHeader, noFooter,
-		); err != nil {
+	themeFiles, err := writeThemeAssets(theme, opts.CSSFile, opts.OutputDir, fileSet)
+	if err != nil {
+		return err
+	}
+
+	shortcodes, err := LoadShortcodes(theme)
+	if err != nil {
+		return err
+	}
+	processor := NewMarkdownProcessorWithConfig(opts.Template, cfg)
+	processor.SetTheme(theme)
+	processor.SetShortcodes(shortcodes)
+	processor.SetLanguages(languages)
+	processor.SetIncludeFiles(fileSet.MarkdownFiles)
+
+	// Gather headers, footers and page metadata before rendering any page
+	partials, err := LoadPartials(processor, opts.InputDir, fileSet.MarkdownFiles, opts.HeaderFile, opts.FooterFile, opts.NoHeader, opts.NoFooter)
+	if err != nil {
+		return err
+	}
+	pages := partials.Pages(fileSet.MarkdownFiles)
+	site, err := LoadSite(processor, cfg, opts.InputDir, pages)
+	if err != nil {
+		return err
+	}
+	processor.SetSite(site)
+	mermaidSrc, mermaidSize := mermaidScript(cfg.Mermaid, opts.InputDir, fileSet)
+	processor.SetMermaidScript(mermaidSrc, mermaidSize)
+	prevCache, _ := loadCache(getCachePath(opts.OutputDir))
+	images, err := ProcessImages(cfg.Images, opts.InputDir, opts.OutputDir, fileSet, prevCache)
+	if err != nil {
+		return err
+	}
+	processor.SetImages(images)
+	themeFiles = append(themeFiles, images.outputs()...)
+
+	bc := &buildContext{
+		opts:       opts,
+		processor:  processor,
+		partials:   partials,
+		languages:  languages,
+		pages:      pages,
+		fileSet:    fileSet,
+		themeFiles: themeFiles,
+		images:     images,
+		settings:   buildFingerprint(opts, cfg, theme, shortcodes, languages, mermaidSrc),
+		startTime:  startTime,
+	}
+
+	// Try incremental build first, falling back to a full build
+	completed := false
+	if !opts.NoIncremental {
+		if completed, err = tryIncrementalBuild(bc); err != nil {
+			return err
+		}
+	}
+	if !completed {
+		if err := performFullBuild(bc); err != nil {
 			return err
-		} else if completed {
-			return nil
 		}
 	}
 
-	// Fall back to full build
-	return performFullBuild(
-		inputDir, outputDir, sizeThreshold, rssURL, rssMaxItems, fileSet, startTime, keepOrphaned, templateOpt,
-		headerFile, footerFile, noHeader, noFooter,
-	)
+	if opts.Check {
+		problems, err := CheckLinks(opts.OutputDir, cfg.BasePath)
+		if err != nil {
+			return err
+		}
+		return ReportLinks(problems)
+	}
+	return nil
+}
+
+// buildContext holds everything resolved before pages are rendered
+type buildContext struct {
+	opts       BuildOptions
+	processor  *MarkdownProcessor
+	partials   *Partials
+	languages  *Languages
+	pages      []string // markdown files rendered as pages
+	fileSet    *FileSet
+	themeFiles []string  // output files generated without a source, e.g. from the theme
+	images     *ImageSet // resized variants of the images, see ProcessImages
+	settings   string    // see buildFingerprint
+	startTime  time.Time
+}
+
+// feedURL returns the base of the RSS feed links: the --rss URL, below the
+// site's base path when it points at the domain root
+func (bc *buildContext) feedURL() string {
+	if bc.opts.RSSURL == "" {
+		return ""
+	}
+	if u, err := siteURL(bc.opts.RSSURL, bc.processor.config.BasePath); err == nil {
+		return u
+	}
+	return bc.opts.RSSURL
+}
+
+// buildFingerprint summarises the settings that affect every page, so that
+// changing them forces a full rebuild instead of an incremental one.
+func buildFingerprint(opts BuildOptions, cfg *Config, theme *Theme, shortcodes *Shortcodes, languages *Languages, mermaidSrc string) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%s\x00%s\x00%s\x00%t\x00%t\x00%s\x00",
+		opts.Template, theme.Name, theme.Dir, opts.HeaderFile, opts.FooterFile, opts.NoHeader, opts.NoFooter, mermaidSrc)
+	if cfgJSON, err := json.Marshal(cfg); err == nil {
+		h.Write(cfgJSON)
+	}
+	for _, layout := range []string{opts.Template, NotFoundLayout} {
+		if src, err := theme.LayoutSource(layout); err == nil {
+			h.Write(src)
+		}
+	}
+	shortcodes.writeSources(h)
+	if languages != nil {
+		if i18nJSON, err := json.Marshal(languages.strings); err == nil {
+			h.Write(i18nJSON)
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
 }
 
 // validateInputsAndCreateOutput validates input directory and creates output directory
@@ -114,222 +262,145 @@ func logDiscoveredFiles(fileSet *FileSet) {
 }
 
 // tryIncrementalBuild attempts an incremental build, returns (completed, error)
-func tryIncrementalBuild(
-	inputDir, outputDir string,
-	sizeThreshold int,
-	rssURL string,
-	rssMaxItems int,
-	fileSet *FileSet,
-	startTime time.Time,
-	keepOrphaned bool,
-	templateOpt string,
-	headerFile string,
-	footerFile string,
-	noHeader bool,
-	noFooter bool,
-) (bool, error) {
+func tryIncrementalBuild(bc *buildContext) (bool, error) {
+	opts := bc.opts
+	inputDir, outputDir := opts.InputDir, opts.OutputDir
 	cachePath := getCachePath(outputDir)
 	cache, err := loadCache(cachePath)
 	if err != nil || cache.Version != 1 {
 		fmt.Printf("[Build] No valid cache found, doing full rebuild\n")
 		return false, nil
 	}
+	if cache.Settings != bc.settings {
+		fmt.Printf("[Build] Build settings or theme changed, doing full rebuild\n")
+		return false, nil
+	}
 
 	fmt.Printf("[Build] Loaded cache from %s\n", cachePath)
 
 	// Perform incremental build
-	builder := NewIncrementalBuilder(inputDir, outputDir, sizeThreshold, cache, templateOpt)
-	sizeOut := make(chan string, len(fileSet.MarkdownFiles))
+	builder := NewIncrementalBuilder(inputDir, outputDir, opts.SizeThreshold, cache, bc.processor)
+	sizeOut := make(chan string, len(bc.pages))
 
 	// Process files incrementally
-	// Prepare header/footer HTML
-	var headerHTML, footerHTML []byte
-	if !noHeader {
-		headerPath := headerFile
-		if headerPath == "" {
-			headerPath = filepath.Join(inputDir, "header.md")
-		}
-		if data, err := os.ReadFile(headerPath); err == nil {
-			headerHTML = SimpleMarkdownToHTML(data)
-		}
-	}
-	if !noFooter {
-		footerPath := footerFile
-		if footerPath == "" {
-			footerPath = filepath.Join(inputDir, "footer.md")
-		}
-		if data, err := os.ReadFile(footerPath); err == nil {
-			footerHTML = SimpleMarkdownToHTML(data)
-		}
-	}
-	// Filter out the actual header/footer files in use (only if injection is enabled)
-	var filteredFiles []string
-	headerBase := ""
-	footerBase := ""
-	if !noHeader {
-		if headerFile != "" {
-			headerBase = filepath.Base(headerFile)
-		} else {
-			headerBase = "header.md"
-		}
-	}
-	if !noFooter {
-		if footerFile != "" {
-			footerBase = filepath.Base(footerFile)
-		} else {
-			footerBase = "footer.md"
-		}
-	}
-	for _, f := range fileSet.MarkdownFiles {
-		base := filepath.Base(f)
-		if (headerBase != "" && base == headerBase) || (footerBase != "" && base == footerBase) {
-			continue
-		}
-		filteredFiles = append(filteredFiles, f)
-	}
-	if err := builder.ProcessMarkdownFilesWithHeaderFooter(filteredFiles, sizeOut, headerHTML, footerHTML); err != nil {
+	if err := builder.ProcessMarkdownFilesWithHeaderFooter(bc.pages, sizeOut, bc.partials); err != nil {
 		return false, err
 	}
-	if err := builder.ProcessAssetFiles(fileSet.AssetFiles); err != nil {
+	if err := builder.ProcessAssetFiles(bc.fileSet.AssetFiles); err != nil {
 		return false, err
 	}
 
 	// Cleanup removed files (if not keeping orphaned files)
-	if !keepOrphaned {
+	if !opts.KeepOrphaned {
 		builder.CleanupRemovedFiles()
+		builder.CleanupGenerated(bc.themeFiles)
 	}
 
 	// Print size check results
-	for i := 0; i < len(filteredFiles); i++ {
+	for i := 0; i < len(bc.pages); i++ {
 		fmt.Fprint(os.Stderr, <-sizeOut)
 	}
 
 	// Generate RSS feed and save cache
-	if err := generateRSSFeed(rssURL, outputDir, fileSet.MarkdownFiles, inputDir, rssMaxItems); err != nil {
+	if err := generateRSSFeed(bc.feedURL(), outputDir, bc.pages, inputDir, opts.RSSMaxItems, bc.languages, bc.processor.config.PrettyURLs); err != nil {
 		return false, err
 	}
 
-	cacheManager := NewCacheManager(inputDir, outputDir)
-	if err := cacheManager.SaveCache(builder.GetNewCache()); err != nil {
+	cacheManager := NewCacheManager(inputDir, outputDir, bc.languages, bc.processor.config.PrettyURLs)
+	newCache := builder.GetNewCache()
+	newCache.Settings = bc.settings
+	newCache.Images = bc.images.cacheEntries()
+	newCache.Generated = bc.themeFiles
+	if err := cacheManager.SaveCache(newCache); err != nil {
 		return false, fmt.Errorf("failed to save cache: %w", err)
 	}
 
-	fmt.Printf("[Build] Incremental build complete in %v.\n", time.Since(startTime))
+	fmt.Printf("[Build] Incremental build complete in %v.\n", time.Since(bc.startTime))
 	return true, nil
 }
 
 // performFullBuild performs a complete rebuild
-func performFullBuild(
-	inputDir, outputDir string,
-	sizeThreshold int,
-	rssURL string,
-	rssMaxItems int,
-	fileSet *FileSet,
-	startTime time.Time,
-	keepOrphaned bool,
-	templateOpt string,
-	headerFile string,
-	footerFile string,
-	noHeader bool,
-	noFooter bool,
-) error {
-	builder := NewFullBuilder(inputDir, outputDir, sizeThreshold, templateOpt)
+func performFullBuild(bc *buildContext) error {
+	opts := bc.opts
+	inputDir, outputDir := opts.InputDir, opts.OutputDir
+	builder := NewFullBuilder(inputDir, outputDir, opts.SizeThreshold, bc.processor)
 
 	// Process asset files
-	if err := builder.ProcessAssetFiles(fileSet.AssetFiles); err != nil {
+	if err := builder.ProcessAssetFiles(bc.fileSet.AssetFiles); err != nil {
 		return err
 	}
 
 	// Process markdown files
-	sizeOut := make(chan string, len(fileSet.MarkdownFiles))
-	// Prepare header/footer HTML
-	var headerHTML, footerHTML []byte
-	if !noHeader {
-		headerPath := headerFile
-		if headerPath == "" {
-			headerPath = filepath.Join(inputDir, "header.md")
-		}
-		if data, err := os.ReadFile(headerPath); err == nil {
-			headerHTML = SimpleMarkdownToHTML(data)
-		}
-	}
-	if !noFooter {
-		footerPath := footerFile
-		if footerPath == "" {
-			footerPath = filepath.Join(inputDir, "footer.md")
-		}
-		if data, err := os.ReadFile(footerPath); err == nil {
-			footerHTML = SimpleMarkdownToHTML(data)
-		}
-	}
-	// Filter out the actual header/footer files in use (only if injection is enabled)
-	var filteredFiles []string
-	headerBase := ""
-	footerBase := ""
-	if !noHeader {
-		if headerFile != "" {
-			headerBase = filepath.Base(headerFile)
-		} else {
-			headerBase = "header.md"
-		}
-	}
-	if !noFooter {
-		if footerFile != "" {
-			footerBase = filepath.Base(footerFile)
-		} else {
-			footerBase = "footer.md"
-		}
-	}
-	for _, f := range fileSet.MarkdownFiles {
-		base := filepath.Base(f)
-		if (headerBase != "" && base == headerBase) || (footerBase != "" && base == footerBase) {
-			continue
-		}
-		filteredFiles = append(filteredFiles, f)
-	}
-	if err := builder.ProcessMarkdownFilesWithHeaderFooter(filteredFiles, sizeOut, headerHTML, footerHTML); err != nil {
+	sizeOut := make(chan string, len(bc.pages))
+	if err := builder.ProcessMarkdownFilesWithHeaderFooter(bc.pages, sizeOut, bc.partials); err != nil {
 		return err
 	}
 
 	// Print size check results
-	for i := 0; i < len(filteredFiles); i++ {
+	for i := 0; i < len(bc.pages); i++ {
 		fmt.Fprint(os.Stderr, <-sizeOut)
 	}
 
 	// Generate RSS feed
-	if err := generateRSSFeed(rssURL, outputDir, fileSet.MarkdownFiles, inputDir, rssMaxItems); err != nil {
+	if err := generateRSSFeed(bc.feedURL(), outputDir, bc.pages, inputDir, opts.RSSMaxItems, bc.languages, bc.processor.config.PrettyURLs); err != nil {
 		return err
 	}
 
 	// Cleanup orphaned files (if not keeping orphaned files)
-	if !keepOrphaned {
-		cleaner := NewOutputCleaner(outputDir, rssURL)
-		if err := cleaner.CleanupOrphanedFiles(fileSet); err != nil {
+	if !opts.KeepOrphaned {
+		cleaner := NewOutputCleaner(outputDir, opts.RSSURL, bc.languages, bc.processor.config.PrettyURLs)
+		cleaner.KeepGenerated(bc.themeFiles...)
+		if err := cleaner.CleanupOrphanedFiles(bc.fileSet); err != nil {
 			return err
 		}
 	}
 
 	// Create and save cache
-	cacheManager := NewCacheManager(inputDir, outputDir)
-	newCache, err := cacheManager.CreateCacheFromFileSet(fileSet)
+	cacheManager := NewCacheManager(inputDir, outputDir, bc.languages, bc.processor.config.PrettyURLs)
+	newCache, err := cacheManager.CreateCacheFromFileSet(bc.fileSet, bc.pages)
 	if err != nil {
 		return err
 	}
+	newCache.Settings = bc.settings
+	newCache.Images = bc.images.cacheEntries()
+	newCache.Generated = bc.themeFiles
+	for _, relPath := range bc.pages {
+		_, _, deps := bc.partials.ForPage(relPath)
+		entry := newCache.Files[relPath]
+		entry.Deps = depMtimes(inputDir, append(deps, bc.processor.contentDeps(relPath)...))
+		entry.Context = bc.processor.contextKey(relPath)
+		entry.Listing = bc.processor.listings[relPath]
+		newCache.Files[relPath] = entry
+	}
 	if err := cacheManager.SaveCache(newCache); err != nil {
 		return fmt.Errorf("failed to save cache: %w", err)
 	}
 
-	fmt.Printf("[Build] Site build complete in %v.\n", time.Since(startTime))
+	fmt.Printf("[Build] Site build complete in %v.\n", time.Since(bc.startTime))
 	return nil
 }
 
-// generateRSSFeed generates RSS feed if requested
-func generateRSSFeed(rssURL, outputDir string, markdownFiles []string, inputDir string, rssMaxItems int) error {
-	if rssURL != "" {
+// generateRSSFeed generates RSS feed if requested, one per language on multilingual
+// sites, from the markdown files that are pages rather than partials
+func generateRSSFeed(rssURL, outputDir string, pages []string, inputDir string, rssMaxItems int, languages *Languages, prettyURLs bool) error {
+	if rssURL == "" {
+		return nil
+	}
+	if languages == nil {
 		rssGen := NewRSSGenerator(rssURL, outputDir)
-		if err := rssGen.Generate(markdownFiles, inputDir, rssMaxItems); err != nil {
+		rssGen.SetPrettyURLs(prettyURLs)
+		if err := rssGen.Generate(pages, inputDir, rssMaxItems); err != nil {
 			return fmt.Errorf("failed to generate RSS feed: %w", err)
 		}
+		return nil
+	}
+	for _, lang := range languages.List() {
+		rssGen := NewRSSGenerator(rssURL, filepath.Join(outputDir, filepath.FromSlash(lang.Prefix)))
+		rssGen.SetLanguage(languages, lang)
+		rssGen.SetPrettyURLs(prettyURLs)
+		if err := rssGen.Generate(pages, inputDir, rssMaxItems); err != nil {
+			return fmt.Errorf("failed to generate RSS feed for language '%s': %w", lang.Code, err)
+		}
 	}
 	return nil
 }
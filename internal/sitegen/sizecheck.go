@@ -8,6 +8,12 @@ import (
 )
 
 func CheckGzipSize(path string, threshold int, out chan<- string) {
+	CheckGzipSizeWithScripts(path, 0, threshold, out)
+}
+
+// CheckGzipSizeWithScripts reports the compressed size of a page plus the
+// compressed size of the scripts it loads, such as Mermaid
+func CheckGzipSizeWithScripts(path string, scriptSize, threshold int, out chan<- string) {
 	go func() {
 		data, err := os.ReadFile(path)
 		if err != nil {
@@ -20,10 +26,14 @@ func CheckGzipSize(path string, threshold int, out chan<- string) {
 		if gzErr != nil {
 			return
 		}
-		sizeKB := float64(gzBuf.Len()) / 1024
+		size := gzBuf.Len() + scriptSize
+		sizeKB := float64(size) / 1024
 		threshKB := float64(threshold) / 1024
 		msg := fmt.Sprintf("[Size] %s: compressed size is %.1fKB\n", path, sizeKB)
-		if gzBuf.Len() > threshold {
+		if scriptSize > 0 {
+			msg = fmt.Sprintf("[Size] %s: compressed size is %.1fKB (%.1fKB page + %.1fKB scripts)\n", path, sizeKB, float64(gzBuf.Len())/1024, float64(scriptSize)/1024)
+		}
+		if size > threshold {
 			msg += fmt.Sprintf("[WARN] %s: compressed size is %.1fKB (> %.1fKB)\n", path, sizeKB, threshKB)
 		}
 		out <- msg
@@ -0,0 +1,94 @@
+// summary.go - Page summaries, word counts and reading time
+package sitegen
+
+import (
+	"bytes"
+	"html"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/renderer"
+)
+
+// summaryMarker separates the summary from the rest of a page
+const summaryMarker = "<!--more-->"
+
+// wordsPerMinute is the reading speed used for .ReadingTime
+const wordsPerMinute = 200
+
+// pageSummary is a page summary as HTML for templates and as plain text for feeds
+type pageSummary struct {
+	HTML string
+	Text string
+}
+
+// summarize picks a page summary from, in order: the content before a
+// <!--more--> marker, the frontmatter summary, or the first paragraph.
+// The renderer is only used for the HTML form and may be nil.
+func summarize(r renderer.Renderer, root ast.Node, src []byte, meta map[string]interface{}) pageSummary {
+	var nodes []ast.Node
+	for n := root.FirstChild(); n != nil; n = n.NextSibling() {
+		if isSummaryMarker(n, src) {
+			return renderSummary(r, nodes, src)
+		}
+		nodes = append(nodes, n)
+	}
+
+	if s, ok := meta["summary"].(string); ok && strings.TrimSpace(s) != "" {
+		s = strings.TrimSpace(s)
+		return pageSummary{HTML: "<p>" + html.EscapeString(s) + "</p>", Text: s}
+	}
+
+	for n := root.FirstChild(); n != nil; n = n.NextSibling() {
+		if n.Kind() == ast.KindParagraph {
+			return renderSummary(r, []ast.Node{n}, src)
+		}
+	}
+	return pageSummary{}
+}
+
+func isSummaryMarker(n ast.Node, src []byte) bool {
+	block, ok := n.(*ast.HTMLBlock)
+	if !ok {
+		return false
+	}
+	var raw bytes.Buffer
+	lines := block.Lines()
+	for i := 0; i < lines.Len(); i++ {
+		seg := lines.At(i)
+		raw.Write(seg.Value(src))
+	}
+	return strings.TrimSpace(raw.String()) == summaryMarker
+}
+
+func renderSummary(r renderer.Renderer, nodes []ast.Node, src []byte) pageSummary {
+	var htmlBuf bytes.Buffer
+	var text []string
+	for _, n := range nodes {
+		if r != nil {
+			if err := r.Render(&htmlBuf, src, n); err != nil {
+				continue
+			}
+		}
+		if t := nodeText(n, src); t != "" {
+			text = append(text, t)
+		}
+	}
+	return pageSummary{
+		HTML: strings.TrimSpace(htmlBuf.String()),
+		Text: strings.Join(text, " "),
+	}
+}
+
+// countWords counts the words of prose in a document
+func countWords(root ast.Node, src []byte) int {
+	return len(strings.Fields(nodeText(root, src)))
+}
+
+// readingTime estimates the minutes needed to read a number of words, rounding up
+func readingTime(words int) int {
+	if words == 0 {
+		return 0
+	}
+	return (words + wordsPerMinute - 1) / wordsPerMinute
+}
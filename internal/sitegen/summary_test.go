@@ -0,0 +1,81 @@
+package sitegen
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSummarize(t *testing.T) {
+	mp := NewMarkdownProcessor("")
+	tests := []struct {
+		name    string
+		content string
+		html    string
+		text    string
+	}{
+		{
+			"more marker",
+			"# Title\n\nIntro **bold**.\n\nSecond part.\n\n<!--more-->\n\nRest of the page.",
+			"<h1 id=\"title\">Title</h1>\n<p>Intro <strong>bold</strong>.</p>\n<p>Second part.</p>",
+			"Title Intro bold. Second part.",
+		},
+		{
+			"frontmatter summary",
+			"---\nsummary: A <short> summary\n---\n\n# Title\n\nFirst paragraph.",
+			"<p>A &lt;short&gt; summary</p>",
+			"A <short> summary",
+		},
+		{
+			"first paragraph",
+			"# Title\n\n- a list\n\nFirst *paragraph*\nover two lines.\n\nSecond paragraph.",
+			"<p>First <em>paragraph</em>\nover two lines.</p>",
+			"First paragraph over two lines.",
+		},
+		{
+			"no paragraph",
+			"# Title",
+			"",
+			"",
+		},
+	}
+	for _, test := range tests {
+		root, src, meta := mp.parseMarkdown([]byte(test.content), "")
+		got := summarize(mp.md.Renderer(), root, src, meta)
+		if got.HTML != test.html {
+			t.Errorf("%s: HTML = %q, want %q", test.name, got.HTML, test.html)
+		}
+		if got.Text != test.text {
+			t.Errorf("%s: Text = %q, want %q", test.name, got.Text, test.text)
+		}
+	}
+}
+
+func TestReadingTime(t *testing.T) {
+	for words, want := range map[int]int{0: 0, 1: 1, 200: 1, 201: 2, 1000: 5} {
+		if got := readingTime(words); got != want {
+			t.Errorf("readingTime(%d) = %d, want %d", words, got, want)
+		}
+	}
+}
+
+func TestBuild_SummaryTemplateData(t *testing.T) {
+	inputDir := t.TempDir()
+	outputDir := t.TempDir()
+	writeTestFile(t, filepath.Join(inputDir, "templates", "summary.html"),
+		"<p class=\"stats\">{{ .WordCount }} words, {{ .ReadingTime }} min</p><div class=\"summary\">{{ .Summary }}</div>")
+	writeTestFile(t, filepath.Join(inputDir, "post.md"),
+		"# Post\n\nThe opening paragraph.\n\n<!--more-->\n\n"+strings.Repeat("word ", 300))
+
+	if err := Build(BuildOptions{InputDir: inputDir, OutputDir: outputDir, Template: "summary"}); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	out := readTestFile(t, filepath.Join(outputDir, "post.html"))
+	if !strings.Contains(out, `<p class="stats">304 words, 2 min</p>`) {
+		t.Errorf("expected word count and reading time, got:\n%s", out)
+	}
+	if !strings.Contains(out, `<div class="summary"><h1 id="post">Post</h1>
+<p>The opening paragraph.</p></div>`) {
+		t.Errorf("expected summary before the more marker, got:\n%s", out)
+	}
+}
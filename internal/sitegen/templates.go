@@ -0,0 +1,146 @@
+// templates.go - Listing, ejecting and validating page layouts
+package sitegen
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ListTemplates returns the names of the bundled layouts
+func ListTemplates() ([]string, error) {
+	entries, err := fs.ReadDir(EmbeddedFiles, "templates")
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() && path.Ext(e.Name()) == ".html" {
+			names = append(names, strings.TrimSuffix(e.Name(), ".html"))
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// EjectTemplate copies a bundled layout and the stylesheet into dir as
+// templates/<name>.html and style.css, where a site build picks them up as
+// overrides. Existing files are only replaced when force is set. Returns the
+// paths written.
+func EjectTemplate(name, dir string, force bool) ([]string, error) {
+	layout, err := fs.ReadFile(EmbeddedFiles, "templates/"+name+".html")
+	if err != nil {
+		return nil, fmt.Errorf("unknown template %q, see 'colade templates list'", name)
+	}
+	css, err := fs.ReadFile(EmbeddedFiles, "templates/style.css")
+	if err != nil {
+		return nil, err
+	}
+	files := []struct {
+		path string
+		data []byte
+	}{
+		{filepath.Join(dir, "templates", name+".html"), layout},
+		{filepath.Join(dir, "style.css"), css},
+	}
+	if !force {
+		for _, f := range files {
+			if fileExists(f.path) {
+				return nil, fmt.Errorf("'%s' already exists, use --force to overwrite it", f.path)
+			}
+		}
+	}
+	var written []string
+	for _, f := range files {
+		if err := os.MkdirAll(filepath.Dir(f.path), 0755); err != nil {
+			return written, fmt.Errorf("failed to create directory for '%s': %w", f.path, err)
+		}
+		if err := os.WriteFile(f.path, f.data, 0644); err != nil {
+			return written, fmt.Errorf("failed to write '%s': %w", f.path, err)
+		}
+		written = append(written, f.path)
+	}
+	return written, nil
+}
+
+// ValidateTemplate parses a layout, given as a file or a bundled name, and
+// renders synthetic pages through it: a bare page as on a site without
+// config, and a page using every template variable. Builds fall back to the
+// bare content when a layout fails, so this is where the errors show up.
+func ValidateTemplate(templateOpt string) error {
+	src, err := (&Theme{Name: "default"}).LayoutSource(templateOpt)
+	if err != nil {
+		return fmt.Errorf("failed to read template '%s': %w", templateOpt, err)
+	}
+	tmpl, err := template.New("layout").Parse(string(src))
+	if err != nil {
+		return fmt.Errorf("failed to parse template '%s': %w", templateOpt, err)
+	}
+	bare, full := samplePages()
+	for _, sample := range []struct {
+		name string
+		data PageData
+	}{{"a bare page", bare}, {"a page using every variable", full}} {
+		if err := tmpl.Execute(io.Discard, sample.data); err != nil {
+			return fmt.Errorf("template '%s' failed to render %s: %w", templateOpt, sample.name, err)
+		}
+	}
+	return nil
+}
+
+// samplePages returns the data of a page without site, language or
+// navigation, and of a page of a multilingual site with all of them
+func samplePages() (bare, full PageData) {
+	content := []byte("<h2 id=\"intro\">Introduction</h2>\n<p>Sample content.</p>\n")
+	meta := map[string]interface{}{"title": "Sample page", "tags": []interface{}{"go", "templates"}}
+	bare = newPageData(content, nil, nil, map[string]interface{}{})
+	full = newPageData(content, []byte("<p>Header</p>"), []byte("<p>Footer</p>"), meta)
+	meta["date"] = "2024-01-02"
+	full.Date = "02 Jan 2024"
+
+	en := &LanguageConfig{Code: "en", Name: "English", Locale: "en-gb"}
+	ga := &LanguageConfig{Code: "ga", Name: "Gaeilge", Locale: "ga", Prefix: "ga"}
+	date := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	home := &Page{RelPath: "index.md", URL: "/index.html", Title: "Home", Language: en, Meta: map[string]interface{}{}}
+	page := &Page{RelPath: "blog/sample.md", URL: "/blog/sample.html", Title: "Sample page", Date: date,
+		Tags: []string{"go", "templates"}, Section: "blog", Summary: "Sample content.", Meta: meta, Language: en}
+	prev := &Page{RelPath: "blog/older.md", URL: "/blog/older.html", Title: "Older post", Date: date.AddDate(0, 0, -7),
+		Tags: []string{"go"}, Section: "blog", Meta: map[string]interface{}{}, Language: en}
+	next := &Page{RelPath: "blog/newer.md", URL: "/blog/newer.html", Title: "Newer post", Date: date.AddDate(0, 0, 7),
+		Section: "blog", Meta: map[string]interface{}{}, Language: en}
+	translation := &Page{RelPath: "blog/sample.ga.md", URL: "/ga/blog/sample.html", Title: "Leathanach samplach",
+		Date: date, Section: "blog", Meta: map[string]interface{}{}, Language: ga}
+	site := &Site{
+		Pages: []*Page{home, prev, page, next, translation},
+		data:  map[string]interface{}{"site": map[string]interface{}{"title": "Sample site"}},
+		menus: map[string][]MenuEntry{
+			"main":   {{Title: "Home", URL: "/index.html", Page: home}, {Title: "Blog", URL: "/blog/sample.html"}},
+			"footer": {{Title: "Feed", URL: "/feed.xml"}},
+		},
+	}
+	page.breadcrumbs = []Breadcrumb{{Title: "Home", URL: home.URL, Page: home}, {Title: "Blog"}, {Title: page.Title, URL: page.URL, Page: page, Current: true}}
+
+	full.TOC = template.HTML(renderTOC([]tocEntry{{Level: 2, ID: "intro", Text: "Introduction"}, {Level: 3, ID: "usage", Text: "Usage"}}, 2, 3))
+	full.Summary = "<p>Sample content.</p>"
+	full.WordCount = 2
+	full.ReadingTime = 1
+	full.Site = &SiteView{site: site, lang: en.Code, url: page.URL}
+	full.Page = page
+	full.Language = en
+	full.Translations = []*Page{translation}
+	full.I18n = map[string]string{"home": "Home", "read_more": "Read more"}
+	full.Breadcrumbs = page.breadcrumbs
+	full.BreadcrumbsJSONLD = breadcrumbsJSONLD(page.breadcrumbs, "https://example.com")
+	full.SEO = template.HTML(`<meta property="og:title" content="Sample page">`)
+	full.Prev, full.Next = prev, next
+	full.Related = []*Page{prev}
+	full.Backlinks = []*Page{next}
+	return bare, full
+}
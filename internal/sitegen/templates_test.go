@@ -0,0 +1,79 @@
+package sitegen
+
+import (
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestListTemplates(t *testing.T) {
+	names, err := ListTemplates()
+	if err != nil {
+		t.Fatalf("ListTemplates failed: %v", err)
+	}
+	for _, want := range append(slices.Clone(BuiltinThemes), NotFoundLayout) {
+		if !slices.Contains(names, want) {
+			t.Errorf("expected %q in %v", want, names)
+		}
+	}
+}
+
+func TestEjectTemplate(t *testing.T) {
+	dir := t.TempDir()
+	written, err := EjectTemplate("minimal", dir, false)
+	if err != nil {
+		t.Fatalf("EjectTemplate failed: %v", err)
+	}
+	if len(written) != 2 {
+		t.Fatalf("expected a layout and a stylesheet, got %v", written)
+	}
+	want, _ := fs.ReadFile(EmbeddedFiles, "templates/minimal.html")
+	if got := readTestFile(t, filepath.Join(dir, "templates", "minimal.html")); got != string(want) {
+		t.Errorf("ejected layout differs from the bundled one")
+	}
+	if !fileExists(filepath.Join(dir, "style.css")) {
+		t.Errorf("expected style.css to be ejected")
+	}
+
+	if _, err := EjectTemplate("minimal", dir, false); err == nil || !strings.Contains(err.Error(), "--force") {
+		t.Errorf("expected ejecting over existing files to fail, got %v", err)
+	}
+	if _, err := EjectTemplate("minimal", dir, true); err != nil {
+		t.Errorf("expected --force to overwrite, got %v", err)
+	}
+	if _, err := EjectTemplate("nope", dir, false); err == nil {
+		t.Errorf("expected an unknown template to fail")
+	}
+}
+
+func TestValidateTemplate(t *testing.T) {
+	names, _ := ListTemplates()
+	for _, name := range names {
+		if err := ValidateTemplate(name); err != nil {
+			t.Errorf("bundled template %q failed validation: %v", name, err)
+		}
+	}
+
+	dir := t.TempDir()
+	tests := map[string]string{
+		"parse.html":   `<h1>{{ .Title }</h1>`,
+		"field.html":   `<h1>{{ .Titel }}</h1>`,
+		"nil.html":     `<html lang="{{ .Language.Locale }}">{{ .Content }}</html>`,
+		"partial.html": `{{ template "missing" . }}`,
+	}
+	for name, src := range tests {
+		file := filepath.Join(dir, name)
+		writeTestFile(t, file, src)
+		if err := ValidateTemplate(file); err == nil {
+			t.Errorf("expected %s to fail validation", name)
+		}
+	}
+
+	good := filepath.Join(dir, "good.html")
+	writeTestFile(t, good, `<html{{ with .Language }} lang="{{ .Locale }}"{{ end }}>{{ range .Site.Pages }}{{ .Title }}{{ end }}{{ .Content }}</html>`)
+	if err := ValidateTemplate(good); err != nil {
+		t.Errorf("expected %s to pass validation, got %v", good, err)
+	}
+}
@@ -0,0 +1,188 @@
+// theme.go - Theme resolution with file-by-file site overrides
+package sitegen
+
+import (
+	"fmt"
+	"html/template"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// BuiltinThemes lists the themes bundled in EmbeddedFiles
+var BuiltinThemes = []string{"default", "minimal", "dark"}
+
+// Theme provides layouts, the stylesheet and static assets for a site.
+// Files are looked up in the site first, then the theme directory, then
+// the bundled templates, so a site can override its theme file by file.
+type Theme struct {
+	Name    string
+	Dir     string // empty for built-in themes
+	siteDir string
+}
+
+// ResolveTheme finds a theme by name or path. Names are looked up under
+// themes/ in the input directory before the built-in themes; anything else
+// is treated as a path to a theme directory.
+func ResolveTheme(name, inputDir string) (*Theme, error) {
+	if name == "" {
+		name = "default"
+	}
+	if dir := filepath.Join(inputDir, "themes", name); isDir(dir) {
+		return &Theme{Name: name, Dir: dir, siteDir: inputDir}, nil
+	}
+	if isBuiltinTheme(name) {
+		return &Theme{Name: name, siteDir: inputDir}, nil
+	}
+	if isDir(name) {
+		return &Theme{Name: filepath.Base(filepath.Clean(name)), Dir: name, siteDir: inputDir}, nil
+	}
+	return nil, fmt.Errorf("theme not found: %s", name)
+}
+
+func isBuiltinTheme(name string) bool {
+	for _, t := range BuiltinThemes {
+		if t == name {
+			return true
+		}
+	}
+	return false
+}
+
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// ReadFile returns a theme file such as "templates/default.html" or "style.css",
+// preferring the site's copy, then the theme directory, then the bundled file.
+func (t *Theme) ReadFile(name string) ([]byte, error) {
+	for _, dir := range []string{t.siteDir, t.Dir} {
+		if dir == "" {
+			continue
+		}
+		if data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name))); err == nil {
+			return data, nil
+		}
+	}
+	return fs.ReadFile(EmbeddedFiles, t.embeddedName(name))
+}
+
+// embeddedName maps a theme file to its bundled equivalent. A built-in theme
+// uses its own template as the default layout.
+func (t *Theme) embeddedName(name string) string {
+	switch {
+	case t.Dir == "" && name == "templates/default.html":
+		return "templates/" + t.Name + ".html"
+	case name == "style.css":
+		return "templates/style.css"
+	case strings.HasPrefix(name, "shortcodes/"):
+		return "templates/" + name
+	}
+	return name
+}
+
+// LayoutSource returns the source of the layout selected by templateOpt, which
+// may be a layout name (e.g. "minimal") or a path to a template file.
+func (t *Theme) LayoutSource(templateOpt string) ([]byte, error) {
+	if templateOpt == "" {
+		templateOpt = "default"
+	}
+	if fileExists(templateOpt) || filepath.IsAbs(templateOpt) {
+		return os.ReadFile(templateOpt)
+	}
+	if filepath.Ext(templateOpt) == ".html" {
+		return t.ReadFile(filepath.ToSlash(templateOpt))
+	}
+	return t.ReadFile("templates/" + templateOpt + ".html")
+}
+
+// Layout parses the layout selected by templateOpt
+func (t *Theme) Layout(templateOpt string) (*template.Template, error) {
+	src, err := t.LayoutSource(templateOpt)
+	if err != nil {
+		return nil, err
+	}
+	return template.New("layout").Parse(string(src))
+}
+
+// StaticFiles lists the files under the theme's static/ directory, relative to it
+func (t *Theme) StaticFiles() ([]string, error) {
+	if t.Dir == "" {
+		return nil, nil
+	}
+	staticDir := filepath.Join(t.Dir, "static")
+	if !isDir(staticDir) {
+		return nil, nil
+	}
+	var files []string
+	err := filepath.Walk(staticDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(staticDir, path)
+		if err != nil {
+			return err
+		}
+		if isHiddenFile(relPath) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !info.IsDir() {
+			files = append(files, relPath)
+		}
+		return nil
+	})
+	sort.Strings(files)
+	return files, err
+}
+
+// writeThemeAssets writes the stylesheet and the theme's static files to the
+// output directory, skipping anything the site provides itself. cssFile, if
+// set, replaces the theme stylesheet. Returns the output paths written.
+func writeThemeAssets(theme *Theme, cssFile, outputDir string, fileSet *FileSet) ([]string, error) {
+	siteFiles := make(map[string]bool, len(fileSet.AssetFiles))
+	for _, f := range fileSet.AssetFiles {
+		siteFiles[filepath.ToSlash(f)] = true
+	}
+
+	var written []string
+	if !siteFiles["style.css"] {
+		cssDst := filepath.Join(outputDir, "style.css")
+		if cssFile != "" {
+			if err := copyFilePreserveDirs(cssFile, cssDst); err != nil {
+				fmt.Printf("[Theme] Warning: could not copy CSS file %s: %v\n", cssFile, err)
+			} else {
+				written = append(written, "style.css")
+			}
+		} else if css, err := theme.ReadFile("style.css"); err == nil {
+			if err := os.WriteFile(cssDst, css, 0644); err != nil {
+				return nil, fmt.Errorf("failed to write style.css: %w", err)
+			}
+			written = append(written, "style.css")
+		}
+	}
+
+	static, err := theme.StaticFiles()
+	if err != nil {
+		return nil, fmt.Errorf("error reading theme static files: %w", err)
+	}
+	for _, relPath := range static {
+		if siteFiles[filepath.ToSlash(relPath)] {
+			continue
+		}
+		src := filepath.Join(theme.Dir, "static", relPath)
+		if err := copyFilePreserveDirs(src, filepath.Join(outputDir, relPath)); err != nil {
+			return nil, fmt.Errorf("failed to copy theme file '%s': %w", relPath, err)
+		}
+		written = append(written, relPath)
+	}
+	if len(written) > 0 {
+		fmt.Printf("[Theme] Wrote %d file(s) from theme '%s': %s\n", len(written), theme.Name, strings.Join(written, ", "))
+	}
+	return written, nil
+}
@@ -0,0 +1,111 @@
+package sitegen
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("failed to create dir for %s: %v", path, err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write %s: %v", path, err)
+	}
+}
+
+func readTestFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", path, err)
+	}
+	return string(data)
+}
+
+func TestBuild_BuiltinTheme(t *testing.T) {
+	inputDir := t.TempDir()
+	outputDir := t.TempDir()
+	writeTestFile(t, filepath.Join(inputDir, "index.md"), "# Home")
+
+	if err := Build(BuildOptions{InputDir: inputDir, OutputDir: outputDir, NoIncremental: true, Theme: "dark"}); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	html := readTestFile(t, filepath.Join(outputDir, "index.html"))
+	if !strings.Contains(html, "#101a2b") {
+		t.Errorf("expected dark theme layout, got: %s", html)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "style.css")); err != nil {
+		t.Errorf("expected style.css to be kept after a full build: %v", err)
+	}
+}
+
+func TestBuild_ThemeDirectoryWithOverrides(t *testing.T) {
+	inputDir := t.TempDir()
+	outputDir := t.TempDir()
+	themeDir := filepath.Join(inputDir, "themes", "house")
+	writeTestFile(t, filepath.Join(themeDir, "templates", "default.html"), "<html><body class=\"house\">{{ .Content }}</body></html>")
+	writeTestFile(t, filepath.Join(themeDir, "templates", "minimal.html"), "<html><main class=\"house-minimal\">{{ .Content }}</main></html>")
+	writeTestFile(t, filepath.Join(themeDir, "style.css"), "body { color: red; }")
+	writeTestFile(t, filepath.Join(themeDir, "static", "js", "site.js"), "console.log('theme')")
+	writeTestFile(t, filepath.Join(themeDir, "static", "favicon.ico"), "theme icon")
+
+	// The site overrides one layout and one static file from the theme
+	writeTestFile(t, filepath.Join(inputDir, "templates", "minimal.html"), "<html><main class=\"site-minimal\">{{ .Content }}</main></html>")
+	writeTestFile(t, filepath.Join(inputDir, "favicon.ico"), "site icon")
+	writeTestFile(t, filepath.Join(inputDir, ConfigFileName), "theme: house\n")
+	writeTestFile(t, filepath.Join(inputDir, "index.md"), "# Home")
+
+	if err := Build(BuildOptions{InputDir: inputDir, OutputDir: outputDir, NoIncremental: true}); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	if html := readTestFile(t, filepath.Join(outputDir, "index.html")); !strings.Contains(html, `class="house"`) {
+		t.Errorf("expected theme layout, got: %s", html)
+	}
+	if css := readTestFile(t, filepath.Join(outputDir, "style.css")); css != "body { color: red; }" {
+		t.Errorf("expected theme stylesheet, got: %s", css)
+	}
+	if js := readTestFile(t, filepath.Join(outputDir, "js", "site.js")); !strings.Contains(js, "theme") {
+		t.Errorf("expected theme static file, got: %s", js)
+	}
+	if icon := readTestFile(t, filepath.Join(outputDir, "favicon.ico")); icon != "site icon" {
+		t.Errorf("expected site file to override theme static file, got: %s", icon)
+	}
+	for _, name := range []string{"themes", "templates", ConfigFileName} {
+		if _, err := os.Stat(filepath.Join(outputDir, name)); err == nil {
+			t.Errorf("%s should not be copied to the output", name)
+		}
+	}
+
+	if err := Build(BuildOptions{InputDir: inputDir, OutputDir: outputDir, NoIncremental: true, Template: "minimal"}); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	if html := readTestFile(t, filepath.Join(outputDir, "index.html")); !strings.Contains(html, `class="site-minimal"`) {
+		t.Errorf("expected site layout to override theme layout, got: %s", html)
+	}
+}
+
+func TestBuild_ThemeChangeForcesRebuild(t *testing.T) {
+	inputDir := t.TempDir()
+	outputDir := t.TempDir()
+	writeTestFile(t, filepath.Join(inputDir, "index.md"), "# Home")
+
+	if err := Build(BuildOptions{InputDir: inputDir, OutputDir: outputDir}); err != nil {
+		t.Fatalf("first build failed: %v", err)
+	}
+	if err := Build(BuildOptions{InputDir: inputDir, OutputDir: outputDir, Theme: "dark"}); err != nil {
+		t.Fatalf("second build failed: %v", err)
+	}
+	if html := readTestFile(t, filepath.Join(outputDir, "index.html")); !strings.Contains(html, "#101a2b") {
+		t.Errorf("expected page to be rebuilt with the dark theme, got: %s", html)
+	}
+}
+
+func TestResolveTheme_Unknown(t *testing.T) {
+	if _, err := ResolveTheme("no-such-theme", t.TempDir()); err == nil {
+		t.Error("expected error for unknown theme")
+	}
+}
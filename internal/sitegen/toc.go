@@ -0,0 +1,110 @@
+// toc.go - Table of contents and heading anchors
+package sitegen
+
+import (
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+)
+
+// Default heading levels included in the table of contents
+const (
+	defaultTOCMinDepth = 2
+	defaultTOCMaxDepth = 3
+)
+
+// tocEntry is a heading listed in the table of contents
+type tocEntry struct {
+	Level int
+	ID    string
+	Text  string
+}
+
+// collectTOC lists the headings of a document that have an ID
+func collectTOC(root ast.Node, src []byte) []tocEntry {
+	var entries []tocEntry
+	ast.Walk(root, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		h, ok := n.(*ast.Heading)
+		if !ok || !entering {
+			return ast.WalkContinue, nil
+		}
+		if id, ok := h.AttributeString("id"); ok {
+			if idBytes, ok := id.([]byte); ok {
+				entries = append(entries, tocEntry{Level: h.Level, ID: string(idBytes), Text: nodeText(h, src)})
+			}
+		}
+		return ast.WalkSkipChildren, nil
+	})
+	return entries
+}
+
+// addHeadingAnchors appends a self-link to every heading with an ID
+func addHeadingAnchors(root ast.Node) {
+	ast.Walk(root, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		h, ok := n.(*ast.Heading)
+		if !ok || !entering {
+			return ast.WalkContinue, nil
+		}
+		if id, ok := h.AttributeString("id"); ok {
+			if idBytes, ok := id.([]byte); ok {
+				link := ast.NewLink()
+				link.Destination = append([]byte("#"), idBytes...)
+				link.SetAttributeString("class", []byte("anchor"))
+				link.AppendChild(link, ast.NewString([]byte("#")))
+				h.AppendChild(h, ast.NewString([]byte(" ")))
+				h.AppendChild(h, link)
+			}
+		}
+		return ast.WalkSkipChildren, nil
+	})
+}
+
+// renderTOC renders the entries between minDepth and maxDepth as a nested
+// list. Returns an empty string when there are fewer than two entries.
+func renderTOC(entries []tocEntry, minDepth, maxDepth int) string {
+	var included []tocEntry
+	for _, e := range entries {
+		if e.Level >= minDepth && e.Level <= maxDepth {
+			included = append(included, e)
+		}
+	}
+	if len(included) < 2 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString(`<nav class="toc">`)
+	// Track the levels of the open lists so skipped levels nest correctly
+	var open []int
+	for i, e := range included {
+		switch {
+		case len(open) == 0 || e.Level > open[len(open)-1]:
+			b.WriteString("<ul>")
+			open = append(open, e.Level)
+		default:
+			for len(open) > 1 && e.Level < open[len(open)-1] && e.Level <= open[len(open)-2] {
+				b.WriteString("</li></ul>")
+				open = open[:len(open)-1]
+			}
+			if i > 0 {
+				b.WriteString("</li>")
+			}
+		}
+		fmt.Fprintf(&b, `<li><a href="#%s">%s</a>`, html.EscapeString(e.ID), html.EscapeString(e.Text))
+	}
+	for range open {
+		b.WriteString("</li></ul>")
+	}
+	b.WriteString("</nav>")
+	return b.String()
+}
+
+// tocEnabled reports whether frontmatter allows a table of contents
+func tocEnabled(meta map[string]interface{}) bool {
+	if v, ok := meta["toc"].(bool); ok {
+		return v
+	}
+	return true
+}
@@ -0,0 +1,65 @@
+package sitegen
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const tocPage = `# Guide
+
+## Install
+
+### From source
+
+## Usage
+
+#### Deep detail
+`
+
+func TestBuild_HeadingIDsAndTOC(t *testing.T) {
+	inputDir := t.TempDir()
+	outputDir := t.TempDir()
+	writeTestFile(t, filepath.Join(inputDir, "guide.md"), tocPage)
+
+	if err := Build(BuildOptions{InputDir: inputDir, OutputDir: outputDir}); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	html := readTestFile(t, filepath.Join(outputDir, "guide.html"))
+
+	if !strings.Contains(html, `<h2 id="install">Install</h2>`) {
+		t.Errorf("expected heading ID, got:\n%s", html)
+	}
+	wantTOC := `<nav class="toc"><ul><li><a href="#install">Install</a><ul><li><a href="#from-source">From source</a></li></ul></li><li><a href="#usage">Usage</a></li></ul></nav>`
+	if !strings.Contains(html, wantTOC) {
+		t.Errorf("expected table of contents %s, got:\n%s", wantTOC, html)
+	}
+	if strings.Contains(html, `class="anchor"`) {
+		t.Errorf("anchors should be off by default")
+	}
+}
+
+func TestBuild_TOCConfigAndFrontmatter(t *testing.T) {
+	inputDir := t.TempDir()
+	outputDir := t.TempDir()
+	writeTestFile(t, filepath.Join(inputDir, ConfigFileName), "heading_anchors: true\ntoc:\n  min_depth: 2\n  max_depth: 4\n")
+	writeTestFile(t, filepath.Join(inputDir, "guide.md"), tocPage)
+	writeTestFile(t, filepath.Join(inputDir, "short.md"), "---\ntoc: false\n---\n"+tocPage)
+
+	if err := Build(BuildOptions{InputDir: inputDir, OutputDir: outputDir}); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	html := readTestFile(t, filepath.Join(outputDir, "guide.html"))
+	if !strings.Contains(html, `<h2 id="install">Install <a href="#install" class="anchor">#</a></h2>`) {
+		t.Errorf("expected self-link anchor, got:\n%s", html)
+	}
+	if !strings.Contains(html, `<a href="#deep-detail">Deep detail</a>`) {
+		t.Errorf("expected h4 in table of contents with max_depth 4, got:\n%s", html)
+	}
+	if strings.Contains(html, `<a href="#install">Install #</a>`) {
+		t.Errorf("anchor text should not leak into the table of contents")
+	}
+	if short := readTestFile(t, filepath.Join(outputDir, "short.html")); strings.Contains(short, `class="toc"`) {
+		t.Errorf("toc: false should suppress the table of contents, got:\n%s", short)
+	}
+}
@@ -0,0 +1,186 @@
+// urls.go - Base path and relative URLs for sites deployed below the domain root
+package sitegen
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// urlAttrPattern matches the URL attributes of rendered HTML
+var urlAttrPattern = regexp.MustCompile(`\b(href|src|poster|srcset)="([^"]*)"`)
+
+// rewriteURLs applies rewrite to every URL attribute of a rendered page,
+// including each candidate of a srcset
+func rewriteURLs(page []byte, rewrite func(string) string) []byte {
+	return urlAttrPattern.ReplaceAllFunc(page, func(m []byte) []byte {
+		parts := urlAttrPattern.FindSubmatch(m)
+		attr, value := string(parts[1]), string(parts[2])
+		if attr == "srcset" {
+			candidates := strings.Split(value, ",")
+			for i, c := range candidates {
+				fields := strings.Fields(c)
+				if len(fields) > 0 {
+					fields[0] = rewrite(fields[0])
+					candidates[i] = strings.Join(fields, " ")
+				}
+			}
+			value = strings.Join(candidates, ", ")
+		} else {
+			value = rewrite(value)
+		}
+		return []byte(attr + `="` + value + `"`)
+	})
+}
+
+// isRootRelative reports whether u is a URL such as "/blog/post.html" that
+// points into the site, as opposed to a relative, fragment or external URL
+func isRootRelative(u string) bool {
+	return strings.HasPrefix(u, "/") && !strings.HasPrefix(u, "//")
+}
+
+// isRelativeURL reports whether u is relative to the page it is on
+func isRelativeURL(u string) bool {
+	return u != "" && !strings.HasPrefix(u, "/") && !strings.HasPrefix(u, "#") && !strings.Contains(u, ":")
+}
+
+// splitURL separates the path of a URL from its query and fragment
+func splitURL(u string) (string, string) {
+	if i := strings.IndexAny(u, "?#"); i >= 0 {
+		return u[:i], u[i:]
+	}
+	return u, ""
+}
+
+// withBasePath prefixes the root-relative URLs of a page with the base path
+func withBasePath(page []byte, basePath string) []byte {
+	if basePath == "" {
+		return page
+	}
+	return rewriteURLs(page, func(u string) string {
+		if !isRootRelative(u) {
+			return u
+		}
+		return basePath + u
+	})
+}
+
+// relativeURLs rewrites the root-relative URLs of the page at outPath as
+// relative ones, so the site works from any directory or file:// URL.
+// Directory links get an explicit index.html, which file:// does not add.
+func relativeURLs(page []byte, outPath string) []byte {
+	dir := path.Dir(filepath.ToSlash(outPath))
+	return rewriteURLs(page, func(u string) string {
+		if !isRootRelative(u) {
+			return u
+		}
+		target, suffix := splitURL(u)
+		if strings.HasSuffix(target, "/") {
+			target += "index.html"
+		}
+		rel, err := filepath.Rel(filepath.FromSlash("/"+dir), filepath.FromSlash(target))
+		if err != nil {
+			return u
+		}
+		return filepath.ToSlash(rel) + suffix
+	})
+}
+
+// pageOutputPath returns the output path of a markdown file, see
+// Languages.OutputPath, moved by prettyOutputPath with pretty URLs
+func pageOutputPath(languages *Languages, relPath string, pretty bool) string {
+	out := languages.OutputPath(relPath)
+	if pretty {
+		out = prettyOutputPath(out)
+	}
+	return out
+}
+
+// prettyOutputPath returns where a page is written with pretty URLs: foo.html
+// moves to foo/index.html so that it is served as foo/. Index and 404 pages
+// stay where they are.
+func prettyOutputPath(out string) string {
+	switch filepath.Base(out) {
+	case "index.html", "404.html":
+		return out
+	}
+	return filepath.Join(strings.TrimSuffix(out, ".html"), "index.html")
+}
+
+// prettyLink drops index.html from a link to a page, "index.html" becoming "./"
+func prettyLink(link string) string {
+	if link == "index.html" {
+		return "./"
+	}
+	if strings.HasSuffix(link, "/index.html") {
+		return strings.TrimSuffix(link, "index.html")
+	}
+	return link
+}
+
+// prettyRelativeURLs rewrites the relative URLs of a page that pretty URLs
+// moved one directory down, which were written for its place without them
+func prettyRelativeURLs(page []byte) []byte {
+	return rewriteURLs(page, func(u string) string {
+		if target, _ := splitURL(u); !isRelativeURL(u) || target == "" {
+			return u
+		}
+		return "../" + strings.TrimPrefix(u, "./")
+	})
+}
+
+// normalizeBasePath returns a base path as "/blog", or "" for the domain root
+func normalizeBasePath(p string) string {
+	p = strings.Trim(filepath.ToSlash(p), "/")
+	if p == "" {
+		return ""
+	}
+	return "/" + p
+}
+
+// resolveURLs settles the base URL and base path of a site. The base path
+// comes from base_path (or --base-path), which replaces the path of
+// base_url, else from the path of base_url. Without base_url, the base URL is
+// the RSS URL below the base path.
+func (c *Config) resolveURLs(rssURL string) error {
+	explicit := c.BasePath != ""
+	var base *url.URL
+	if c.BaseURL != "" {
+		u, err := url.Parse(c.BaseURL)
+		if err != nil {
+			return fmt.Errorf("invalid base_url %q: %w", c.BaseURL, err)
+		}
+		base = u
+		if !explicit {
+			c.BasePath = u.Path
+		}
+	}
+	c.BasePath = normalizeBasePath(c.BasePath)
+	switch {
+	case base != nil:
+		base.Path = c.BasePath
+		c.BaseURL = strings.TrimSuffix(base.String(), "/")
+	case rssURL != "":
+		u, err := siteURL(rssURL, c.BasePath)
+		if err != nil {
+			return fmt.Errorf("invalid RSS URL %q: %w", rssURL, err)
+		}
+		c.BaseURL = u
+	}
+	return nil
+}
+
+// siteURL adds the base path to an absolute URL that points at the domain root
+func siteURL(rawURL, basePath string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	if strings.Trim(u.Path, "/") == "" {
+		u.Path = basePath
+	}
+	return strings.TrimSuffix(u.String(), "/"), nil
+}
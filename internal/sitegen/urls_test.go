@@ -0,0 +1,133 @@
+package sitegen
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRewriteURLs(t *testing.T) {
+	page := `<link href="/style.css"><img src="/a.png" srcset="/a.png 1x, /a@2x.png 2x"><a href="https://x.org/">x</a><a href="#top">t</a><a href="rel.html">r</a>`
+	if got, want := string(withBasePath([]byte(page), "/blog")),
+		`<link href="/blog/style.css"><img src="/blog/a.png" srcset="/blog/a.png 1x, /blog/a@2x.png 2x"><a href="https://x.org/">x</a><a href="#top">t</a><a href="rel.html">r</a>`; got != want {
+		t.Errorf("withBasePath = %q, want %q", got, want)
+	}
+
+	page = `<link href="/style.css"><a href="/">home</a><a href="/blog/">blog</a><a href="/blog/other.html#part">o</a><a href="//cdn.example.com/x.js">cdn</a>`
+	if got, want := string(relativeURLs([]byte(page), filepath.Join("blog", "post.html"))),
+		`<link href="../style.css"><a href="../index.html">home</a><a href="index.html">blog</a><a href="other.html#part">o</a><a href="//cdn.example.com/x.js">cdn</a>`; got != want {
+		t.Errorf("relativeURLs = %q, want %q", got, want)
+	}
+}
+
+func TestConfig_ResolveURLs(t *testing.T) {
+	tests := []struct {
+		cfg      Config
+		rssURL   string
+		baseURL  string
+		basePath string
+	}{
+		{Config{}, "", "", ""},
+		{Config{}, "https://example.com", "https://example.com", ""},
+		{Config{BaseURL: "https://example.com/blog/"}, "", "https://example.com/blog", "/blog"},
+		{Config{BasePath: "docs/"}, "https://example.com", "https://example.com/docs", "/docs"},
+		{Config{BaseURL: "https://example.com", BasePath: "/docs"}, "", "https://example.com/docs", "/docs"},
+		{Config{BaseURL: "https://example.com/blog", BasePath: "/"}, "", "https://example.com", ""},
+		{Config{}, "https://example.com/feeds/", "https://example.com/feeds", ""},
+	}
+	for _, tt := range tests {
+		cfg := tt.cfg
+		if err := cfg.resolveURLs(tt.rssURL); err != nil {
+			t.Fatalf("resolveURLs(%+v) failed: %v", tt.cfg, err)
+		}
+		if cfg.BaseURL != tt.baseURL || cfg.BasePath != tt.basePath {
+			t.Errorf("resolveURLs(%+v, %q) = %q, %q, want %q, %q", tt.cfg, tt.rssURL, cfg.BaseURL, cfg.BasePath, tt.baseURL, tt.basePath)
+		}
+	}
+}
+
+func TestBuild_BasePath(t *testing.T) {
+	inputDir := t.TempDir()
+	outputDir := t.TempDir()
+	writeTestFile(t, filepath.Join(inputDir, "colade.yaml"), "base_url: https://example.com/blog/\nmenus:\n  main:\n    - title: About\n      url: /about.html\n")
+	writeTestFile(t, filepath.Join(inputDir, "about.md"), "# About\n\nSee the [guide](/docs/guide.md) and [home](index.md).")
+	writeTestFile(t, filepath.Join(inputDir, "index.md"), "# Home")
+	writeTestFile(t, filepath.Join(inputDir, "docs", "guide.md"), "# Guide")
+
+	if err := Build(BuildOptions{InputDir: inputDir, OutputDir: outputDir, RSSURL: "https://example.com"}); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	out := readTestFile(t, filepath.Join(outputDir, "about.html"))
+	for _, want := range []string{
+		`<link rel="stylesheet" href="/blog/style.css">`,
+		`<a href="/blog/about.html" class="active" aria-current="page">About</a>`,
+		`<a href="/blog/docs/guide.html">guide</a>`,
+		`<a href="index.html">home</a>`,
+		`<link rel="canonical" href="https://example.com/blog/about.html">`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected about.html to contain %q, got:\n%s", want, out)
+		}
+	}
+	if feed := readTestFile(t, filepath.Join(outputDir, "feed.xml")); !strings.Contains(feed, "<link>https://example.com/blog/docs/guide.html</link>") {
+		t.Errorf("expected feed links below the base path, got:\n%s", feed)
+	}
+
+	// --base-path overrides the path of base_url
+	if err := Build(BuildOptions{InputDir: inputDir, OutputDir: outputDir, BasePath: "/docs-site"}); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	if out := readTestFile(t, filepath.Join(outputDir, "about.html")); !strings.Contains(out, `href="/docs-site/style.css"`) {
+		t.Errorf("expected --base-path to be used, got:\n%s", out)
+	}
+}
+
+func TestBuild_RelativeURLs(t *testing.T) {
+	inputDir := t.TempDir()
+	outputDir := t.TempDir()
+	writeTestFile(t, filepath.Join(inputDir, "colade.yaml"), "menus:\n  main:\n    - title: Home\n      url: /\n")
+	writeTestFile(t, filepath.Join(inputDir, "index.md"), "# Home")
+	writeTestFile(t, filepath.Join(inputDir, "404.md"), "# Lost")
+	writeTestFile(t, filepath.Join(inputDir, "docs", "guide", "intro.md"), "# Intro\n\n[Top](/index.md)")
+
+	if err := Build(BuildOptions{InputDir: inputDir, OutputDir: outputDir, RelativeURLs: true}); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	out := readTestFile(t, filepath.Join(outputDir, "docs", "guide", "intro.html"))
+	for _, want := range []string{
+		`<link rel="stylesheet" href="../../style.css">`,
+		`<li><a href="../../index.html">Home</a></li>`,
+		`<a href="../../index.html">Top</a>`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected intro.html to contain %q, got:\n%s", want, out)
+		}
+	}
+	// The 404 page is served at any depth, so it keeps root-relative links
+	if out := readTestFile(t, filepath.Join(outputDir, "404.html")); !strings.Contains(out, `href="/style.css"`) {
+		t.Errorf("expected root-relative links on the 404 page, got:\n%s", out)
+	}
+}
+
+func TestCustomFileServer_BasePath(t *testing.T) {
+	root := t.TempDir()
+	writeTestFile(t, filepath.Join(root, "index.html"), "home")
+	writeTestFile(t, filepath.Join(root, "style.css"), "body{}")
+	cfs := &customFileServer{root: http.Dir(root), dir: root, basePath: "/blog"}
+
+	rec := httptest.NewRecorder()
+	cfs.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusFound || rec.Header().Get("Location") != "/blog/" {
+		t.Errorf("expected a redirect to the base path, got %d %q", rec.Code, rec.Header().Get("Location"))
+	}
+	tests := map[string]int{"/blog/": http.StatusOK, "/blog/style.css": http.StatusOK, "/style.css": http.StatusNotFound, "/blog/missing.css": http.StatusNotFound}
+	for path, want := range tests {
+		rec := httptest.NewRecorder()
+		cfs.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != want {
+			t.Errorf("%s: status = %d, want %d", path, rec.Code, want)
+		}
+	}
+}
@@ -0,0 +1,268 @@
+// wikilink.go - [[Page Name]] links resolved against page titles and file names, and backlinks
+package sitegen
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// wikiLinkKey holds the function resolving the wiki links of the page being
+// parsed, see MarkdownProcessor.wikiLinkURL
+var wikiLinkKey = parser.NewContextKey()
+
+// KindWikiLink is the node kind of wiki links
+var KindWikiLink = ast.NewNodeKind("WikiLink")
+
+// WikiLink is a [[target]] or [[target|label]] link. Its children are the label.
+type WikiLink struct {
+	ast.BaseInline
+	Target      string // page name, optionally followed by #heading
+	Destination string // URL of the target, "" while unresolved
+}
+
+func (n *WikiLink) Kind() ast.NodeKind { return KindWikiLink }
+
+func (n *WikiLink) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"Target": n.Target, "Destination": n.Destination}, nil)
+}
+
+// wikiLinks is a goldmark extension for wiki links
+type wikiLinks struct{}
+
+func (e *wikiLinks) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(
+		// Ahead of goldmark's link parser at 200
+		parser.WithInlineParsers(util.Prioritized(&wikiLinkParser{}, 199)),
+		parser.WithASTTransformers(util.Prioritized(&wikiLinkTransformer{}, 100)),
+	)
+	m.Renderer().AddOptions(renderer.WithNodeRenderers(
+		util.Prioritized(&wikiLinkRenderer{}, 500),
+	))
+}
+
+type wikiLinkParser struct{}
+
+func (p *wikiLinkParser) Trigger() []byte {
+	return []byte{'['}
+}
+
+func (p *wikiLinkParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	line, seg := block.PeekLine()
+	if !bytes.HasPrefix(line, []byte("[[")) {
+		return nil
+	}
+	end := bytes.Index(line, []byte("]]"))
+	if end < 0 {
+		return nil
+	}
+	// Leave [[1]](url), [[x]][ref] and [[x]]: /url lines to the link parser
+	if next := line[end+2:]; len(next) > 0 {
+		if next[0] == '(' || next[0] == '[' || (next[0] == ':' && block.PrecendingCharacter() == '\n') {
+			return nil
+		}
+	}
+	inner := line[2:end]
+	if bytes.ContainsAny(inner, "[]\n") {
+		return nil
+	}
+	target, label := inner, inner
+	labelStart := 2
+	if i := bytes.IndexByte(inner, '|'); i >= 0 {
+		target, label = inner[:i], inner[i+1:]
+		labelStart += i + 1
+	}
+	if len(bytes.TrimSpace(target)) == 0 || len(bytes.TrimSpace(label)) == 0 {
+		return nil
+	}
+	block.Advance(end + 2)
+	node := &WikiLink{Target: strings.TrimSpace(string(target))}
+	node.AppendChild(node, ast.NewTextSegment(text.NewSegment(seg.Start+labelStart, seg.Start+labelStart+len(label))))
+	return node
+}
+
+type wikiLinkTransformer struct{}
+
+func (t *wikiLinkTransformer) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	resolve, _ := pc.Get(wikiLinkKey).(func(string) string)
+	if resolve == nil {
+		return
+	}
+	ast.Walk(doc, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+		if n, ok := node.(*WikiLink); ok && entering {
+			n.Destination = resolve(n.Target)
+		}
+		return ast.WalkContinue, nil
+	})
+}
+
+// wikiLinkRenderer writes resolved wiki links as links and unresolved ones as
+// marked-up text
+type wikiLinkRenderer struct{}
+
+func (r *wikiLinkRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(KindWikiLink, r.renderWikiLink)
+}
+
+func (r *wikiLinkRenderer) renderWikiLink(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	n := node.(*WikiLink)
+	switch {
+	case n.Destination == "" && entering:
+		_, _ = w.WriteString(`<span class="wikilink wikilink-missing">`)
+	case n.Destination == "":
+		_, _ = w.WriteString("</span>")
+	case entering:
+		_, _ = w.WriteString(`<a class="wikilink" href="`)
+		_, _ = w.Write(util.EscapeHTML(util.URLEscape([]byte(n.Destination), true)))
+		_, _ = w.WriteString(`">`)
+	default:
+		_, _ = w.WriteString("</a>")
+	}
+	return ast.WalkContinue, nil
+}
+
+// wikiKey normalizes a page name so that "Install Guide", "install-guide"
+// and "install_guide.md" match
+func wikiKey(name string) string {
+	name = strings.TrimSuffix(filepath.ToSlash(strings.TrimSpace(name)), ".md")
+	name = strings.Map(func(r rune) rune {
+		if r == '-' || r == '_' {
+			return ' '
+		}
+		return r
+	}, strings.ToLower(name))
+	return strings.Join(strings.Fields(name), " ")
+}
+
+// splitWikiTarget separates the page name of a wiki link from its heading
+func splitWikiTarget(target string) (name, heading string) {
+	name, heading, _ = strings.Cut(target, "#")
+	return strings.TrimSpace(name), strings.TrimSpace(heading)
+}
+
+// headingID returns the id goldmark gives a heading with the given text
+func headingID(heading string) string {
+	var b strings.Builder
+	for i := 0; i < len(heading); i++ {
+		c := heading[i]
+		switch {
+		case c >= 'A' && c <= 'Z':
+			b.WriteByte(c + 'a' - 'A')
+		case c >= 'a' && c <= 'z' || c >= '0' && c <= '9':
+			b.WriteByte(c)
+		case c == ' ' || c == '\t' || c == '-' || c == '_':
+			b.WriteByte('-')
+		}
+	}
+	return b.String()
+}
+
+// indexWikiNames indexes every page by title, file name and path without
+// extension, each normalized by wikiKey
+func (s *Site) indexWikiNames() {
+	s.wikiNames = make(map[string][]*Page)
+	for _, p := range s.Pages {
+		key := filepath.ToSlash(p.key)
+		stem := strings.TrimSuffix(key, path.Ext(key))
+		seen := make(map[string]bool)
+		for _, name := range []string{p.Title, path.Base(stem), stem} {
+			if k := wikiKey(name); k != "" && !seen[k] {
+				seen[k] = true
+				s.wikiNames[k] = append(s.wikiNames[k], p)
+			}
+		}
+	}
+}
+
+// resolveWikiLink returns the page a wiki link on page from points to,
+// preferring pages in the same language, or nil
+func (s *Site) resolveWikiLink(from *Page, name string) *Page {
+	candidates := s.wikiNames[wikiKey(name)]
+	for _, p := range candidates {
+		if from == nil || p.langCode() == from.langCode() {
+			return p
+		}
+	}
+	if len(candidates) > 0 {
+		return candidates[0]
+	}
+	return nil
+}
+
+// wikiLinkURL returns the URL of a wiki link on the page at relPath, or "" if
+// its target does not exist. [[#Heading]] links within the page.
+func (mp *MarkdownProcessor) wikiLinkURL(relPath, target string) string {
+	name, heading := splitWikiTarget(target)
+	fragment := ""
+	if heading != "" {
+		fragment = "#" + headingID(heading)
+	}
+	if name == "" {
+		return fragment
+	}
+	page := mp.site.resolveWikiLink(mp.site.Page(relPath), name)
+	if page == nil {
+		return ""
+	}
+	return mp.pageURL(relPath, page.RelPath) + fragment
+}
+
+// wikiTargets returns the targets of the wiki links in a document
+func wikiTargets(root ast.Node) []string {
+	var targets []string
+	ast.Walk(root, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if link, ok := n.(*WikiLink); ok && entering {
+			targets = append(targets, link.Target)
+		}
+		return ast.WalkContinue, nil
+	})
+	return targets
+}
+
+// warnUnresolvedWikiLinks reports the wiki links of a rendered page whose
+// targets do not exist
+func warnUnresolvedWikiLinks(root ast.Node, relPath string) {
+	ast.Walk(root, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if link, ok := n.(*WikiLink); ok && entering && link.Destination == "" {
+			fmt.Printf("[Wiki] Warning: %s: no page found for [[%s]]\n", relPath, link.Target)
+		}
+		return ast.WalkContinue, nil
+	})
+}
+
+// linkBacklinks lists on every page the pages whose wiki links point to it,
+// sorted by title
+func (s *Site) linkBacklinks() {
+	for _, p := range s.Pages {
+		seen := make(map[*Page]bool)
+		for _, target := range p.wikiLinks {
+			name, _ := splitWikiTarget(target)
+			if name == "" {
+				continue
+			}
+			if to := s.resolveWikiLink(p, name); to != nil && to != p && !seen[to] {
+				seen[to] = true
+				to.backlinks = append(to.backlinks, p)
+			}
+		}
+	}
+	for _, p := range s.Pages {
+		sort.SliceStable(p.backlinks, func(i, j int) bool {
+			a, b := p.backlinks[i], p.backlinks[j]
+			if a.Title != b.Title {
+				return a.Title < b.Title
+			}
+			return a.RelPath < b.RelPath
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package sitegen
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWikiKey(t *testing.T) {
+	for name, want := range map[string]string{
+		"Install Guide":         "install guide",
+		"install-guide":         "install guide",
+		" install_guide.md ":    "install guide",
+		"docs/Getting--Started": "docs/getting started",
+	} {
+		if got := wikiKey(name); got != want {
+			t.Errorf("wikiKey(%q) = %q, want %q", name, got, want)
+		}
+	}
+	if got := headingID("Set up: the CLI_tool"); got != "set-up-the-cli-tool" {
+		t.Errorf("headingID() = %q", got)
+	}
+}
+
+func TestWikiLinks_Unresolved(t *testing.T) {
+	out, _, _ := NewMarkdownProcessor("").renderMarkdown([]byte("See [[Some Page|the page]] and `[[code]]` and [[ ]] and [[a]b]]."))
+	want := `<p>See <span class="wikilink wikilink-missing">the page</span> and <code>[[code]]</code> and [[ ]] and [[a]b]].</p>` + "\n"
+	if string(out) != want {
+		t.Errorf("renderMarkdown() = %q, want %q", out, want)
+	}
+}
+
+func TestBuild_WikiLinksAndBacklinks(t *testing.T) {
+	inputDir := t.TempDir()
+	outputDir := t.TempDir()
+	writeTestFile(t, filepath.Join(inputDir, "index.md"), "# Home\n\nRead [[Install Guide]], [[docs/faq|the FAQ]], [[install-guide#Set Up|setup]] and [[Missing Page]].\n")
+	writeTestFile(t, filepath.Join(inputDir, "docs", "install.md"), "---\ntitle: Install Guide\n---\n## Set up\n\nBack [[home]] or jump to [[#Set up]].\n")
+	writeTestFile(t, filepath.Join(inputDir, "docs", "faq.md"), "# FAQ\n\nSee [[Install Guide]].\n")
+
+	if err := Build(BuildOptions{InputDir: inputDir, OutputDir: outputDir}); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	index := readTestFile(t, filepath.Join(outputDir, "index.html"))
+	for _, want := range []string{
+		`<a class="wikilink" href="docs/install.html">Install Guide</a>`,
+		`<a class="wikilink" href="docs/faq.html">the FAQ</a>`,
+		`<a class="wikilink" href="docs/install.html#set-up">setup</a>`,
+		`<span class="wikilink wikilink-missing">Missing Page</span>`,
+	} {
+		if !strings.Contains(index, want) {
+			t.Errorf("expected index.html to contain %q, got:\n%s", want, index)
+		}
+	}
+	install := readTestFile(t, filepath.Join(outputDir, "docs", "install.html"))
+	for _, want := range []string{
+		`<a class="wikilink" href="../index.html">home</a>`,
+		`<a class="wikilink" href="#set-up">#Set up</a>`,
+		`<h2>Linked from</h2>`,
+		`<li><a href="/docs/faq.html">FAQ</a></li><li><a href="/index.html">Home</a></li>`,
+	} {
+		if !strings.Contains(install, want) {
+			t.Errorf("expected install.html to contain %q, got:\n%s", want, install)
+		}
+	}
+	if faq := readTestFile(t, filepath.Join(outputDir, "docs", "faq.html")); !strings.Contains(faq, `<li><a href="/index.html">Home</a></li>`) {
+		t.Errorf("expected a backlink from the home page, got:\n%s", faq)
+	}
+
+	// Adding the missing page rebuilds the page linking to it in an incremental build
+	writeTestFile(t, filepath.Join(inputDir, "missing-page.md"), "# Missing\n")
+	later := time.Now().Add(2 * time.Second)
+	os.Chtimes(filepath.Join(inputDir, "missing-page.md"), later, later)
+	if err := Build(BuildOptions{InputDir: inputDir, OutputDir: outputDir}); err != nil {
+		t.Fatalf("incremental Build failed: %v", err)
+	}
+	if index := readTestFile(t, filepath.Join(outputDir, "index.html")); !strings.Contains(index, `<a class="wikilink" href="missing-page.html">Missing Page</a>`) {
+		t.Errorf("expected the wiki link to resolve once the page exists, got:\n%s", index)
+	}
+}
+
+func TestWikiLinks_LinkSyntax(t *testing.T) {
+	mp := NewMarkdownProcessor("")
+	tests := []struct {
+		src, want string
+	}{
+		{"[[1]](https://example.com)", `<p><a href="https://example.com">[1]</a></p>`},
+		{"[[x]][ref]\n\n[ref]: /url", `<p><a href="/url">[x]</a></p>`},
+		{"[[x]]: /url", `<p>[[x]]: /url</p>`},
+		{"See [[x]]: missing.", `<p>See <span class="wikilink wikilink-missing">x</span>: missing.</p>`},
+	}
+	for _, test := range tests {
+		out, _, _ := mp.renderMarkdown([]byte(test.src))
+		if got := strings.TrimSpace(string(out)); got != test.want {
+			t.Errorf("renderMarkdown(%q) = %q, want %q", test.src, got, test.want)
+		}
+	}
+}
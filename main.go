@@ -2,6 +2,7 @@
 package main
 
 import (
+	"bytes"
 	"fmt"
 	"os"
 
@@ -33,23 +34,29 @@ func main() {
 		Short: "Build a static site from Markdown files",
 		Args:  cobra.ExactArgs(2),
 		Run: func(cmd *cobra.Command, args []string) {
-			inputDir := args[0]
-			outputDir := args[1]
 			threshold, _ := cmd.Flags().GetInt("size-threshold")
-			noIncremental, _ := cmd.Flags().GetBool("no-incremental")
-			rssURL, _ := cmd.Flags().GetString("rss")
-			rssMaxItems, _ := cmd.Flags().GetInt("rss-max-items")
-			keepOrphaned, _ := cmd.Flags().GetBool("keep-orphaned")
-			templateOpt, _ := cmd.Flags().GetString("template")
-			headerFile, _ := cmd.Flags().GetString("header-file")
-			footerFile, _ := cmd.Flags().GetString("footer-file")
-			noHeader, _ := cmd.Flags().GetBool("no-header")
-			noFooter, _ := cmd.Flags().GetBool("no-footer")
-			cssFile, _ := cmd.Flags().GetString("css")
-			if err := sitegen.BuildSite(
-				inputDir, outputDir, threshold*1024, noIncremental, rssURL, rssMaxItems, keepOrphaned, templateOpt,
-				headerFile, footerFile, noHeader, noFooter, cssFile,
-			); err != nil {
+			opts := sitegen.BuildOptions{
+				InputDir:      args[0],
+				OutputDir:     args[1],
+				SizeThreshold: threshold * 1024,
+			}
+			opts.NoIncremental, _ = cmd.Flags().GetBool("no-incremental")
+			opts.RSSURL, _ = cmd.Flags().GetString("rss")
+			opts.RSSMaxItems, _ = cmd.Flags().GetInt("rss-max-items")
+			opts.KeepOrphaned, _ = cmd.Flags().GetBool("keep-orphaned")
+			opts.Template, _ = cmd.Flags().GetString("template")
+			opts.HeaderFile, _ = cmd.Flags().GetString("header-file")
+			opts.FooterFile, _ = cmd.Flags().GetString("footer-file")
+			opts.NoHeader, _ = cmd.Flags().GetBool("no-header")
+			opts.NoFooter, _ = cmd.Flags().GetBool("no-footer")
+			opts.CSSFile, _ = cmd.Flags().GetString("css")
+			opts.Theme, _ = cmd.Flags().GetString("theme")
+			opts.BasePath, _ = cmd.Flags().GetString("base-path")
+			opts.RelativeURLs, _ = cmd.Flags().GetBool("relative-urls")
+			opts.PrettyURLs, _ = cmd.Flags().GetBool("pretty-urls")
+			opts.Check, _ = cmd.Flags().GetBool("check")
+			opts.SafeHTML, _ = cmd.Flags().GetBool("safe-html")
+			if err := sitegen.Build(opts); err != nil {
 				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 				os.Exit(1)
 			}
@@ -66,6 +73,13 @@ func main() {
 	buildCmd.Flags().Bool("no-header", false, "Disable header injection")
 	buildCmd.Flags().Bool("no-footer", false, "Disable footer injection")
 	buildCmd.Flags().String("css", "", "Path to custom CSS file to use instead of the default style.css")
+	buildCmd.Flags().String("theme", "", "Theme to use: a built-in theme (default, minimal, dark), a theme under themes/ in inputDir, or a path to a theme directory")
+
+	buildCmd.Flags().String("base-path", "", "Path the site is served from, e.g. /blog (default: the path of base_url in colade.yaml)")
+	buildCmd.Flags().Bool("relative-urls", false, "Make internal links relative so the site works from any directory or file://")
+	buildCmd.Flags().Bool("pretty-urls", false, "Write pages as foo/index.html and link to them as foo/")
+	buildCmd.Flags().Bool("check", false, "Check the links and assets of the built site and fail if any are broken")
+	buildCmd.Flags().Bool("safe-html", false, "Remove raw HTML and script URLs from markdown, or sanitize it against safe_html.allow in colade.yaml")
 
 	rootCmd.AddCommand(buildCmd)
 
@@ -81,7 +95,8 @@ func main() {
 				os.Exit(1)
 			}
 			port, _ := cmd.Flags().GetInt("port")
-			err = sitegen.ServeDir(dir, port)
+			basePath, _ := cmd.Flags().GetString("base-path")
+			err = sitegen.ServeDirAt(dir, port, basePath)
 			if err != nil {
 				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 				os.Exit(1)
@@ -89,8 +104,117 @@ func main() {
 		},
 	}
 	serveCmd.Flags().IntP("port", "p", 8080, "Port to serve on (default 8080)")
+	serveCmd.Flags().String("base-path", "", "Serve the site below this path, e.g. /blog, for sites built with --base-path")
 	rootCmd.AddCommand(serveCmd)
 
+	checkCmd := &cobra.Command{
+		Use:   "check [outputDir]",
+		Short: "Check the links and assets of a built site",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			basePath, _ := cmd.Flags().GetString("base-path")
+			problems, err := sitegen.CheckLinks(args[0], basePath)
+			if err == nil {
+				err = sitegen.ReportLinks(problems)
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+		},
+	}
+	checkCmd.Flags().String("base-path", "", "Path the site is served from, e.g. /blog, for sites built with --base-path")
+	rootCmd.AddCommand(checkCmd)
+
+	templatesCmd := &cobra.Command{
+		Use:   "templates",
+		Short: "List, eject and validate page templates",
+	}
+	templatesCmd.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "List the bundled templates",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			names, err := sitegen.ListTemplates()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			for _, name := range names {
+				fmt.Println(name)
+			}
+		},
+	})
+	ejectCmd := &cobra.Command{
+		Use:   "eject [name] [dir]",
+		Short: "Copy a bundled template and style.css into a site for customization (default dir: .)",
+		Args:  cobra.RangeArgs(1, 2),
+		Run: func(cmd *cobra.Command, args []string) {
+			dir := "."
+			if len(args) > 1 {
+				dir = args[1]
+			}
+			force, _ := cmd.Flags().GetBool("force")
+			written, err := sitegen.EjectTemplate(args[0], dir, force)
+			for _, path := range written {
+				fmt.Printf("[Templates] Wrote %s\n", path)
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+		},
+	}
+	ejectCmd.Flags().BoolP("force", "f", false, "Overwrite existing files")
+	templatesCmd.AddCommand(ejectCmd)
+	templatesCmd.AddCommand(&cobra.Command{
+		Use:   "validate [file]",
+		Short: "Render sample pages through a template to check it for errors",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := sitegen.ValidateTemplate(args[0]); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Printf("[Templates] %s is valid\n", args[0])
+		},
+	})
+	rootCmd.AddCommand(templatesCmd)
+
+	genCSSCmd := &cobra.Command{
+		Use:   "gen-css",
+		Short: "Generate the stylesheet for highlighted code blocks",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			if list, _ := cmd.Flags().GetBool("list"); list {
+				for _, name := range sitegen.HighlightStyles() {
+					fmt.Println(name)
+				}
+				return
+			}
+			style, _ := cmd.Flags().GetString("style")
+			output, _ := cmd.Flags().GetString("output")
+			var css bytes.Buffer
+			if err := sitegen.HighlightCSS(&css, style); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			if output == "" {
+				fmt.Print(css.String())
+				return
+			}
+			if err := os.WriteFile(output, css.Bytes(), 0644); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Printf("[CSS] Wrote %s style to %s\n", style, output)
+		},
+	}
+	genCSSCmd.Flags().String("style", sitegen.DefaultHighlightStyle, "Chroma style to generate, e.g. github, monokai, dracula")
+	genCSSCmd.Flags().StringP("output", "o", "", "File to write the stylesheet to (default: stdout)")
+	genCSSCmd.Flags().Bool("list", false, "List the available styles")
+	rootCmd.AddCommand(genCSSCmd)
+
 	rootCmd.AddCommand(&cobra.Command{
 		Use:   "version",
 		Short: "Show the version of Colade",